@@ -1,69 +1,54 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
-	"github.com/joho/godotenv"
-
-	clients "backend-go/internal/clients"
-	db "backend-go/internal/db"
-	sqlc "backend-go/internal/db/sqlc"
-	"backend-go/internal/events"
-	router "backend-go/internal/router"
-	worker "backend-go/internal/worker"
+	"backend-go/internal/app"
+	"backend-go/internal/config"
+	"backend-go/internal/logging"
 )
 
-var bucketName string
-var taskQueueName string
-var responseQueueName string
-
 func main() {
-	_ = godotenv.Load(".env")           // attempt root .env first
-	_ = godotenv.Load("backend-go.env") // fallback / legacy
-
-	bucketName = getenvDefault("S3_BUCKET_NAME", "file-overview-system-bucket")
-	taskQueueName = getenvDefault("TASK_QUEUE_NAME", "task-queue")
-	responseQueueName = getenvDefault("RESPONSE_QUEUE_NAME", "response-queue")
-
-	conn, err := db.Connect()
-
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatalf("database connection error: %v", err)
+		// before the logger is set up, and one problem per line
+		fmt.Fprintf(os.Stderr, "invalid config:\n  %s\n", strings.ReplaceAll(err.Error(), "\n", "\n  "))
+		os.Exit(2)
+	}
+	if cfg.PrintOnly {
+		if err := cfg.Print(os.Stdout); err != nil {
+			os.Exit(1)
+		}
+		return
 	}
 
-	defer conn.Close()
-	fmt.Println("Connected to NeonDB")
-
-	queries := sqlc.New(conn)
-
-	s3Client := clients.InitS3Client()
-	clients.CreateBucket(s3Client, bucketName)
-
-	sqsClient := clients.InitSQSClient()
-	clients.CreateQueue(sqsClient, taskQueueName)
-	clients.CreateQueue(sqsClient, responseQueueName)
+	logging.Setup(cfg.Log)
+	slog.Info("config loaded", "file", cfg.File, "env_files", cfg.EnvFiles)
 
+	a, err := app.New(cfg)
 	if err != nil {
-		log.Fatalf("failed to create SQS queue: %v", err)
+		fatal("failed to start", err)
 	}
 
-	broadcaster := events.NewBroadcaster()
-
-	worker.StartResponseWorker(sqsClient, s3Client, responseQueueName, bucketName, broadcaster)
-
-	r := router.SetupRouter(queries, s3Client, sqsClient, bucketName, taskQueueName, broadcaster)
-
-	port := getenvDefault("PORT", "8080")
-	r.Run(":" + port)
-	fmt.Printf("Server started on http://localhost:%s\n", port)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := a.Run(ctx); err != nil {
+		fatal("server stopped", err)
+	}
 }
 
-func getenvDefault(key, def string) string {
-	v := os.Getenv(key)
-	if v == "" {
-		return def
-	}
-	return v
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
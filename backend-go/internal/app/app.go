@@ -0,0 +1,259 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"backend-go/internal/ask"
+	clients "backend-go/internal/clients"
+	"backend-go/internal/config"
+	db "backend-go/internal/db"
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/deadletter"
+	"backend-go/internal/dispatch"
+	"backend-go/internal/embed"
+	"backend-go/internal/events"
+	"backend-go/internal/gitimport"
+	"backend-go/internal/health"
+	"backend-go/internal/idempotency"
+	"backend-go/internal/ingest"
+	"backend-go/internal/llm"
+	"backend-go/internal/metrics"
+	"backend-go/internal/outbox"
+	"backend-go/internal/quota"
+	router "backend-go/internal/router"
+	"backend-go/internal/storage"
+	"backend-go/internal/tracing"
+	worker "backend-go/internal/worker"
+)
+
+// App holds the backend's components. New starts them in dependency order
+// and Shutdown stops them in the reverse order.
+type App struct {
+	cfg *config.Config
+
+	pool        *pgxpool.Pool
+	queries     *sqlc.Queries
+	s3Client    *s3.Client
+	sqsClient   *sqs.Client
+	broadcaster *events.Broadcaster
+	server      *http.Server
+
+	// stops are run last to first by Shutdown
+	stops []stop
+}
+
+type stop struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// New connects to the database, storage and queues, starts the background
+// workers and prepares the HTTP server. If a step fails, what was already
+// started is stopped again.
+func New(cfg *config.Config) (*App, error) {
+	a := &App{cfg: cfg}
+	if err := a.start(); err != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		a.Shutdown(shutdownCtx)
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *App) start() error {
+	cfg := a.cfg
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	// stopped last, so the spans of everything else shutting down are
+	// exported too
+	a.onShutdown("tracing", shutdownTracing)
+
+	pool, err := db.Connect(cfg.Database.URL)
+	if err != nil {
+		return fmt.Errorf("database connection error: %w", err)
+	}
+	a.pool = pool
+	a.onShutdown("database", func(context.Context) error {
+		pool.Close()
+		return nil
+	})
+	slog.Info("connected to database")
+	a.queries = sqlc.New(pool)
+	queries := a.queries
+
+	a.s3Client, err = clients.InitS3Client(cfg.AWS.Region, cfg.AWS.S3Endpoint)
+	if err != nil {
+		return err
+	}
+	if err := clients.CreateBucket(a.s3Client, cfg.AWS.BucketName, cfg.AWS.Region); err != nil {
+		return err
+	}
+
+	deadLetterConfig := cfg.DeadLetter
+	deadLetterConfig.Queues = []string{cfg.AWS.TaskQueueName, cfg.AWS.ResponseQueueName}
+	deadLetterConfig.ResponseQueue = cfg.AWS.ResponseQueueName
+
+	a.sqsClient, err = clients.InitSQSClient(cfg.AWS.Region, cfg.AWS.SQSEndpoint)
+	if err != nil {
+		return err
+	}
+	// the worker extends a task's visibility while it works on it, from
+	// this timeout on
+	if _, err := clients.CreateQueue(a.sqsClient, cfg.AWS.TaskQueueName, cfg.AWS.TaskVisibilityTimeout, deadLetterConfig.MaxReceiveCount); err != nil {
+		return err
+	}
+	// the response worker sets the visibility of what it receives itself
+	if _, err := clients.CreateQueue(a.sqsClient, cfg.AWS.ResponseQueueName, 0, deadLetterConfig.MaxReceiveCount); err != nil {
+		return err
+	}
+
+	a.broadcaster = events.NewBroadcaster()
+
+	embedder, err := embed.New(cfg.Embeddings.Provider, cfg.Embeddings.URL, cfg.Embeddings.APIKey, cfg.Embeddings.Model)
+	if err != nil {
+		return fmt.Errorf("invalid embeddings config: %w", err)
+	}
+	indexer := embed.NewIndexer(pool, queries, embedder, a.s3Client, cfg.AWS.BucketName)
+	if err := indexer.Start(a.background("semantic index")); err != nil {
+		return fmt.Errorf("failed to start semantic index: %w", err)
+	}
+
+	quotas := quota.NewService(pool, queries, cfg.Quota.Limits, cfg.Quota.WorkspaceMaxBytes)
+
+	relay := outbox.NewRelay(queries, map[string]dispatch.Queue{
+		outbox.TaskQueue: dispatch.NewSQSQueue(a.sqsClient, cfg.AWS.TaskQueueName),
+	}, cfg.Outbox)
+	relay.Start(a.background("outbox relay"))
+
+	dispatcher := dispatch.NewDispatcher(dispatch.NewStore(pool, queries), relay.Queue(outbox.TaskQueue), cfg.Dispatch)
+	dispatcher.Start(a.background("dispatcher"))
+
+	blobs := storage.NewBlobStore(pool, queries, a.s3Client, cfg.AWS.BucketName)
+	ingester := ingest.NewService(pool, queries, blobs, a.s3Client, dispatcher, indexer, a.broadcaster, quotas, cfg.AWS.BucketName)
+	llmClient := llm.NewClient(cfg.LLM.URL, cfg.LLM.APIKey)
+	asker := ask.NewService(queries, indexer, quotas, llmClient, cfg.LLM.AskModel)
+	importer := gitimport.NewImporter(queries, ingester, a.broadcaster, cfg.GitImport.Root)
+	ingester.StartReconciler(a.background("reconciler"), ingest.ReconcileInterval)
+
+	deadLetters := deadletter.NewService(a.sqsClient, queries, deadLetterConfig)
+	deadLetters.Start(a.background("dead-letter sweep"))
+
+	idempotencyStore := idempotency.NewStore(queries, cfg.Idempotency)
+	idempotencyStore.Start(a.background("idempotency cleanup"))
+
+	responses := worker.NewResponseWorker(pool, a.sqsClient, a.s3Client, queries, cfg.AWS.ResponseQueueName, a.broadcaster, llmClient, quotas, dispatcher, deadLetters, cfg.Worker)
+	if err := responses.Start(context.Background()); err != nil {
+		return fmt.Errorf("failed to start response worker: %w", err)
+	}
+	// finish the responses being handled, so none waits out its visibility
+	// timeout to be handled again
+	a.onShutdown("response worker", responses.Stop)
+
+	metrics.RegisterStateCollector(queries, a.sqsClient, []string{
+		cfg.AWS.TaskQueueName,
+		cfg.AWS.ResponseQueueName,
+		clients.DeadLetterQueueName(cfg.AWS.TaskQueueName),
+		clients.DeadLetterQueueName(cfg.AWS.ResponseQueueName),
+	})
+
+	// liveness doesn't depend on anything outside the process, so an outage
+	// doesn't get every instance restarted
+	liveness := health.NewChecker(cfg.Health)
+	readiness := health.NewChecker(cfg.Health,
+		health.Database(pool),
+		health.Bucket(a.s3Client, cfg.AWS.BucketName),
+		health.Queue(a.sqsClient, cfg.AWS.TaskQueueName),
+		health.Queue(a.sqsClient, cfg.AWS.ResponseQueueName),
+		health.Check{
+			Name: "response worker",
+			Probe: func(context.Context) error {
+				return responses.CheckPolling(responses.MaxPollAge())
+			},
+		},
+	)
+
+	r := router.SetupRouter(queries, a.s3Client, cfg.AWS.BucketName, ingester, importer, indexer, asker, a.broadcaster, quotas, dispatcher, deadLetters, idempotencyStore, responses, liveness, readiness, cfg.Server.AllowedOrigins)
+	a.server = &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           r,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	// event streams never end on their own; ask their clients to reconnect,
+	// to another backend if there is one, once shutdown begins
+	a.server.RegisterOnShutdown(a.broadcaster.Close)
+	return nil
+}
+
+// background returns a context for a background service that is cancelled
+// when the app shuts down, in the reverse order the services were started.
+func (a *App) background(name string) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.onShutdown(name, func(context.Context) error {
+		cancel()
+		return nil
+	})
+	return ctx
+}
+
+func (a *App) onShutdown(name string, fn func(ctx context.Context) error) {
+	a.stops = append(a.stops, stop{name: name, fn: fn})
+}
+
+// Run serves HTTP until ctx is done or the server fails, then shuts the app
+// down.
+func (a *App) Run(ctx context.Context) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- a.server.ListenAndServe()
+	}()
+	slog.Info("server started", "addr", "http://localhost:"+a.cfg.Server.Port)
+
+	var err error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	case err = <-serveErr:
+		err = fmt.Errorf("server error: %w", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.Server.ShutdownTimeout)
+	defer cancel()
+	return errors.Join(err, a.Shutdown(shutdownCtx))
+}
+
+// Shutdown stops taking requests and waits for the ones in progress, then
+// stops the workers and closes the database, all within ctx.
+func (a *App) Shutdown(ctx context.Context) error {
+	var errs []error
+	if a.server != nil {
+		if err := a.server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop HTTP server: %w", err))
+		}
+	}
+	for i := len(a.stops) - 1; i >= 0; i-- {
+		s := a.stops[i]
+		if err := s.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", s.name, err))
+		}
+	}
+	a.stops = nil
+	if len(errs) == 0 {
+		slog.Info("shutdown complete")
+	}
+	return errors.Join(errs...)
+}
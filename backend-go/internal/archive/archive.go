@@ -0,0 +1,262 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported archive format")
+	ErrTooManyEntries    = errors.New("archive has too many entries")
+	ErrTooLarge          = errors.New("archive expands beyond the size limit")
+	ErrEntryTooLarge     = errors.New("entry exceeds the size limit")
+)
+
+type Limits struct {
+	MaxEntries   int
+	MaxEntrySize int64
+	MaxTotalSize int64
+	Extensions   []string
+}
+
+var DefaultLimits = Limits{
+	MaxEntries:   1000,
+	MaxEntrySize: 5 << 20,
+	MaxTotalSize: 100 << 20,
+	Extensions:   []string{".md", ".markdown", ".txt"},
+}
+
+// Entry is a single file from the archive. Skipped entries carry the reason
+// and no body; they are reported so callers can return per-entry results.
+type Entry struct {
+	Path    string
+	Body    io.Reader
+	Skipped string
+}
+
+// Extract walks the archive and calls fn for every regular file, in archive
+// order. Entry bodies are only valid for the duration of the call. Sizes are
+// enforced on the decompressed bytes, never on what the headers claim.
+func Extract(filename string, r io.ReaderAt, size int64, limits Limits, fn func(Entry) error) error {
+	switch detect(filename, r) {
+	case "zip":
+		return extractZip(r, size, limits, fn)
+	case "tar.gz":
+		return extractTarGz(io.NewSectionReader(r, 0, size), limits, fn)
+	default:
+		return ErrUnsupportedFormat
+	}
+}
+
+func detect(filename string, r io.ReaderAt) string {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	}
+
+	magic := make([]byte, 4)
+	if _, err := r.ReadAt(magic, 0); err != nil {
+		return ""
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		return "zip"
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return "tar.gz"
+	}
+	return ""
+}
+
+func extractZip(r io.ReaderAt, size int64, limits Limits, fn func(Entry) error) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	if len(zr.File) > limits.MaxEntries {
+		return ErrTooManyEntries
+	}
+
+	budget := &budget{remaining: limits.MaxTotalSize}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			if err := fn(skip(f.Name, "not a regular file")); err != nil {
+				return err
+			}
+			continue
+		}
+
+		entry, ok := accept(f.Name, limits)
+		if !ok {
+			if err := fn(entry); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			if err := fn(Entry{Path: entry.Path, Skipped: "unreadable entry"}); err != nil {
+				return err
+			}
+			continue
+		}
+		entry.Body = budget.limit(rc, limits.MaxEntrySize)
+		err = fn(entry)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if budget.exceeded {
+			return ErrTooLarge
+		}
+	}
+	return nil
+}
+
+func extractTarGz(r io.Reader, limits Limits, fn func(Entry) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	budget := &budget{remaining: limits.MaxTotalSize}
+	entries := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		entries++
+		if entries > limits.MaxEntries {
+			return ErrTooManyEntries
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeReg:
+		default:
+			if err := fn(skip(hdr.Name, "not a regular file")); err != nil {
+				return err
+			}
+			continue
+		}
+
+		entry, ok := accept(hdr.Name, limits)
+		if ok {
+			entry.Body = budget.limit(tr, limits.MaxEntrySize)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+		if budget.exceeded {
+			return ErrTooLarge
+		}
+	}
+}
+
+// accept validates the entry name and extension. The returned entry has its
+// cleaned path set, or a skip reason when ok is false.
+func accept(name string, limits Limits) (Entry, bool) {
+	cleaned, ok := CleanPath(name)
+	if !ok {
+		return Entry{Path: name, Skipped: "unsafe path"}, false
+	}
+	if strings.HasPrefix(cleaned, "__MACOSX/") {
+		return Entry{Path: cleaned, Skipped: "metadata entry"}, false
+	}
+	if !hasExtension(cleaned, limits.Extensions) {
+		return Entry{Path: cleaned, Skipped: "unsupported file type"}, false
+	}
+	return Entry{Path: cleaned}, true
+}
+
+func skip(name string, reason string) Entry {
+	if cleaned, ok := CleanPath(name); ok {
+		name = cleaned
+	}
+	return Entry{Path: name, Skipped: reason}
+}
+
+// CleanPath normalizes an entry name to a relative slash-separated path and
+// rejects anything that could escape the archive root (zip-slip).
+func CleanPath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || strings.ContainsRune(name, 0) {
+		return "", false
+	}
+	if len(name) >= 2 && name[1] == ':' {
+		// windows drive letter
+		return "", false
+	}
+
+	cleaned := path.Clean(name)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", false
+		}
+	}
+	return cleaned, true
+}
+
+func hasExtension(name string, extensions []string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// budget tracks decompressed bytes across all entries of one archive.
+type budget struct {
+	remaining int64
+	exceeded  bool
+}
+
+func (b *budget) limit(r io.Reader, entryLimit int64) io.Reader {
+	return &limitedReader{r: r, entryLeft: entryLimit, budget: b}
+}
+
+type limitedReader struct {
+	r         io.Reader
+	entryLeft int64
+	budget    *budget
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.entryLeft -= int64(n)
+	l.budget.remaining -= int64(n)
+	if l.budget.remaining < 0 {
+		l.budget.exceeded = true
+		return n, ErrTooLarge
+	}
+	if l.entryLeft < 0 {
+		return n, ErrEntryTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,194 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+type file struct {
+	name string
+	body string
+}
+
+func makeZip(t *testing.T, files []file) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatalf("zip create %s: %v", f.name, err)
+		}
+		if _, err := io.WriteString(w, f.body); err != nil {
+			t.Fatalf("zip write %s: %v", f.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func makeTarGz(t *testing.T, files []file) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.body)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("tar header %s: %v", f.name, err)
+		}
+		if _, err := io.WriteString(tw, f.body); err != nil {
+			t.Fatalf("tar write %s: %v", f.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar close: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// extract reads every accepted entry to the end, like the importer does,
+// and returns Extract's error.
+func extract(name string, data []byte, limits Limits) error {
+	return Extract(name, bytes.NewReader(data), int64(len(data)), limits, func(e Entry) error {
+		if e.Body != nil {
+			io.Copy(io.Discard, e.Body)
+		}
+		return nil
+	})
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"notes.md", "notes.md", true},
+		{"docs/guide/intro.md", "docs/guide/intro.md", true},
+		{"./docs//intro.md", "docs/intro.md", true},
+		{"docs\\guide\\intro.md", "docs/guide/intro.md", true},
+		{"", "", false},
+		{".", "", false},
+		{"..", "", false},
+		{"../secret.md", "", false},
+		{"docs/../../secret.md", "", false},
+		// .. is rejected even where it would stay inside the root
+		{"docs/../intro.md", "", false},
+		{"..\\secret.md", "", false},
+		{"docs\\..\\..\\secret.md", "", false},
+		{"/etc/passwd", "", false},
+		{"\\etc\\passwd", "", false},
+		{"C:\\Windows\\notes.md", "", false},
+		{"c:/notes.md", "", false},
+		{"notes\x00.md", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := CleanPath(tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("CleanPath(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestExtractTooManyEntries(t *testing.T) {
+	files := make([]file, 4)
+	for i := range files {
+		files[i] = file{name: fmt.Sprintf("doc%d.md", i), body: "# doc"}
+	}
+	limits := DefaultLimits
+	limits.MaxEntries = 3
+
+	for name, data := range map[string][]byte{
+		"notes.zip":    makeZip(t, files),
+		"notes.tar.gz": makeTarGz(t, files),
+	} {
+		if err := extract(name, data, limits); !errors.Is(err, ErrTooManyEntries) {
+			t.Errorf("%s: Extract returned %v, want %v", name, err, ErrTooManyEntries)
+		}
+	}
+}
+
+func TestExtractTooLarge(t *testing.T) {
+	// every entry fits on its own, together they don't
+	files := make([]file, 3)
+	for i := range files {
+		files[i] = file{name: fmt.Sprintf("doc%d.md", i), body: strings.Repeat("a", 400)}
+	}
+	limits := DefaultLimits
+	limits.MaxEntrySize = 500
+	limits.MaxTotalSize = 1000
+
+	for name, data := range map[string][]byte{
+		"notes.zip":    makeZip(t, files),
+		"notes.tar.gz": makeTarGz(t, files),
+	} {
+		if err := extract(name, data, limits); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("%s: Extract returned %v, want %v", name, err, ErrTooLarge)
+		}
+	}
+}
+
+func TestExtractEntryTooLarge(t *testing.T) {
+	files := []file{{name: "big.md", body: strings.Repeat("a", 600)}}
+	limits := DefaultLimits
+	limits.MaxEntrySize = 500
+
+	for name, data := range map[string][]byte{
+		"notes.zip":    makeZip(t, files),
+		"notes.tar.gz": makeTarGz(t, files),
+	} {
+		var readErr error
+		err := Extract(name, bytes.NewReader(data), int64(len(data)), limits, func(e Entry) error {
+			_, readErr = io.Copy(io.Discard, e.Body)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: Extract: %v", name, err)
+		}
+		if !errors.Is(readErr, ErrEntryTooLarge) {
+			t.Errorf("%s: reading the entry returned %v, want %v", name, readErr, ErrEntryTooLarge)
+		}
+	}
+}
+
+func TestExtractSkipsUnsafePaths(t *testing.T) {
+	files := []file{
+		{name: "../escape.md", body: "x"},
+		{name: "docs/intro.md", body: "# intro"},
+	}
+	data := makeZip(t, files)
+
+	var got []Entry
+	err := Extract("notes.zip", bytes.NewReader(data), int64(len(data)), DefaultLimits, func(e Entry) error {
+		got = append(got, Entry{Path: e.Path, Skipped: e.Skipped})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := []Entry{
+		{Path: "../escape.md", Skipped: "unsafe path"},
+		{Path: "docs/intro.md"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got entries %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package ask
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/embed"
+	"backend-go/internal/ingest"
+	"backend-go/internal/llm"
+	"backend-go/internal/quota"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Events sent while an answer is produced.
+const (
+	EventSources = "sources"
+	EventToken   = "token"
+)
+
+const (
+	maxPassages = 6
+	// maxHistory is how many earlier messages of a thread are sent along,
+	// enough for follow-ups without growing the prompt forever.
+	maxHistory  = 10
+	titleLength = 80
+)
+
+// Citation points at the part of the document an excerpt came from.
+type Citation struct {
+	Ref       int    `json:"ref"`
+	Heading   string `json:"heading,omitempty"`
+	StartLine int    `json:"startLine"`
+	EndLine   int    `json:"endLine"`
+}
+
+type Answer struct {
+	Message sqlc.AskMessage
+	// Citations are the excerpts the answer actually referred to.
+	Citations []Citation
+}
+
+type TokenMessage struct {
+	Text string `json:"text"`
+}
+
+// Service answers questions about a single document from its most relevant
+// chunks, keeping the conversation in a thread so follow-ups have context.
+type Service struct {
+	queries *sqlc.Queries
+	indexer *embed.Indexer
+	quotas  *quota.Service
+	llm     *llm.Client
+	model   string
+}
+
+func NewService(queries *sqlc.Queries, indexer *embed.Indexer, quotas *quota.Service, client *llm.Client, model string) *Service {
+	return &Service{
+		queries: queries,
+		indexer: indexer,
+		quotas:  quotas,
+		llm:     client,
+		model:   model,
+	}
+}
+
+// Thread returns the caller's thread on the document, or starts a new one
+// when threadID is zero. pgx.ErrNoRows means the thread doesn't exist or
+// belongs to someone else.
+func (s *Service) Thread(ctx context.Context, userID int32, documentID int32, threadID int32, question string) (sqlc.AskThread, error) {
+	if threadID != 0 {
+		return s.queries.GetAskThread(ctx, sqlc.GetAskThreadParams{
+			ID:         threadID,
+			DocumentID: documentID,
+			UserID:     userID,
+		})
+	}
+	return s.queries.CreateAskThread(ctx, sqlc.CreateAskThreadParams{
+		DocumentID: documentID,
+		UserID:     userID,
+		Title:      title(question),
+	})
+}
+
+// Ask answers question within thread. emit receives the excerpts used as
+// context first and then every token of the answer; an error from emit
+// aborts the answer. The exchange is only stored once the answer is
+// complete.
+func (s *Service) Ask(ctx context.Context, thread sqlc.AskThread, doc sqlc.Document, question string, emit func(event string, data any) error) (Answer, error) {
+	history, err := s.queries.ListAskMessages(ctx, thread.ID)
+	if err != nil {
+		return Answer{}, fmt.Errorf("failed to load thread: %w", err)
+	}
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+
+	// a follow-up like "and for uploads?" only retrieves well together with
+	// the question before it
+	query := question
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == RoleUser {
+			query = history[i].Content + "\n" + question
+			break
+		}
+	}
+
+	passages, err := s.indexer.Passages(ctx, doc, query, maxPassages)
+	if err != nil {
+		return Answer{}, fmt.Errorf("failed to retrieve passages: %w", err)
+	}
+	sources := make([]Citation, len(passages))
+	for i, p := range passages {
+		sources[i] = Citation{Ref: i + 1, Heading: p.Heading, StartLine: p.StartLine, EndLine: p.EndLine}
+	}
+	if err := emit(EventSources, sources); err != nil {
+		return Answer{}, err
+	}
+
+	messages := []llm.Message{{Role: "system", Content: systemPrompt(doc.Path, passages)}}
+	for _, m := range history {
+		messages = append(messages, llm.Message{Role: m.Role, Content: m.Content})
+	}
+	messages = append(messages, llm.Message{Role: RoleUser, Content: question})
+
+	text, usage, err := s.llm.Stream(ctx, s.model, messages, func(token string) error {
+		return emit(EventToken, TokenMessage{Text: token})
+	})
+	// what was generated is paid for even if the stream broke off
+	s.charge(context.WithoutCancel(ctx), thread.UserID, messages, text, usage)
+	if err != nil {
+		return Answer{}, err
+	}
+
+	cited := citedSources(text, sources)
+	citations, _ := json.Marshal(cited)
+
+	_, err = s.queries.CreateAskMessage(ctx, sqlc.CreateAskMessageParams{
+		ThreadID:  thread.ID,
+		Role:      RoleUser,
+		Content:   question,
+		Citations: []byte("[]"),
+	})
+	if err != nil {
+		return Answer{}, fmt.Errorf("failed to store question: %w", err)
+	}
+	msg, err := s.queries.CreateAskMessage(ctx, sqlc.CreateAskMessageParams{
+		ThreadID:  thread.ID,
+		Role:      RoleAssistant,
+		Content:   text,
+		Citations: citations,
+	})
+	if err != nil {
+		return Answer{}, fmt.Errorf("failed to store answer: %w", err)
+	}
+	if err := s.queries.TouchAskThread(ctx, thread.ID); err != nil {
+		return Answer{}, fmt.Errorf("failed to update thread: %w", err)
+	}
+
+	return Answer{Message: msg, Citations: cited}, nil
+}
+
+// charge adds a completion to the user's monthly spend. Providers that
+// don't report usage, or a stream cut off before it, get an estimate from
+// the length of the prompt and answer.
+func (s *Service) charge(ctx context.Context, userID int32, messages []llm.Message, text string, usage llm.Usage) {
+	if usage.PromptTokens == 0 && usage.CompletionTokens == 0 {
+		var size int64
+		for _, m := range messages {
+			size += int64(len(m.Content))
+		}
+		usage.PromptTokens = int32(ingest.EstimateTokens(size))
+		usage.CompletionTokens = int32(ingest.EstimateTokens(int64(len(text))))
+	}
+
+	var cost float64
+	model, err := s.queries.GetLlmModel(ctx, s.model)
+	if err == nil {
+		cost = ingest.Cost(model, usage.PromptTokens, usage.CompletionTokens)
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		slog.ErrorContext(ctx, "failed to load model price", "model", s.model, "error", err)
+	}
+
+	tokens := int64(usage.PromptTokens) + int64(usage.CompletionTokens)
+	if err := s.quotas.Spend(ctx, userID, tokens, cost); err != nil {
+		slog.ErrorContext(ctx, "failed to record ask spend", "user_id", userID, "error", err)
+	}
+}
+
+func systemPrompt(path string, passages []embed.Passage) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "You answer questions about the markdown document %q using only the numbered excerpts below. ", path)
+	b.WriteString("Cite every excerpt you rely on as [n], e.g. [2]. ")
+	b.WriteString("If the excerpts don't answer the question, say so instead of guessing.\n")
+	for i, p := range passages {
+		fmt.Fprintf(&b, "\n[%d] ", i+1)
+		if p.Heading != "" {
+			fmt.Fprintf(&b, "%s ", p.Heading)
+		}
+		fmt.Fprintf(&b, "(lines %d-%d)\n%s\n", p.StartLine, p.EndLine, p.Text)
+	}
+	return b.String()
+}
+
+var citationRef = regexp.MustCompile(`\[(\d+)\]`)
+
+// citedSources picks the sources referenced as [n] in the answer, in order
+// of first mention.
+func citedSources(answer string, sources []Citation) []Citation {
+	cited := []Citation{}
+	seen := map[int]bool{}
+	for _, m := range citationRef.FindAllStringSubmatch(answer, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 || n > len(sources) || seen[n] {
+			continue
+		}
+		seen[n] = true
+		cited = append(cited, sources[n-1])
+	}
+	return cited
+}
+
+func title(question string) string {
+	question = strings.Join(strings.Fields(question), " ")
+	runes := []rune(question)
+	if len(runes) <= titleLength {
+		return question
+	}
+	return string(runes[:titleLength-1]) + "…"
+}
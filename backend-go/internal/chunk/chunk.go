@@ -0,0 +1,121 @@
+package chunk
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// MaxSize is the soft limit on chunk length in bytes. Chunks are cut at
+	// headings first, then at blank lines once they grow past it, and
+	// anywhere outside of headings once they reach four times the size.
+	MaxSize = 1500
+	// MaxChunks bounds how many chunks one document produces.
+	MaxChunks = 400
+)
+
+// Chunk is a piece of a markdown document together with where it came from,
+// so answers and search hits can point back at the source.
+type Chunk struct {
+	Index int
+	// Heading is the path of headings above the chunk, e.g. "Setup > Docker".
+	Heading string
+	// StartLine and EndLine are 1-based and inclusive.
+	StartLine int
+	EndLine   int
+	Text      string
+}
+
+var headingLine = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+
+// Split cuts a markdown document into chunks along its heading structure.
+// Headings inside fenced code blocks are ignored.
+func Split(doc string) []Chunk {
+	doc = strings.ReplaceAll(strings.ToValidUTF8(doc, ""), "\x00", "")
+	lines := strings.Split(strings.ReplaceAll(doc, "\r\n", "\n"), "\n")
+
+	var (
+		chunks  []Chunk
+		stack   []string
+		buf     []string
+		start   int
+		end     int
+		size    int
+		fence   string
+		heading string
+	)
+
+	flush := func() {
+		text := strings.TrimSpace(strings.Join(buf, "\n"))
+		if text != "" && len(chunks) < MaxChunks {
+			chunks = append(chunks, Chunk{
+				Index:     len(chunks),
+				Heading:   heading,
+				StartLine: start,
+				EndLine:   end,
+				Text:      text,
+			})
+		}
+		buf = buf[:0]
+		size = 0
+	}
+
+	for i, line := range lines {
+		n := i + 1
+		trimmed := strings.TrimSpace(line)
+
+		if fence == "" {
+			if m := headingLine.FindStringSubmatch(line); m != nil {
+				flush()
+				level := len(m[1])
+				if level <= len(stack) {
+					stack = stack[:level-1]
+				}
+				for len(stack) < level-1 {
+					stack = append(stack, "")
+				}
+				stack = append(stack, m[2])
+				heading = joinHeadings(stack)
+			} else if size >= MaxSize && trimmed == "" {
+				flush()
+			}
+		}
+		if size >= 4*MaxSize {
+			flush()
+		}
+
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			switch {
+			case fence == "":
+				fence = trimmed[:3]
+			case strings.HasPrefix(trimmed, fence):
+				fence = ""
+			}
+		}
+
+		if len(buf) == 0 {
+			if trimmed == "" {
+				continue
+			}
+			start = n
+		}
+		buf = append(buf, line)
+		size += len(line) + 1
+		if trimmed != "" {
+			end = n
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+func joinHeadings(stack []string) string {
+	parts := make([]string, 0, len(stack))
+	for _, h := range stack {
+		if h != "" {
+			parts = append(parts, h)
+		}
+	}
+	return strings.Join(parts, " > ")
+}
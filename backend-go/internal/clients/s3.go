@@ -1,55 +1,83 @@
 package clients
 
 import (
+	"bytes"
 	"context"
-	"log"
-	"os"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"backend-go/internal/metrics"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
 )
 
-func InitS3Client() *s3.Client {
-	region := os.Getenv("AWS_DEFAULT_REGION")
-	if region == "" {
-		region = "eu-central-1"
-	}
-	endpoint := os.Getenv("S3_ENDPOINT")
-	if endpoint == "" {
-		endpoint = os.Getenv("LOCALSTACK_ENDPOINT")
-	}
-	if endpoint == "" {
-		endpoint = "http://localhost:4566"
-	}
-
+func InitS3Client(region string, endpoint string) (*s3.Client, error) {
 	cfg, err := config.LoadDefaultConfig(
 		context.TODO(),
 		config.WithRegion(region),
 	)
 	if err != nil {
-		log.Printf("failed to load AWS config: %v", err)
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
 	s3Client := s3.NewFromConfig(cfg, func(o *s3.Options) {
 		o.UsePathStyle = true
 		o.BaseEndpoint = aws.String(endpoint)
+		o.APIOptions = append(o.APIOptions, metrics.AWSMiddleware)
+		otelaws.AppendMiddlewares(&o.APIOptions)
 	})
-	return s3Client
+	return s3Client, nil
 }
 
-func CreateBucket(client *s3.Client, bucketName string) error {
-	_, err := client.CreateBucket(context.TODO(), &s3.CreateBucketInput{
+// CreateBucket creates bucketName in region unless it already exists.
+func CreateBucket(client *s3.Client, bucketName string, region string) error {
+	input := &s3.CreateBucketInput{
 		Bucket: aws.String(bucketName),
-		CreateBucketConfiguration: &s3types.CreateBucketConfiguration{
-			LocationConstraint: s3types.BucketLocationConstraintEuCentral1,
-		},
-	})
+	}
+	// us-east-1 is the default and S3 rejects it as a constraint
+	if region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3types.CreateBucketConfiguration{
+			LocationConstraint: s3types.BucketLocationConstraint(region),
+		}
+	}
+	_, err := client.CreateBucket(context.TODO(), input)
+	var owned *s3types.BucketAlreadyOwnedByYou
+	if errors.As(err, &owned) {
+		slog.Info("bucket already exists", "bucket", bucketName)
+		return nil
+	}
 	if err != nil {
-		log.Printf("failed to create bucket: %v", err)
-		return err
+		return fmt.Errorf("failed to create bucket %s: %w", bucketName, err)
 	}
-	log.Printf("Bucket %s created successfully\n", bucketName)
+	slog.Info("bucket created", "bucket", bucketName)
 	return nil
 }
+
+func ReadObject(ctx context.Context, client *s3.Client, bucketName string, key string) ([]byte, error) {
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	return io.ReadAll(out.Body)
+}
+
+func WriteObject(ctx context.Context, client *s3.Client, bucketName string, key string, body []byte, contentType string) error {
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucketName),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
@@ -2,65 +2,141 @@ package clients
 
 import (
 	"context"
-	"log"
-	"os"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"backend-go/internal/metrics"
+	"backend-go/internal/tracing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func InitSQSClient() *sqs.Client {
-	region := os.Getenv("AWS_DEFAULT_REGION")
-	if region == "" {
-		region = "eu-central-1"
-	}
-	endpoint := os.Getenv("SQS_ENDPOINT")
-	if endpoint == "" {
-		endpoint = os.Getenv("LOCALSTACK_ENDPOINT")
-	}
-	if endpoint == "" {
-		endpoint = "http://localhost:4566"
-	}
-
+func InitSQSClient(region string, endpoint string) (*sqs.Client, error) {
 	cfg, err := config.LoadDefaultConfig(
 		context.TODO(),
 		config.WithRegion(region),
 	)
 	if err != nil {
-		log.Fatalf("failed to load AWS config: %v", err)
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
 	client := sqs.NewFromConfig(cfg, func(o *sqs.Options) {
 		o.BaseEndpoint = aws.String(endpoint)
+		o.APIOptions = append(o.APIOptions, metrics.AWSMiddleware)
+		otelaws.AppendMiddlewares(&o.APIOptions)
 	})
 
-	return client
+	return client, nil
+}
+
+// DeadLetterQueueName is the name of the queue that receives the messages
+// of queueName that could not be processed.
+func DeadLetterQueueName(queueName string) string {
+	return queueName + "-dlq"
 }
 
-func CreateQueue(client *sqs.Client, queueName string) (string, error) {
+// CreateQueue creates queueName along with its dead-letter queue. A message
+// received maxReceiveCount times without being deleted is moved to the
+// dead-letter queue; 0 leaves the queue without one. A received message is
+// hidden from other receives for visibilityTimeout; 0 keeps the queue's.
+func CreateQueue(client *sqs.Client, queueName string, visibilityTimeout time.Duration, maxReceiveCount int) (string, error) {
 	out, err := client.CreateQueue(context.TODO(), &sqs.CreateQueueInput{
 		QueueName: &queueName,
 	})
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("failed to create queue %s: %w", queueName, err)
+	}
+	slog.Info("queue created", "queue", queueName, "url", *out.QueueUrl)
+
+	// set separately so queues created before they had these settings get
+	// them too
+	attributes := make(map[string]string)
+	if visibilityTimeout > 0 {
+		attributes[string(types.QueueAttributeNameVisibilityTimeout)] = strconv.Itoa(int(visibilityTimeout.Seconds()))
+	}
+	dlqName := DeadLetterQueueName(queueName)
+	if maxReceiveCount > 0 {
+		dlq, err := client.CreateQueue(context.TODO(), &sqs.CreateQueueInput{
+			QueueName: &dlqName,
+			Attributes: map[string]string{
+				// the longest SQS allows, so nothing expires before it is quarantined
+				string(types.QueueAttributeNameMessageRetentionPeriod): "1209600",
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to create dead-letter queue %s: %w", dlqName, err)
+		}
+		attrs, err := client.GetQueueAttributes(context.TODO(), &sqs.GetQueueAttributesInput{
+			QueueUrl:       dlq.QueueUrl,
+			AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameQueueArn},
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to look up dead-letter queue %s: %w", dlqName, err)
+		}
+		policy, _ := json.Marshal(map[string]string{
+			"deadLetterTargetArn": attrs.Attributes[string(types.QueueAttributeNameQueueArn)],
+			"maxReceiveCount":     strconv.Itoa(maxReceiveCount),
+		})
+		attributes[string(types.QueueAttributeNameRedrivePolicy)] = string(policy)
+	}
+	if len(attributes) == 0 {
+		return *out.QueueUrl, nil
+	}
+
+	_, err = client.SetQueueAttributes(context.TODO(), &sqs.SetQueueAttributesInput{
+		QueueUrl:   out.QueueUrl,
+		Attributes: attributes,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to set attributes of %s: %w", queueName, err)
+	}
+	if maxReceiveCount > 0 {
+		slog.Info("queue dead-letters", "queue", queueName, "dead_letter_queue", dlqName, "max_receive_count", maxReceiveCount)
+	}
+	if visibilityTimeout > 0 {
+		slog.Info("queue visibility timeout", "queue", queueName, "visibility_timeout", visibilityTimeout)
 	}
-	log.Printf("Queue %s created successfully at %s\n", queueName, *out.QueueUrl)
 	return *out.QueueUrl, nil
 }
 
-func SendMessage(client *sqs.Client, queueName string, messageBody string) error {
-	getOut, err := client.GetQueueUrl(context.TODO(), &sqs.GetQueueUrlInput{
+// SendMessage sends messageBody to queueName in a producer span, with the
+// trace context in the message attributes.
+func SendMessage(ctx context.Context, client *sqs.Client, queueName string, messageBody string) error {
+	ctx, span := tracing.Start(ctx, queueName+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "aws_sqs"),
+			attribute.String("messaging.destination.name", queueName),
+		),
+	)
+	defer span.End()
+
+	getOut, err := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
 		QueueName: &queueName,
 	})
 	if err != nil {
+		tracing.RecordError(span, err)
 		return err
 	}
 
-	_, err = client.SendMessage(context.TODO(), &sqs.SendMessageInput{
-		QueueUrl:    getOut.QueueUrl,
-		MessageBody: &messageBody,
+	out, err := client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:          getOut.QueueUrl,
+		MessageBody:       &messageBody,
+		MessageAttributes: tracing.InjectMessage(ctx, nil),
 	})
-
-	return err
+	if err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	span.SetAttributes(attribute.String("messaging.message.id", aws.ToString(out.MessageId)))
+	return nil
 }
@@ -0,0 +1,244 @@
+// Package config holds the backend's settings. Load reads them from a YAML
+// or TOML file, the environment and command-line flags over the defaults,
+// and validates them all at once.
+package config
+
+import (
+	"flag"
+	"strings"
+	"time"
+
+	"backend-go/internal/deadletter"
+	"backend-go/internal/dispatch"
+	"backend-go/internal/embed"
+	"backend-go/internal/health"
+	"backend-go/internal/idempotency"
+	"backend-go/internal/ingest"
+	"backend-go/internal/llm"
+	"backend-go/internal/logging"
+	"backend-go/internal/outbox"
+	"backend-go/internal/quota"
+	"backend-go/internal/tracing"
+	"backend-go/internal/worker"
+)
+
+type Config struct {
+	Server      Server
+	Database    Database
+	AWS         AWS
+	LLM         LLM
+	Embeddings  Embeddings
+	GitImport   GitImport
+	Log         logging.Config
+	Tracing     tracing.Config
+	Worker      worker.Config
+	Dispatch    dispatch.Config
+	Outbox      outbox.Config
+	DeadLetter  deadletter.Config
+	Idempotency idempotency.Config
+	Health      health.Config
+	Quota       Quota
+
+	// File is the config file read, if any, and EnvFiles the .env files.
+	File     string
+	EnvFiles []string
+	// PrintOnly is set by -print-config: print the config instead of
+	// starting.
+	PrintOnly bool
+
+	// sources records where each setting came from, by key
+	sources map[string]string
+}
+
+type Server struct {
+	Port string
+	// ReadHeaderTimeout and ReadTimeout bound reading a request; the latter
+	// covers the body, so it must allow for large uploads.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	// WriteTimeout bounds writing a response. Event streams lift it.
+	WriteTimeout time.Duration
+	// IdleTimeout is how long a keep-alive connection waits for the next
+	// request.
+	IdleTimeout time.Duration
+	// ShutdownTimeout bounds the whole shutdown: requests in progress,
+	// draining the workers and closing the database.
+	ShutdownTimeout time.Duration
+	// AllowedOrigins are the origins browsers may call the API from.
+	AllowedOrigins []string
+}
+
+type Database struct {
+	URL string
+}
+
+type AWS struct {
+	Region string
+	// Endpoint is where S3 and SQS are reached unless they have an endpoint
+	// of their own, LocalStack by default.
+	Endpoint          string
+	S3Endpoint        string
+	SQSEndpoint       string
+	BucketName        string
+	TaskQueueName     string
+	ResponseQueueName string
+	// TaskVisibilityTimeout is how long a task stays hidden once received.
+	// The worker extends it while summarizing, so it only bounds how soon a
+	// task comes back after the worker stopped.
+	TaskVisibilityTimeout time.Duration
+}
+
+type LLM struct {
+	URL    string
+	APIKey string
+	// AskModel answers questions about files.
+	AskModel string
+}
+
+type Embeddings struct {
+	// Provider is hash or openai.
+	Provider string
+	URL      string
+	APIKey   string
+	Model    string
+}
+
+type GitImport struct {
+	// Root is the directory repositories can be imported from. Empty
+	// disables importing.
+	Root string
+}
+
+type Quota struct {
+	// Limits are the default user limits; zero means unlimited.
+	Limits            quota.Limits
+	WorkspaceMaxBytes int64
+}
+
+// Default returns the settings used where nothing else is given.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Port:              "8080",
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       5 * time.Minute,
+			WriteTimeout:      2 * time.Minute,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   45 * time.Second,
+			AllowedOrigins:    []string{"http://localhost:3000"},
+		},
+		AWS: AWS{
+			Region:            "eu-central-1",
+			Endpoint:          "http://localhost:4566",
+			BucketName:        "file-overview-system-bucket",
+			TaskQueueName:     "task-queue",
+			ResponseQueueName: "response-queue",
+			// a few LLM calls
+			TaskVisibilityTimeout: 5 * time.Minute,
+		},
+		LLM: LLM{
+			URL:      llm.DefaultURL,
+			AskModel: ingest.DefaultModel,
+		},
+		Embeddings: Embeddings{
+			Provider: embed.ProviderHash,
+			URL:      embed.DefaultOpenAIURL,
+			Model:    embed.DefaultOpenAIModel,
+		},
+		Log:         logging.DefaultConfig,
+		Tracing:     tracing.DefaultConfig,
+		Worker:      worker.DefaultConfig,
+		Dispatch:    dispatch.DefaultConfig,
+		Outbox:      outbox.DefaultConfig,
+		DeadLetter:  deadletter.DefaultConfig,
+		Idempotency: idempotency.DefaultConfig,
+		Health:      health.DefaultConfig,
+		sources:     make(map[string]string),
+	}
+}
+
+// setting is one value of the config. Its key names it in the config file,
+// where the part before the dot is a section, and as a flag.
+type setting struct {
+	key   string
+	env   string
+	usage string
+	value flag.Value
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"server.port", "PORT", "HTTP port", stringValue{&c.Server.Port}},
+		{"server.read_header_timeout", "HTTP_READ_HEADER_TIMEOUT", "time to read request headers", durationValue{&c.Server.ReadHeaderTimeout}},
+		{"server.read_timeout", "HTTP_READ_TIMEOUT", "time to read a request, uploads included", durationValue{&c.Server.ReadTimeout}},
+		{"server.write_timeout", "HTTP_WRITE_TIMEOUT", "time to write a response; event streams are exempt", durationValue{&c.Server.WriteTimeout}},
+		{"server.idle_timeout", "HTTP_IDLE_TIMEOUT", "time a keep-alive connection waits for a request", durationValue{&c.Server.IdleTimeout}},
+		{"server.shutdown_timeout", "SHUTDOWN_TIMEOUT", "time shutdown may take", durationValue{&c.Server.ShutdownTimeout}},
+		{"server.allowed_origins", "ALLOWED_ORIGINS", "comma-separated origins allowed by CORS", listValue{&c.Server.AllowedOrigins}},
+
+		{"database.url", "DATABASE_URL", "PostgreSQL connection string", stringValue{&c.Database.URL}},
+
+		{"aws.region", "AWS_DEFAULT_REGION", "AWS region", stringValue{&c.AWS.Region}},
+		{"aws.endpoint", "LOCALSTACK_ENDPOINT", "endpoint of S3 and SQS, e.g. LocalStack", stringValue{&c.AWS.Endpoint}},
+		{"aws.s3_endpoint", "S3_ENDPOINT", "S3 endpoint, if not aws.endpoint", stringValue{&c.AWS.S3Endpoint}},
+		{"aws.sqs_endpoint", "SQS_ENDPOINT", "SQS endpoint, if not aws.endpoint", stringValue{&c.AWS.SQSEndpoint}},
+		{"aws.bucket_name", "S3_BUCKET_NAME", "S3 bucket of files and summaries", stringValue{&c.AWS.BucketName}},
+		{"aws.task_queue_name", "TASK_QUEUE_NAME", "SQS queue of tasks for the worker", stringValue{&c.AWS.TaskQueueName}},
+		{"aws.response_queue_name", "RESPONSE_QUEUE_NAME", "SQS queue of the worker's responses", stringValue{&c.AWS.ResponseQueueName}},
+		{"aws.task_visibility_timeout", "TASK_QUEUE_VISIBILITY_TIMEOUT", "time a received task stays hidden, extended while the worker summarizes it", durationValue{&c.AWS.TaskVisibilityTimeout}},
+
+		{"llm.url", "OPENROUTER_URL", "chat completions URL", stringValue{&c.LLM.URL}},
+		{"llm.api_key", "OPENROUTER_API_KEY", "chat completions API key", stringValue{&c.LLM.APIKey}},
+		{"llm.ask_model", "ASK_MODEL", "model answering questions about files", stringValue{&c.LLM.AskModel}},
+
+		{"embeddings.provider", "EMBEDDINGS_PROVIDER", "embeddings provider: hash or openai", stringValue{&c.Embeddings.Provider}},
+		{"embeddings.url", "EMBEDDINGS_URL", "OpenAI-compatible embeddings API", stringValue{&c.Embeddings.URL}},
+		{"embeddings.api_key", "EMBEDDINGS_API_KEY", "embeddings API key", stringValue{&c.Embeddings.APIKey}},
+		{"embeddings.model", "EMBEDDINGS_MODEL", "embeddings model", stringValue{&c.Embeddings.Model}},
+
+		{"git_import.root", "GIT_IMPORT_ROOT", "directory git repositories can be imported from; empty disables importing", stringValue{&c.GitImport.Root}},
+
+		{"log.level", "LOG_LEVEL", "log level: debug, info, warn or error", levelValue{&c.Log.Level}},
+		{"log.format", "LOG_FORMAT", "log format: text or json", stringValue{&c.Log.Format}},
+
+		{"tracing.exporter", "OTEL_TRACES_EXPORTER", "trace exporter: none, otlp or stdout", stringValue{&c.Tracing.Exporter}},
+		{"tracing.service_name", "OTEL_SERVICE_NAME", "service name on spans", stringValue{&c.Tracing.ServiceName}},
+
+		{"worker.concurrency", "RESPONSE_WORKER_CONCURRENCY", "responses handled at once", intValue[int]{&c.Worker.Concurrency}},
+		{"worker.visibility_timeout", "RESPONSE_WORKER_VISIBILITY_TIMEOUT", "time a response stays hidden while handled", durationValue{&c.Worker.VisibilityTimeout}},
+		{"worker.drain_timeout", "RESPONSE_WORKER_DRAIN_TIMEOUT", "time shutdown waits for responses being handled", durationValue{&c.Worker.DrainTimeout}},
+
+		{"dispatch.max_in_flight", "DISPATCH_MAX_IN_FLIGHT", "jobs in the task queue not yet finished", intValue[int]{&c.Dispatch.MaxInFlight}},
+		{"dispatch.in_flight_timeout", "DISPATCH_IN_FLIGHT_TIMEOUT", "time after which a sent job stops counting", durationValue{&c.Dispatch.InFlightTimeout}},
+
+		{"outbox.max_backoff", "OUTBOX_MAX_BACKOFF", "longest wait before retrying a rejected message", durationValue{&c.Outbox.MaxBackoff}},
+		{"outbox.retention", "OUTBOX_RETENTION", "time sent messages are kept", durationValue{&c.Outbox.Retention}},
+
+		{"dead_letter.max_receive_count", "SQS_MAX_RECEIVE_COUNT", "receives before a message is dead-lettered", intValue[int]{&c.DeadLetter.MaxReceiveCount}},
+		{"dead_letter.sweep_interval", "DEADLETTER_SWEEP_INTERVAL", "how often dead letters are quarantined", durationValue{&c.DeadLetter.Interval}},
+
+		{"idempotency.ttl", "IDEMPOTENCY_TTL", "time an Idempotency-Key is kept", durationValue{&c.Idempotency.TTL}},
+
+		{"health.check_timeout", "HEALTH_CHECK_TIMEOUT", "timeout of each readiness check", durationValue{&c.Health.Timeout}},
+		{"health.cache_ttl", "HEALTH_CACHE_TTL", "time a health report is reused", durationValue{&c.Health.CacheTTL}},
+
+		{"quota.max_bytes", "QUOTA_MAX_BYTES", "bytes stored per user; 0 is unlimited", intValue[int64]{&c.Quota.Limits.MaxBytes}},
+		{"quota.uploads_per_day", "QUOTA_UPLOADS_PER_DAY", "uploads per user and day; 0 is unlimited", intValue[int32]{&c.Quota.Limits.UploadsPerDay}},
+		{"quota.tokens_per_month", "QUOTA_TOKENS_PER_MONTH", "tokens per user and month; 0 is unlimited", intValue[int64]{&c.Quota.Limits.TokensPerMonth}},
+		{"quota.cost_per_month", "QUOTA_COST_PER_MONTH", "cost per user and month; 0 is unlimited", floatValue{&c.Quota.Limits.CostPerMonth}},
+		{"quota.pending_jobs", "QUOTA_PENDING_JOBS", "unfinished jobs per user; 0 is unlimited", intValue[int32]{&c.Quota.Limits.PendingJobs}},
+		{"quota.workspace_max_bytes", "QUOTA_WORKSPACE_MAX_BYTES", "bytes stored per workspace; 0 is unlimited", intValue[int64]{&c.Quota.WorkspaceMaxBytes}},
+	}
+}
+
+// resolve fills in the settings that follow from others.
+func (c *Config) resolve() {
+	if c.AWS.S3Endpoint == "" {
+		c.AWS.S3Endpoint = c.AWS.Endpoint
+	}
+	if c.AWS.SQSEndpoint == "" {
+		c.AWS.SQSEndpoint = c.AWS.Endpoint
+	}
+	c.Worker.HeartbeatInterval = c.Worker.VisibilityTimeout / 3
+	c.Log.Format = strings.ToLower(c.Log.Format)
+}
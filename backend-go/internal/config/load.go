@@ -0,0 +1,199 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// DefaultEnvFiles are loaded when no -env-file is given, if they exist: the
+// .env shared with the worker, then the backend's own.
+var DefaultEnvFiles = []string{".env", "backend-go.env"}
+
+// Where a setting came from, weakest first.
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+// Load reads the settings from, in increasing precedence, the defaults, the
+// config file named by -config or CONFIG_FILE, the environment and the
+// flags in args. The .env files are loaded into the environment first; they
+// don't override variables that are already set. Errors in any of them, and
+// settings that are invalid, are all returned together.
+//
+// A config file is YAML or TOML, told apart by its extension, with one
+// section per part of the key before the dot:
+//
+//	server:
+//	  port: 8080
+//	  allowed_origins: [http://localhost:3000]
+func Load(args []string) (*Config, error) {
+	c := Default()
+	settings := c.settings()
+
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	file := flags.String("config", "", "YAML or TOML config `file` (env CONFIG_FILE)")
+	envFiles := DefaultEnvFiles
+	explicit := false
+	flags.Func("env-file", "load variables from `file` if not set, repeatable; empty loads none (default "+strings.Join(DefaultEnvFiles, ", ")+")", func(s string) error {
+		if !explicit {
+			envFiles, explicit = nil, true
+		}
+		if s != "" {
+			envFiles = append(envFiles, s)
+		}
+		return nil
+	})
+	flags.BoolVar(&c.PrintOnly, "print-config", false, "print the effective config, secrets redacted, and exit")
+	for _, s := range settings {
+		// only recorded here; set in order of precedence below
+		flags.String(s.key, s.value.String(), s.usage+" (env "+s.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	var errs []error
+	for _, path := range envFiles {
+		err := godotenv.Load(path)
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c.EnvFiles = append(c.EnvFiles, path)
+	}
+
+	if *file == "" {
+		*file = os.Getenv("CONFIG_FILE")
+	}
+	if *file != "" {
+		c.File = *file
+		errs = append(errs, c.loadFile(*file, settings)...)
+	}
+
+	for _, s := range settings {
+		// empty counts as unset, as it always has
+		v := os.Getenv(s.env)
+		if v == "" {
+			continue
+		}
+		if err := s.value.Set(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid value %q: %w", s.env, v, err))
+			continue
+		}
+		c.sources[s.key] = sourceEnv + " " + s.env
+	}
+
+	byKey := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+	flags.Visit(func(f *flag.Flag) {
+		s, ok := byKey[f.Name]
+		if !ok {
+			return
+		}
+		v := f.Value.String()
+		if err := s.value.Set(v); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: invalid value %q: %w", s.key, v, err))
+			return
+		}
+		c.sources[s.key] = sourceFlag
+	})
+
+	c.resolve()
+	if err := c.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// loadFile sets the settings in the config file at path.
+func (c *Config) loadFile(path string, settings []setting) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+	var doc map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return []error{fmt.Errorf("%s: config file must be .yaml, .yml or .toml", path)}
+	}
+	if err != nil {
+		return []error{fmt.Errorf("%s: %w", path, err)}
+	}
+
+	values := make(map[string]string)
+	flatten("", doc, values)
+	var errs []error
+	for _, s := range settings {
+		v, ok := values[s.key]
+		if !ok {
+			continue
+		}
+		delete(values, s.key)
+		if err := s.value.Set(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: invalid value %q: %w", path, s.key, v, err))
+			continue
+		}
+		c.sources[s.key] = sourceFile
+	}
+	unknown := make([]string, 0, len(values))
+	for key := range values {
+		unknown = append(unknown, key)
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("%s: unknown setting %s", path, key))
+	}
+	return errs
+}
+
+// flatten turns the sections of a config file into values by key, with
+// lists joined by commas. Empty values are left out.
+func flatten(prefix string, doc map[string]any, values map[string]string) {
+	for name, v := range doc {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		switch v := v.(type) {
+		case nil:
+		case map[string]any:
+			flatten(key, v, values)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
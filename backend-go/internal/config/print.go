@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"backend-go/internal/logging"
+)
+
+// Print writes every setting with its value and where it came from. Secret
+// values are redacted, as are passwords in URLs.
+func (c *Config) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if c.File != "" {
+		fmt.Fprintf(tw, "# config file %s\n", c.File)
+	}
+	for _, path := range c.EnvFiles {
+		fmt.Fprintf(tw, "# env file %s\n", path)
+	}
+	for _, s := range c.settings() {
+		v := s.value.String()
+		switch {
+		case v == "":
+			v = `""`
+		case logging.IsSecret(s.env):
+			v = logging.Redacted
+		default:
+			v = logging.Redact(v)
+		}
+		source := c.sources[s.key]
+		if source == "" {
+			source = sourceDefault
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.key, v, source)
+	}
+	return tw.Flush()
+}
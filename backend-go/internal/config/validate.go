@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"backend-go/internal/embed"
+	"backend-go/internal/logging"
+	"backend-go/internal/tracing"
+)
+
+// Validate reports every setting that is invalid, not just the first.
+func (c *Config) Validate() error {
+	v := validator{env: make(map[string]string)}
+	for _, s := range c.settings() {
+		v.env[s.key] = s.env
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		v.fail("server.port", "must be a port number")
+	}
+	v.positive("server.read_header_timeout", c.Server.ReadHeaderTimeout)
+	v.positive("server.read_timeout", c.Server.ReadTimeout)
+	v.positive("server.write_timeout", c.Server.WriteTimeout)
+	v.positive("server.idle_timeout", c.Server.IdleTimeout)
+	v.positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+	if len(c.Server.AllowedOrigins) == 0 {
+		v.fail("server.allowed_origins", "is required")
+	}
+	for _, origin := range c.Server.AllowedOrigins {
+		// compared as is with the Origin header, which has no path; a
+		// wildcard is no use either, browsers reject it with credentials
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			v.fail("server.allowed_origins", fmt.Sprintf("%q is not an origin such as https://example.com", origin))
+		}
+	}
+
+	v.required("database.url", c.Database.URL)
+
+	v.required("aws.region", c.AWS.Region)
+	v.url("aws.s3_endpoint", c.AWS.S3Endpoint)
+	v.url("aws.sqs_endpoint", c.AWS.SQSEndpoint)
+	v.required("aws.bucket_name", c.AWS.BucketName)
+	v.required("aws.task_queue_name", c.AWS.TaskQueueName)
+	v.required("aws.response_queue_name", c.AWS.ResponseQueueName)
+	if c.AWS.TaskQueueName != "" && c.AWS.TaskQueueName == c.AWS.ResponseQueueName {
+		v.fail("aws.response_queue_name", "must differ from aws.task_queue_name")
+	}
+	// SQS takes whole seconds up to 12 hours; the worker extends it every
+	// minute
+	if c.AWS.TaskVisibilityTimeout < 2*time.Minute || c.AWS.TaskVisibilityTimeout > 12*time.Hour {
+		v.fail("aws.task_visibility_timeout", "must be between 2m and 12h")
+	}
+
+	v.url("llm.url", c.LLM.URL)
+	v.required("llm.ask_model", c.LLM.AskModel)
+
+	switch c.Embeddings.Provider {
+	case embed.ProviderHash:
+	case embed.ProviderOpenAI:
+		v.url("embeddings.url", c.Embeddings.URL)
+		v.required("embeddings.model", c.Embeddings.Model)
+	default:
+		v.fail("embeddings.provider", "must be hash or openai")
+	}
+
+	switch c.Log.Format {
+	case logging.FormatText, logging.FormatJSON:
+	default:
+		v.fail("log.format", "must be text or json")
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout:
+	default:
+		v.fail("tracing.exporter", "must be none, otlp or stdout")
+	}
+	v.required("tracing.service_name", c.Tracing.ServiceName)
+
+	if c.Worker.Concurrency < 1 {
+		v.fail("worker.concurrency", "must be at least 1")
+	}
+	// SQS takes whole seconds up to 12 hours, and a third of it is the
+	// heartbeat
+	if c.Worker.VisibilityTimeout < 3*time.Second || c.Worker.VisibilityTimeout > 12*time.Hour {
+		v.fail("worker.visibility_timeout", "must be between 3s and 12h")
+	}
+	v.positive("worker.drain_timeout", c.Worker.DrainTimeout)
+
+	if c.Dispatch.MaxInFlight < 1 {
+		v.fail("dispatch.max_in_flight", "must be at least 1")
+	}
+	v.positive("dispatch.in_flight_timeout", c.Dispatch.InFlightTimeout)
+
+	if c.Outbox.MaxBackoff < c.Outbox.MinBackoff {
+		v.fail("outbox.max_backoff", "must be at least "+c.Outbox.MinBackoff.String())
+	}
+	v.positive("outbox.retention", c.Outbox.Retention)
+
+	if c.DeadLetter.MaxReceiveCount < 1 || c.DeadLetter.MaxReceiveCount > 1000 {
+		v.fail("dead_letter.max_receive_count", "must be between 1 and 1000")
+	}
+	v.positive("dead_letter.sweep_interval", c.DeadLetter.Interval)
+
+	v.positive("idempotency.ttl", c.Idempotency.TTL)
+
+	v.positive("health.check_timeout", c.Health.Timeout)
+	if c.Health.CacheTTL < 0 {
+		v.fail("health.cache_ttl", "must not be negative")
+	}
+
+	for _, q := range []struct {
+		key string
+		n   float64
+	}{
+		{"quota.max_bytes", float64(c.Quota.Limits.MaxBytes)},
+		{"quota.uploads_per_day", float64(c.Quota.Limits.UploadsPerDay)},
+		{"quota.tokens_per_month", float64(c.Quota.Limits.TokensPerMonth)},
+		{"quota.cost_per_month", c.Quota.Limits.CostPerMonth},
+		{"quota.pending_jobs", float64(c.Quota.Limits.PendingJobs)},
+		{"quota.workspace_max_bytes", float64(c.Quota.WorkspaceMaxBytes)},
+	} {
+		if q.n < 0 {
+			v.fail(q.key, "must not be negative")
+		}
+	}
+
+	return errors.Join(v.errs...)
+}
+
+type validator struct {
+	env  map[string]string
+	errs []error
+}
+
+func (v *validator) fail(key string, msg string) {
+	v.errs = append(v.errs, fmt.Errorf("%s (%s) %s", key, v.env[key], msg))
+}
+
+func (v *validator) required(key string, s string) {
+	if s == "" {
+		v.fail(key, "is required")
+	}
+}
+
+func (v *validator) positive(key string, d time.Duration) {
+	if d <= 0 {
+		v.fail(key, "must be positive")
+	}
+}
+
+func (v *validator) url(key string, s string) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.fail(key, "must be an http or https URL")
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The values below parse a setting from the file, the environment or a
+// flag; whether the result makes sense is left to Validate.
+
+type stringValue struct{ p *string }
+
+func (v stringValue) Set(s string) error {
+	*v.p = s
+	return nil
+}
+
+func (v stringValue) String() string { return *v.p }
+
+// listValue is a comma-separated list. Blank items are dropped.
+type listValue struct{ p *[]string }
+
+func (v listValue) Set(s string) error {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*v.p = items
+	return nil
+}
+
+func (v listValue) String() string { return strings.Join(*v.p, ",") }
+
+type intValue[T int | int32 | int64] struct{ p *T }
+
+func (v intValue[T]) Set(s string) error {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || int64(T(n)) != n {
+		return fmt.Errorf("not an integer in range")
+	}
+	*v.p = T(n)
+	return nil
+}
+
+func (v intValue[T]) String() string { return strconv.FormatInt(int64(*v.p), 10) }
+
+type floatValue struct{ p *float64 }
+
+func (v floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return fmt.Errorf("not a number")
+	}
+	*v.p = f
+	return nil
+}
+
+func (v floatValue) String() string { return strconv.FormatFloat(*v.p, 'f', -1, 64) }
+
+type durationValue struct{ p *time.Duration }
+
+func (v durationValue) Set(s string) error {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("not a duration such as 30s or 5m")
+	}
+	*v.p = d
+	return nil
+}
+
+func (v durationValue) String() string { return v.p.String() }
+
+type levelValue struct{ p *slog.Level }
+
+func (v levelValue) Set(s string) error {
+	if err := v.p.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return fmt.Errorf("not debug, info, warn or error")
+	}
+	return nil
+}
+
+func (v levelValue) String() string { return strings.ToLower(v.p.String()) }
@@ -0,0 +1,31 @@
+-- name: CreateAskThread :one
+INSERT INTO ask_threads (document_id, user_id, title)
+VALUES ($1, $2, $3)
+RETURNING id, document_id, user_id, title, created_at, updated_at;
+
+-- name: GetAskThread :one
+SELECT id, document_id, user_id, title, created_at, updated_at
+FROM ask_threads
+WHERE id = $1 AND document_id = $2 AND user_id = $3;
+
+-- name: ListAskThreads :many
+SELECT id, document_id, user_id, title, created_at, updated_at
+FROM ask_threads
+WHERE document_id = $1 AND user_id = $2
+ORDER BY updated_at DESC;
+
+-- name: TouchAskThread :exec
+UPDATE ask_threads
+SET updated_at = current_timestamp
+WHERE id = $1;
+
+-- name: CreateAskMessage :one
+INSERT INTO ask_messages (thread_id, role, content, citations)
+VALUES ($1, $2, $3, $4)
+RETURNING id, thread_id, role, content, citations, created_at;
+
+-- name: ListAskMessages :many
+SELECT id, thread_id, role, content, citations, created_at
+FROM ask_messages
+WHERE thread_id = $1
+ORDER BY id;
\ No newline at end of file
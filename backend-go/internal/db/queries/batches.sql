@@ -0,0 +1,25 @@
+-- name: CreateBatch :one
+INSERT INTO batches (user_id, name)
+VALUES ($1, $2)
+RETURNING id, user_id, name, status, total, completed, failed, created_at, completed_at;
+
+-- name: SealBatch :exec
+UPDATE batches
+SET status = 'sealed', total = $2, completed = completed + $3
+WHERE id = $1;
+
+-- name: IncrementBatchCompleted :exec
+UPDATE batches
+SET completed = completed + 1
+WHERE id = $1;
+
+-- name: IncrementBatchFailed :exec
+UPDATE batches
+SET failed = failed + 1
+WHERE id = $1;
+
+-- name: FinishBatch :one
+UPDATE batches
+SET status = 'completed', completed_at = current_timestamp
+WHERE id = $1 AND status = 'sealed' AND completed + failed >= total
+RETURNING id, user_id, name, status, total, completed, failed, created_at, completed_at;
@@ -0,0 +1,31 @@
+-- name: LockBlob :exec
+SELECT pg_advisory_xact_lock(hashtextextended($1, 0));
+
+-- name: AcquireBlob :one
+UPDATE blobs
+SET ref_count = ref_count + 1
+WHERE hash = $1
+RETURNING hash, size, storage_key, ref_count, created_at;
+
+-- name: InsertBlob :one
+INSERT INTO blobs (hash, size, storage_key, ref_count)
+VALUES ($1, $2, $3, 1)
+ON CONFLICT (hash) DO UPDATE SET ref_count = blobs.ref_count + 1
+RETURNING hash, size, storage_key, ref_count, created_at;
+
+-- name: ReleaseBlob :one
+UPDATE blobs
+SET ref_count = ref_count - 1
+WHERE hash = $1
+RETURNING hash, size, storage_key, ref_count, created_at;
+
+-- name: DeleteUnreferencedBlob :one
+DELETE
+FROM blobs
+WHERE hash = $1 AND ref_count <= 0
+RETURNING storage_key;
+
+-- name: GetBlob :one
+SELECT hash, size, storage_key, ref_count, created_at
+FROM blobs
+WHERE hash = $1;
\ No newline at end of file
@@ -0,0 +1,64 @@
+-- name: DeleteDocumentChunks :exec
+DELETE
+FROM document_chunks
+WHERE document_id = $1 AND model = $2;
+
+-- name: InsertDocumentChunk :one
+INSERT INTO document_chunks (document_id, chunk_index, heading, start_line, end_line, content, content_hash, model, embedding)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id;
+
+-- name: ListChunkEmbeddings :many
+SELECT id, document_id, embedding
+FROM document_chunks
+WHERE model = $1;
+
+-- name: ListDocumentChunks :many
+SELECT id, chunk_index, heading, start_line, end_line, content, embedding
+FROM document_chunks
+WHERE document_id = $1 AND model = $2 AND content_hash = $3
+ORDER BY chunk_index;
+
+-- name: ListDocumentEmbeddings :many
+SELECT embedding
+FROM document_chunks
+WHERE document_id = $1 AND model = $2;
+
+-- name: ListDocumentsToEmbed :many
+SELECT d.id
+FROM documents d
+WHERE NOT EXISTS (
+    SELECT 1 FROM document_chunks c
+    WHERE c.document_id = d.id AND c.model = $1 AND c.content_hash = d.blob_hash
+)
+ORDER BY d.id;
+
+-- name: HasCurrentChunks :one
+SELECT EXISTS (
+    SELECT 1 FROM document_chunks
+    WHERE document_id = $1 AND model = $2 AND content_hash = $3
+);
+
+-- name: ListChunkHits :many
+SELECT c.id, c.document_id, c.heading, c.start_line, c.end_line, c.content, d.path
+FROM document_chunks c
+JOIN documents d ON d.id = c.document_id
+WHERE c.id = ANY(@ids::int[]);
+
+-- name: HasVectorExtension :one
+SELECT EXISTS (
+    SELECT 1 FROM pg_extension WHERE extname = 'vector'
+);
+
+-- name: SearchChunksByVector :many
+SELECT c.id, c.document_id,
+       (1 - (c.embedding::vector <=> @query::real[]::vector))::real AS score
+FROM document_chunks c
+JOIN documents d ON d.id = c.document_id
+WHERE c.model = @model
+  AND c.document_id <> @exclude_id
+  AND (d.user_id = @user_id OR EXISTS (
+      SELECT 1 FROM workspace_members m WHERE m.workspace_id = d.workspace_id AND m.user_id = @user_id
+  ))
+ORDER BY c.embedding::vector <=> @query::real[]::vector
+LIMIT @row_limit;
\ No newline at end of file
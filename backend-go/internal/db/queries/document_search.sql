@@ -0,0 +1,44 @@
+-- name: IndexDocumentContent :exec
+INSERT INTO document_search (document_id, title, content, summary)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (document_id) DO UPDATE
+SET title = EXCLUDED.title, content = EXCLUDED.content, summary = EXCLUDED.summary;
+
+-- name: IndexDocumentSummary :exec
+INSERT INTO document_search (document_id, title, summary)
+SELECT id, path, @summary::text FROM documents WHERE id = @document_id
+ON CONFLICT (document_id) DO UPDATE
+SET summary = EXCLUDED.summary;
+
+-- name: SearchDocuments :many
+WITH matches AS (
+    SELECT d.id, d.path, d.status, d.summary_preview, d.workspace_id, d.created_at,
+           s.content, s.summary, q.query,
+           ts_rank_cd(s.search_vector, q.query)::real AS rank
+    FROM document_search s
+    JOIN documents d ON d.id = s.document_id
+    CROSS JOIN to_tsquery('english', @query::text) AS q(query)
+    WHERE s.search_vector @@ q.query
+      AND (d.user_id = @user_id OR EXISTS (
+          SELECT 1 FROM workspace_members m WHERE m.workspace_id = d.workspace_id AND m.user_id = @user_id
+      ))
+      AND (sqlc.narg('workspace_id')::int IS NULL OR d.workspace_id = sqlc.narg('workspace_id'))
+      AND (sqlc.narg('tag')::text IS NULL OR EXISTS (
+          SELECT 1 FROM document_tags t WHERE t.document_id = d.id AND t.tag = sqlc.narg('tag')
+      ))
+      AND (sqlc.narg('created_from')::timestamp IS NULL OR d.created_at >= sqlc.narg('created_from'))
+      AND (sqlc.narg('created_to')::timestamp IS NULL OR d.created_at < sqlc.narg('created_to'))
+),
+page AS (
+    SELECT *
+    FROM matches
+    WHERE sqlc.narg('cursor_rank')::real IS NULL
+       OR (rank, id) < (sqlc.narg('cursor_rank'), sqlc.narg('cursor_id')::int)
+    ORDER BY rank DESC, id DESC
+    LIMIT @page_size
+)
+SELECT id, path, status, summary_preview, workspace_id, created_at, rank,
+       ts_headline('english', content, query, 'MaxFragments=2, MaxWords=30, MinWords=10, StartSel=<mark>, StopSel=</mark>')::text AS snippet,
+       ts_headline('english', summary, query, 'MaxWords=30, MinWords=10, StartSel=<mark>, StopSel=</mark>')::text AS summary_snippet
+FROM page
+ORDER BY rank DESC, id DESC;
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- name: AddDocumentTag :exec
+INSERT INTO document_tags (document_id, tag)
+VALUES ($1, $2)
+ON CONFLICT (document_id, tag) DO NOTHING;
+
+-- name: DeleteDocumentTags :exec
+DELETE
+FROM document_tags
+WHERE document_id = $1;
+
+-- name: ListDocumentTags :many
+SELECT tag
+FROM document_tags
+WHERE document_id = $1
+ORDER BY tag;
\ No newline at end of file
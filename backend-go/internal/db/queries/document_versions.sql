@@ -0,0 +1,10 @@
+-- name: CreateDocumentVersion :one
+INSERT INTO document_versions (document_id, blob_hash, commit_sha)
+VALUES ($1, $2, $3)
+RETURNING id, document_id, blob_hash, commit_sha, created_at;
+
+-- name: ListDocumentVersions :many
+SELECT id, document_id, blob_hash, commit_sha, created_at
+FROM document_versions
+WHERE document_id = $1
+ORDER BY created_at DESC;
\ No newline at end of file
@@ -0,0 +1,112 @@
+-- name: CreateDocument :one
+INSERT INTO documents (user_id, path, blob_hash, size, status, summary_key, repository_id, source_sha, summary_preview)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, user_id, path, blob_hash, size, status, summary_key, created_at, updated_at, repository_id, source_sha, summary_preview, workspace_id;
+
+-- name: GetDocument :one
+SELECT id, user_id, path, blob_hash, size, status, summary_key, created_at, updated_at, repository_id, source_sha, summary_preview, workspace_id
+FROM documents
+WHERE id = $1;
+
+-- name: GetReadableDocument :one
+SELECT d.id, d.user_id, d.path, d.blob_hash, d.size, d.status, d.summary_key, d.created_at, d.updated_at, d.repository_id, d.source_sha, d.summary_preview, d.workspace_id
+FROM documents d
+WHERE d.id = $1
+  AND (d.user_id = $2 OR EXISTS (
+      SELECT 1 FROM workspace_members m WHERE m.workspace_id = d.workspace_id AND m.user_id = $2
+  ));
+
+-- name: ListReadableDocumentIDs :many
+SELECT d.id
+FROM documents d
+WHERE d.user_id = $1 OR EXISTS (
+    SELECT 1 FROM workspace_members m WHERE m.workspace_id = d.workspace_id AND m.user_id = $1
+);
+
+-- name: SetDocumentWorkspace :execrows
+UPDATE documents
+SET workspace_id = $3, updated_at = current_timestamp
+WHERE id = $1 AND user_id = $2;
+
+-- name: UpdateDocumentContent :one
+UPDATE documents
+SET blob_hash = $2, size = $3, status = $4, summary_key = $5, source_sha = $6, summary_preview = $7, updated_at = current_timestamp
+WHERE id = $1
+RETURNING id, user_id, path, blob_hash, size, status, summary_key, created_at, updated_at, repository_id, source_sha, summary_preview, workspace_id;
+
+-- name: CompleteDocument :execrows
+UPDATE documents
+SET status = 'completed', summary_key = @summary_key, summary_preview = @summary_preview, updated_at = current_timestamp
+WHERE id = @id
+  AND NOT EXISTS (SELECT 1 FROM jobs j WHERE j.document_id = documents.id AND j.id > @job_id);
+
+-- name: SetDocumentStatus :execrows
+UPDATE documents
+SET status = @status, updated_at = current_timestamp
+WHERE id = @id
+  AND NOT EXISTS (SELECT 1 FROM jobs j WHERE j.document_id = documents.id AND j.id > @job_id);
+
+-- name: DeleteDocument :one
+DELETE
+FROM documents
+WHERE id = $1 AND user_id = $2
+RETURNING blob_hash, size, status;
+
+-- name: ListDocumentsByUser :many
+SELECT id, user_id, path, blob_hash, size, status, summary_key, created_at, updated_at, repository_id, source_sha, summary_preview, workspace_id
+FROM documents
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: ListRepositoryDocuments :many
+SELECT id, user_id, path, blob_hash, size, status, summary_key, created_at, updated_at, repository_id, source_sha, summary_preview, workspace_id
+FROM documents
+WHERE repository_id = $1;
+
+-- name: ListDocumentsPage :many
+WITH page AS (
+    SELECT d.id, d.path, d.size, d.status, d.summary_preview, d.created_at, d.updated_at,
+           coalesce(j.status, '')::text AS job_status,
+           coalesce((SELECT array_agg(t.tag ORDER BY t.tag) FROM document_tags t WHERE t.document_id = d.id), '{}')::text[] AS tags,
+           (CASE @sort_by::text
+                WHEN 'name' THEN lower(d.path)
+                WHEN 'size' THEN lpad(d.size::text, 20, '0')
+                ELSE to_char(d.created_at, 'YYYYMMDDHH24MISSUS')
+            END)::text AS sort_key
+    FROM documents d
+    LEFT JOIN LATERAL (
+        SELECT status FROM jobs WHERE document_id = d.id ORDER BY id DESC LIMIT 1
+    ) j ON true
+    WHERE d.user_id = @user_id
+      AND (sqlc.narg('status')::text IS NULL OR d.status = sqlc.narg('status'))
+      AND (sqlc.narg('tag')::text IS NULL OR EXISTS (
+          SELECT 1 FROM document_tags t WHERE t.document_id = d.id AND t.tag = sqlc.narg('tag')
+      ))
+      AND (sqlc.narg('folder')::text IS NULL OR starts_with(d.path, sqlc.narg('folder') || '/'))
+      AND (sqlc.narg('created_from')::timestamp IS NULL OR d.created_at >= sqlc.narg('created_from'))
+      AND (sqlc.narg('created_to')::timestamp IS NULL OR d.created_at < sqlc.narg('created_to'))
+)
+SELECT id, path, size, status, summary_preview, created_at, updated_at, job_status, tags, sort_key
+FROM page
+WHERE sqlc.narg('cursor_key')::text IS NULL
+   OR (@descending::boolean AND (sort_key, id) < (sqlc.narg('cursor_key'), sqlc.narg('cursor_id')::int))
+   OR (NOT @descending::boolean AND (sort_key, id) > (sqlc.narg('cursor_key'), sqlc.narg('cursor_id')::int))
+ORDER BY
+    CASE WHEN @descending::boolean THEN sort_key END DESC,
+    CASE WHEN @descending::boolean THEN id END DESC,
+    sort_key ASC,
+    id ASC
+LIMIT @page_size;
+
+-- name: ListStalledDocuments :many
+SELECT id, user_id, path, blob_hash, size, status, summary_key, created_at, updated_at, repository_id, source_sha, summary_preview, workspace_id
+FROM documents d
+WHERE d.status = 'pending'
+  AND d.summary_key IS NULL
+  AND d.updated_at < current_timestamp - make_interval(secs => @grace_seconds::float8)
+  AND NOT EXISTS (
+    SELECT 1 FROM jobs j
+    WHERE j.document_id = d.id AND j.status IN ('queued', 'running')
+  )
+ORDER BY d.id
+LIMIT @max_documents;
\ No newline at end of file
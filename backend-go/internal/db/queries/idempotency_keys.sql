@@ -0,0 +1,36 @@
+-- name: ClaimIdempotencyKey :one
+INSERT INTO idempotency_keys (user_id, key, fingerprint, expires_at)
+VALUES (@user_id, @key, @fingerprint, current_timestamp + make_interval(secs => @ttl_seconds::float8))
+ON CONFLICT (user_id, key) DO UPDATE
+SET fingerprint = EXCLUDED.fingerprint,
+    status = 'processing',
+    response_status = NULL,
+    response_body = NULL,
+    content_type = NULL,
+    created_at = current_timestamp,
+    expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at < current_timestamp
+   OR (idempotency_keys.status = 'processing'
+       AND idempotency_keys.fingerprint = EXCLUDED.fingerprint
+       AND idempotency_keys.created_at < current_timestamp - make_interval(secs => @lock_seconds::float8))
+RETURNING user_id, key, fingerprint, status, response_status, response_body, content_type, created_at, expires_at;
+
+-- name: GetIdempotencyKey :one
+SELECT user_id, key, fingerprint, status, response_status, response_body, content_type, created_at, expires_at
+FROM idempotency_keys
+WHERE user_id = $1 AND key = $2;
+
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys
+SET status = 'completed', response_status = $3, response_body = $4, content_type = $5
+WHERE user_id = $1 AND key = $2;
+
+-- name: DeleteIdempotencyKey :exec
+DELETE
+FROM idempotency_keys
+WHERE user_id = $1 AND key = $2;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE
+FROM idempotency_keys
+WHERE expires_at < current_timestamp;
\ No newline at end of file
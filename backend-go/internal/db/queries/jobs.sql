@@ -0,0 +1,176 @@
+-- name: CreateJob :one
+INSERT INTO jobs (document_id, user_id, content_hash, model, prompt_version, batch_id, template_id, template_version, format, lane, trace_context)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+RETURNING id, document_id, user_id, content_hash, model, prompt_version, status, error, created_at, updated_at, completed_at, batch_id, template_id, template_version, format, prompt_tokens, completion_tokens, latency_ms, cost, lane, priority, task, dispatched_at, trace_context;
+
+-- name: GetJob :one
+SELECT id, document_id, user_id, content_hash, model, prompt_version, status, error, created_at, updated_at, completed_at, batch_id, template_id, template_version, format, prompt_tokens, completion_tokens, latency_ms, cost, lane, priority, task, dispatched_at, trace_context
+FROM jobs
+WHERE id = $1;
+
+-- name: StartJob :exec
+UPDATE jobs
+SET status = 'running', updated_at = current_timestamp
+WHERE id = $1 AND status = 'queued';
+
+-- name: CompleteJob :execrows
+UPDATE jobs
+SET status = 'completed', updated_at = current_timestamp, completed_at = current_timestamp
+WHERE id = $1 AND status IN ('queued', 'running');
+
+-- name: FailJob :execrows
+UPDATE jobs
+SET status = 'failed', error = $2, updated_at = current_timestamp, completed_at = current_timestamp
+WHERE id = $1 AND status IN ('queued', 'running');
+
+-- name: RecordJobUsage :exec
+UPDATE jobs
+SET prompt_tokens = $2, completion_tokens = $3, latency_ms = $4, cost = $5, updated_at = current_timestamp
+WHERE id = $1;
+
+-- name: GetUserUsage :many
+SELECT j.model,
+       count(*) AS jobs,
+       coalesce(sum(j.prompt_tokens), 0)::bigint AS prompt_tokens,
+       coalesce(sum(j.completion_tokens), 0)::bigint AS completion_tokens,
+       coalesce(sum(j.cost), 0)::float8 AS cost,
+       coalesce(avg(j.latency_ms) FILTER (WHERE j.latency_ms > 0), 0)::float8 AS avg_latency_ms
+FROM jobs j
+WHERE j.user_id = @user_id
+  AND (sqlc.narg('created_from')::timestamp IS NULL OR j.created_at >= sqlc.narg('created_from'))
+  AND (sqlc.narg('created_to')::timestamp IS NULL OR j.created_at < sqlc.narg('created_to'))
+GROUP BY j.model
+ORDER BY j.model;
+
+-- name: GetWorkspaceUsage :many
+SELECT j.model,
+       count(*) AS jobs,
+       coalesce(sum(j.prompt_tokens), 0)::bigint AS prompt_tokens,
+       coalesce(sum(j.completion_tokens), 0)::bigint AS completion_tokens,
+       coalesce(sum(j.cost), 0)::float8 AS cost,
+       coalesce(avg(j.latency_ms) FILTER (WHERE j.latency_ms > 0), 0)::float8 AS avg_latency_ms
+FROM jobs j
+JOIN documents d ON d.id = j.document_id
+WHERE d.workspace_id = @workspace_id
+  AND (sqlc.narg('created_from')::timestamp IS NULL OR j.created_at >= sqlc.narg('created_from'))
+  AND (sqlc.narg('created_to')::timestamp IS NULL OR j.created_at < sqlc.narg('created_to'))
+GROUP BY j.model
+ORDER BY j.model;
+
+-- name: GetWorkspaceUsageByUser :many
+SELECT u.id AS user_id, u.username,
+       count(*) AS jobs,
+       coalesce(sum(j.prompt_tokens), 0)::bigint AS prompt_tokens,
+       coalesce(sum(j.completion_tokens), 0)::bigint AS completion_tokens,
+       coalesce(sum(j.cost), 0)::float8 AS cost
+FROM jobs j
+JOIN documents d ON d.id = j.document_id
+JOIN users u ON u.id = j.user_id
+WHERE d.workspace_id = @workspace_id
+  AND (sqlc.narg('created_from')::timestamp IS NULL OR j.created_at >= sqlc.narg('created_from'))
+  AND (sqlc.narg('created_to')::timestamp IS NULL OR j.created_at < sqlc.narg('created_to'))
+GROUP BY u.id, u.username
+ORDER BY u.username;
+
+-- name: SetJobTask :exec
+UPDATE jobs
+SET task = $2, updated_at = current_timestamp
+WHERE id = $1;
+
+-- name: ListDispatchHeads :many
+SELECT DISTINCT ON (lane, user_id) id, user_id, lane, priority
+FROM jobs
+WHERE dispatched_at IS NULL AND status = 'queued' AND task IS NOT NULL
+ORDER BY lane, user_id, priority DESC, id;
+
+-- name: ClaimJob :one
+UPDATE jobs
+SET dispatched_at = current_timestamp, updated_at = current_timestamp
+WHERE id = $1 AND dispatched_at IS NULL AND status = 'queued'
+RETURNING task;
+
+-- name: UnclaimJob :exec
+UPDATE jobs
+SET dispatched_at = NULL, updated_at = current_timestamp
+WHERE id = $1 AND status = 'queued';
+
+-- name: CountInFlightJobs :one
+SELECT count(*)
+FROM jobs
+WHERE dispatched_at >= current_timestamp - make_interval(secs => @timeout_seconds::float8)
+  AND status IN ('queued', 'running');
+
+-- name: SetJobPriority :execrows
+UPDATE jobs
+SET priority = $2, lane = $3, updated_at = current_timestamp
+WHERE id = $1 AND dispatched_at IS NULL AND status = 'queued';
+
+-- name: GetLaneStats :many
+SELECT lane, count(*) AS waiting, count(DISTINCT user_id) AS users
+FROM jobs
+WHERE dispatched_at IS NULL AND status = 'queued'
+GROUP BY lane
+ORDER BY lane;
+
+-- name: ListWaitingJobs :many
+SELECT j.id, j.user_id, u.username, j.document_id, j.lane, j.priority, j.created_at
+FROM jobs j
+JOIN users u ON u.id = j.user_id
+WHERE j.dispatched_at IS NULL AND j.status = 'queued'
+ORDER BY j.priority DESC, j.id
+LIMIT $1;
+
+-- name: CancelJob :one
+UPDATE jobs
+SET status = 'cancelled', updated_at = current_timestamp, completed_at = current_timestamp
+WHERE id = $1 AND user_id = $2 AND status IN ('queued', 'running')
+RETURNING id, document_id, user_id, content_hash, model, prompt_version, status, error, created_at, updated_at, completed_at, batch_id, template_id, template_version, format, prompt_tokens, completion_tokens, latency_ms, cost, lane, priority, task, dispatched_at, trace_context;
+
+-- name: SupersedeDocumentJobs :many
+UPDATE jobs
+SET status = 'cancelled', error = 'superseded by new content', updated_at = current_timestamp, completed_at = current_timestamp
+WHERE document_id = $1 AND status IN ('queued', 'running')
+RETURNING id, document_id, user_id, content_hash, model, prompt_version, status, error, created_at, updated_at, completed_at, batch_id, template_id, template_version, format, prompt_tokens, completion_tokens, latency_ms, cost, lane, priority, task, dispatched_at, trace_context;
+
+-- name: GetJobStatus :one
+SELECT status
+FROM jobs
+WHERE id = $1;
+
+-- name: ListReprocessCandidates :many
+SELECT d.id AS document_id, d.user_id, j.id AS job_id, t.name AS style, j.format
+FROM documents d
+JOIN LATERAL (
+    SELECT lj.id, lj.model, lj.prompt_version, lj.template_id, lj.template_version, lj.format, lj.completed_at
+    FROM jobs lj
+    WHERE lj.document_id = d.id AND lj.status = 'completed'
+    ORDER BY lj.id DESC
+    LIMIT 1
+) j ON true
+LEFT JOIN prompt_templates t ON t.id = j.template_id
+WHERE d.status = 'completed'
+  AND (sqlc.narg('user_id')::int IS NULL OR d.user_id = sqlc.narg('user_id'))
+  AND (sqlc.narg('style')::text IS NULL OR t.name = sqlc.narg('style'))
+  AND (sqlc.narg('template_version')::int IS NULL OR j.template_version = sqlc.narg('template_version'))
+  AND (sqlc.narg('model')::text IS NULL OR j.model = sqlc.narg('model'))
+  AND (sqlc.narg('prompt_version')::text IS NULL OR j.prompt_version = sqlc.narg('prompt_version'))
+  AND (sqlc.narg('completed_before')::timestamp IS NULL OR j.completed_at < sqlc.narg('completed_before'))
+ORDER BY d.id
+LIMIT @max_documents;
+
+-- name: UnclaimLostJobs :execrows
+UPDATE jobs
+SET dispatched_at = NULL, updated_at = current_timestamp
+WHERE status = 'queued'
+  AND dispatched_at < current_timestamp - make_interval(secs => @grace_seconds::float8)
+  AND NOT EXISTS (SELECT 1 FROM outbox_messages o WHERE o.job_id = jobs.id);
+
+-- name: GetJobDuration :one
+SELECT EXTRACT(EPOCH FROM completed_at - created_at)::float8 AS seconds
+FROM jobs
+WHERE id = $1 AND completed_at IS NOT NULL;
+
+-- name: CountJobsByStatus :many
+SELECT status, count(*) AS jobs
+FROM jobs
+GROUP BY status;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- name: GetLlmModel :one
+SELECT id, name, context_tokens, prompt_price, completion_price, quality, enabled, created_at
+FROM llm_models
+WHERE id = $1;
+
+-- name: ListLlmModels :many
+SELECT id, name, context_tokens, prompt_price, completion_price, quality, enabled, created_at
+FROM llm_models
+WHERE enabled
+ORDER BY quality, id;
\ No newline at end of file
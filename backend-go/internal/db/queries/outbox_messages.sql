@@ -0,0 +1,44 @@
+-- name: CreateOutboxMessage :exec
+INSERT INTO outbox_messages (queue_name, body, job_id, trace_context)
+VALUES ($1, $2, $3, (SELECT trace_context FROM jobs WHERE id = $3));
+
+-- name: ClaimDueOutboxMessages :many
+UPDATE outbox_messages
+SET available_at = current_timestamp + make_interval(secs => @lease_seconds::float8)
+WHERE id IN (
+    SELECT id
+    FROM outbox_messages
+    WHERE sent_at IS NULL AND available_at <= current_timestamp
+    ORDER BY id
+    LIMIT @max_messages
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, queue_name, body, job_id, attempts, last_error, available_at, created_at, sent_at, trace_context;
+
+-- name: MarkOutboxMessageSent :exec
+UPDATE outbox_messages
+SET sent_at = current_timestamp, attempts = attempts + 1
+WHERE id = $1;
+
+-- name: MarkOutboxMessageFailed :exec
+UPDATE outbox_messages
+SET attempts = attempts + 1,
+    last_error = @last_error,
+    available_at = current_timestamp + make_interval(secs => @delay_seconds::float8)
+WHERE id = @id;
+
+-- name: DeleteSentOutboxMessages :execrows
+DELETE
+FROM outbox_messages
+WHERE sent_at < current_timestamp - make_interval(secs => @retention_seconds::float8)
+  AND NOT EXISTS (
+    SELECT 1 FROM jobs j
+    WHERE j.id = outbox_messages.job_id AND j.status IN ('queued', 'running')
+  );
+
+-- name: GetOutboxStats :one
+SELECT count(*) AS pending,
+       count(*) FILTER (WHERE attempts > 0) AS failing,
+       min(created_at)::timestamp AS oldest
+FROM outbox_messages
+WHERE sent_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,26 @@
+-- name: GetPromptTemplate :one
+SELECT id, name, version, user_id, body, created_at
+FROM prompt_templates
+WHERE name = $1 AND user_id IS NULL
+ORDER BY version DESC
+LIMIT 1;
+
+-- name: GetCustomPromptTemplate :one
+SELECT id, name, version, user_id, body, created_at
+FROM prompt_templates
+WHERE name = 'custom' AND user_id = $1
+ORDER BY version DESC
+LIMIT 1;
+
+-- name: ListPromptTemplates :many
+SELECT DISTINCT ON (name) id, name, version, user_id, body, created_at
+FROM prompt_templates
+WHERE user_id IS NULL OR user_id = $1
+ORDER BY name, version DESC;
+
+-- name: CreateCustomPromptTemplate :one
+INSERT INTO prompt_templates (name, version, user_id, body)
+SELECT 'custom', coalesce(max(version), 0) + 1, @user_id::int, @body::text
+FROM prompt_templates
+WHERE name = 'custom' AND user_id = @user_id
+RETURNING id, name, version, user_id, body, created_at;
\ No newline at end of file
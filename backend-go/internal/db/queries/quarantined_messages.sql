@@ -0,0 +1,34 @@
+-- name: QuarantineMessage :exec
+INSERT INTO quarantined_messages (queue_name, message_id, body, reason, receive_count, job_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (queue_name, message_id) DO NOTHING;
+
+-- name: GetQuarantinedMessage :one
+SELECT id, queue_name, message_id, body, reason, receive_count, job_id, status, created_at, resolved_at, resolved_by
+FROM quarantined_messages
+WHERE id = $1;
+
+-- name: ListQuarantinedMessages :many
+SELECT id, queue_name, message_id, body, reason, receive_count, job_id, status, created_at, resolved_at, resolved_by
+FROM quarantined_messages
+WHERE status = @status
+  AND (sqlc.narg('queue_name')::text IS NULL OR queue_name = sqlc.narg('queue_name'))
+ORDER BY created_at DESC, id DESC
+LIMIT @max_messages;
+
+-- name: GetQuarantineStats :many
+SELECT queue_name, status, count(*) AS messages
+FROM quarantined_messages
+GROUP BY queue_name, status
+ORDER BY queue_name, status;
+
+-- name: ResolveQuarantinedMessage :one
+UPDATE quarantined_messages
+SET status = $2, resolved_at = current_timestamp, resolved_by = $3
+WHERE id = $1 AND status = 'quarantined'
+RETURNING id, queue_name, message_id, body, reason, receive_count, job_id, status, created_at, resolved_at, resolved_by;
+
+-- name: ReopenQuarantinedMessage :exec
+UPDATE quarantined_messages
+SET status = 'quarantined', resolved_at = NULL, resolved_by = NULL
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,83 @@
+-- name: EnsureQuotaUsage :exec
+INSERT INTO quota_usage (user_id)
+VALUES ($1)
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: GetQuotaUsage :one
+SELECT user_id, bytes_stored, pending_jobs, uploads_day, uploads, spend_month, tokens, cost, updated_at
+FROM quota_usage
+WHERE user_id = $1;
+
+-- name: LockQuotaUsage :one
+SELECT user_id, bytes_stored, pending_jobs, uploads_day, uploads, spend_month, tokens, cost, updated_at
+FROM quota_usage
+WHERE user_id = $1
+FOR UPDATE;
+
+-- name: SaveQuotaUsage :exec
+UPDATE quota_usage
+SET bytes_stored = $2, pending_jobs = $3, uploads_day = $4, uploads = $5, spend_month = $6, tokens = $7, cost = $8,
+    updated_at = current_timestamp
+WHERE user_id = $1;
+
+-- name: AdjustQuotaUsage :exec
+UPDATE quota_usage
+SET bytes_stored = greatest(bytes_stored + @bytes::bigint, 0),
+    pending_jobs = greatest(pending_jobs + @jobs::int, 0),
+    updated_at = current_timestamp
+WHERE user_id = @user_id;
+
+-- name: RecordQuotaSpend :exec
+UPDATE quota_usage
+SET pending_jobs = greatest(pending_jobs - @jobs::int, 0),
+    tokens = CASE WHEN spend_month = @month::date THEN tokens ELSE 0 END + @tokens::bigint,
+    cost = CASE WHEN spend_month = @month::date THEN cost ELSE 0 END + @cost::float8,
+    spend_month = @month::date,
+    updated_at = current_timestamp
+WHERE user_id = @user_id;
+
+-- name: GetQuotaOverride :one
+SELECT user_id, max_bytes, uploads_per_day, tokens_per_month, cost_per_month, pending_jobs, note, granted_by, updated_at
+FROM quota_overrides
+WHERE user_id = $1;
+
+-- name: UpsertQuotaOverride :one
+INSERT INTO quota_overrides (user_id, max_bytes, uploads_per_day, tokens_per_month, cost_per_month, pending_jobs, note, granted_by)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (user_id) DO UPDATE
+SET max_bytes = EXCLUDED.max_bytes,
+    uploads_per_day = EXCLUDED.uploads_per_day,
+    tokens_per_month = EXCLUDED.tokens_per_month,
+    cost_per_month = EXCLUDED.cost_per_month,
+    pending_jobs = EXCLUDED.pending_jobs,
+    note = EXCLUDED.note,
+    granted_by = EXCLUDED.granted_by,
+    updated_at = current_timestamp
+RETURNING user_id, max_bytes, uploads_per_day, tokens_per_month, cost_per_month, pending_jobs, note, granted_by, updated_at;
+
+-- name: DeleteQuotaOverride :execrows
+DELETE
+FROM quota_overrides
+WHERE user_id = $1;
+
+-- name: GetWorkspaceStoredBytes :one
+SELECT coalesce(sum(size), 0)::bigint AS stored_bytes
+FROM documents
+WHERE workspace_id = $1;
+
+-- name: GetWorkspaceQuotaOverride :one
+SELECT workspace_id, max_bytes, granted_by, updated_at
+FROM workspace_quota_overrides
+WHERE workspace_id = $1;
+
+-- name: UpsertWorkspaceQuotaOverride :one
+INSERT INTO workspace_quota_overrides (workspace_id, max_bytes, granted_by)
+VALUES ($1, $2, $3)
+ON CONFLICT (workspace_id) DO UPDATE
+SET max_bytes = EXCLUDED.max_bytes, granted_by = EXCLUDED.granted_by, updated_at = current_timestamp
+RETURNING workspace_id, max_bytes, granted_by, updated_at;
+
+-- name: DeleteWorkspaceQuotaOverride :execrows
+DELETE
+FROM workspace_quota_overrides
+WHERE workspace_id = $1;
\ No newline at end of file
@@ -0,0 +1,25 @@
+-- name: CreateRepository :one
+INSERT INTO repositories (user_id, path, ref)
+VALUES ($1, $2, $3)
+RETURNING id, user_id, path, ref, last_commit, created_at, synced_at;
+
+-- name: GetRepository :one
+SELECT id, user_id, path, ref, last_commit, created_at, synced_at
+FROM repositories
+WHERE id = $1 AND user_id = $2;
+
+-- name: ListRepositoriesByUser :many
+SELECT id, user_id, path, ref, last_commit, created_at, synced_at
+FROM repositories
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: UpdateRepositorySync :exec
+UPDATE repositories
+SET ref = $2, last_commit = $3, synced_at = current_timestamp
+WHERE id = $1;
+
+-- name: DeleteRepository :exec
+DELETE
+FROM repositories
+WHERE id = $1;
\ No newline at end of file
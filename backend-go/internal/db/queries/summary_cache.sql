@@ -0,0 +1,9 @@
+-- name: GetCachedSummary :one
+SELECT content_hash, model, prompt_version, summary_key, created_at, preview, template_id, template_version
+FROM summary_cache
+WHERE content_hash = $1 AND model = $2 AND prompt_version = $3;
+
+-- name: PutCachedSummary :exec
+INSERT INTO summary_cache (content_hash, model, prompt_version, summary_key, preview, template_id, template_version)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (content_hash, model, prompt_version) DO NOTHING;
\ No newline at end of file
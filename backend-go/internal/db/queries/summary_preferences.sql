@@ -0,0 +1,11 @@
+-- name: GetSummaryPreferences :one
+SELECT user_id, style, length, language, updated_at, format, model
+FROM summary_preferences
+WHERE user_id = $1;
+
+-- name: UpsertSummaryPreferences :one
+INSERT INTO summary_preferences (user_id, style, length, language, format, model)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id) DO UPDATE
+SET style = EXCLUDED.style, length = EXCLUDED.length, language = EXCLUDED.language, format = EXCLUDED.format, model = EXCLUDED.model, updated_at = current_timestamp
+RETURNING user_id, style, length, language, updated_at, format, model;
\ No newline at end of file
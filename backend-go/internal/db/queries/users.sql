@@ -1,19 +1,24 @@
 -- name: CreateUser :one
 INSERT INTO users (username, email, pass)
 VALUES ($1, $2, $3)
-RETURNING id, username, email, pass, created_at;
+RETURNING id, username, email, pass, created_at, is_admin;
 
 -- name: GetUserByEmail :one
-SELECT id, username, email, pass, created_at
+SELECT id, username, email, pass, created_at, is_admin
 FROM users
 WHERE email = $1;
 
 -- name: ListUsers :many
-SELECT id, username, email, pass, created_at
+SELECT id, username, email, pass, created_at, is_admin
 FROM users
 ORDER BY created_at DESC;
 
 -- name: GetUserIdByUsername :one
 SELECT id
 FROM users
-WHERE username = $1;
\ No newline at end of file
+WHERE username = $1;
+
+-- name: GetUser :one
+SELECT id, username, email, pass, created_at, is_admin
+FROM users
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,38 @@
+-- name: CreateWorkspace :one
+INSERT INTO workspaces (name, owner_id)
+VALUES ($1, $2)
+RETURNING id, name, owner_id, created_at;
+
+-- name: GetWorkspace :one
+SELECT id, name, owner_id, created_at
+FROM workspaces
+WHERE id = $1;
+
+-- name: ListWorkspacesByMember :many
+SELECT w.id, w.name, w.owner_id, w.created_at, m.role
+FROM workspaces w
+JOIN workspace_members m ON m.workspace_id = w.id
+WHERE m.user_id = $1
+ORDER BY w.name;
+
+-- name: AddWorkspaceMember :exec
+INSERT INTO workspace_members (workspace_id, user_id, role)
+VALUES ($1, $2, $3)
+ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role;
+
+-- name: RemoveWorkspaceMember :exec
+DELETE
+FROM workspace_members
+WHERE workspace_id = $1 AND user_id = $2;
+
+-- name: GetWorkspaceMemberRole :one
+SELECT role
+FROM workspace_members
+WHERE workspace_id = $1 AND user_id = $2;
+
+-- name: ListWorkspaceMembers :many
+SELECT u.id, u.username, u.email, m.role
+FROM workspace_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.workspace_id = $1
+ORDER BY u.username;
\ No newline at end of file
@@ -12,4 +12,363 @@ create table if not exists user_sessions (
     session_token varchar(255) unique not null,
     created_at timestamp default current_timestamp,
     expires_at timestamp not null
-);
\ No newline at end of file
+);
+
+create table if not exists blobs (
+    hash varchar(64) primary key,
+    size bigint not null,
+    storage_key varchar(255) not null,
+    ref_count int not null default 0,
+    created_at timestamp default current_timestamp
+);
+
+create table if not exists repositories (
+    id serial primary key,
+    user_id int not null references users(id) on delete cascade,
+    path varchar(1024) not null,
+    ref varchar(255) not null,
+    last_commit varchar(40),
+    created_at timestamp default current_timestamp,
+    synced_at timestamp,
+    unique (user_id, path)
+);
+
+create table if not exists workspaces (
+    id serial primary key,
+    name varchar(255) not null,
+    owner_id int not null references users(id) on delete cascade,
+    created_at timestamp default current_timestamp
+);
+
+create table if not exists workspace_members (
+    workspace_id int not null references workspaces(id) on delete cascade,
+    user_id int not null references users(id) on delete cascade,
+    role varchar(20) not null default 'member',
+    created_at timestamp default current_timestamp,
+    primary key (workspace_id, user_id)
+);
+
+create index if not exists workspace_members_user_id_idx on workspace_members(user_id);
+
+create table if not exists documents (
+    id serial primary key,
+    user_id int not null references users(id) on delete cascade,
+    path varchar(1024) not null,
+    blob_hash varchar(64) not null references blobs(hash),
+    size bigint not null,
+    status varchar(20) not null default 'pending',
+    summary_key varchar(1024),
+    created_at timestamp default current_timestamp,
+    updated_at timestamp default current_timestamp,
+    repository_id int references repositories(id) on delete set null,
+    source_sha varchar(40),
+    summary_preview varchar(300),
+    workspace_id int references workspaces(id) on delete set null
+);
+
+create index if not exists documents_user_id_idx on documents(user_id);
+create index if not exists documents_blob_hash_idx on documents(blob_hash);
+create index if not exists documents_repository_id_idx on documents(repository_id);
+create index if not exists documents_user_created_idx on documents(user_id, created_at, id);
+create index if not exists documents_workspace_id_idx on documents(workspace_id);
+
+create table if not exists document_tags (
+    document_id int not null references documents(id) on delete cascade,
+    tag varchar(64) not null,
+    primary key (document_id, tag)
+);
+
+create index if not exists document_tags_tag_idx on document_tags(tag);
+
+create table if not exists document_versions (
+    id serial primary key,
+    document_id int not null references documents(id) on delete cascade,
+    blob_hash varchar(64) not null,
+    commit_sha varchar(40),
+    created_at timestamp default current_timestamp
+);
+
+create index if not exists document_versions_document_id_idx on document_versions(document_id);
+
+create table if not exists batches (
+    id serial primary key,
+    user_id int not null references users(id) on delete cascade,
+    name varchar(1024) not null,
+    status varchar(20) not null default 'open',
+    total int not null default 0,
+    completed int not null default 0,
+    failed int not null default 0,
+    created_at timestamp default current_timestamp,
+    completed_at timestamp
+);
+
+create table if not exists jobs (
+    id serial primary key,
+    document_id int not null references documents(id) on delete cascade,
+    user_id int not null references users(id) on delete cascade,
+    content_hash varchar(64) not null,
+    model varchar(255) not null,
+    prompt_version varchar(50) not null,
+    status varchar(20) not null default 'queued',
+    error text,
+    created_at timestamp default current_timestamp,
+    updated_at timestamp default current_timestamp,
+    completed_at timestamp,
+    batch_id int references batches(id) on delete set null
+);
+
+create index if not exists jobs_document_id_idx on jobs(document_id);
+
+create table if not exists summary_cache (
+    content_hash varchar(64) not null,
+    model varchar(255) not null,
+    prompt_version varchar(50) not null,
+    summary_key varchar(1024) not null,
+    created_at timestamp default current_timestamp,
+    preview varchar(300),
+    primary key (content_hash, model, prompt_version)
+);
+
+create table if not exists document_search (
+    document_id int primary key references documents(id) on delete cascade,
+    title text not null default '',
+    content text not null default '',
+    summary text not null default '',
+    search_vector tsvector generated always as (
+        setweight(to_tsvector('english', title), 'A') ||
+        setweight(to_tsvector('english', summary), 'B') ||
+        setweight(to_tsvector('english', content), 'C')
+    ) stored
+);
+
+create index if not exists document_search_vector_idx on document_search using gin (search_vector);
+
+create table if not exists document_chunks (
+    id serial primary key,
+    document_id int not null references documents(id) on delete cascade,
+    chunk_index int not null,
+    heading text not null default '',
+    start_line int not null,
+    end_line int not null,
+    content text not null,
+    content_hash varchar(64) not null,
+    model varchar(255) not null,
+    embedding real[] not null,
+    created_at timestamp default current_timestamp,
+    unique (document_id, model, chunk_index)
+);
+
+create index if not exists document_chunks_model_idx on document_chunks(model, document_id);
+
+create table if not exists ask_threads (
+    id serial primary key,
+    document_id int not null references documents(id) on delete cascade,
+    user_id int not null references users(id) on delete cascade,
+    title varchar(255) not null,
+    created_at timestamp default current_timestamp,
+    updated_at timestamp default current_timestamp
+);
+
+create index if not exists ask_threads_document_user_idx on ask_threads(document_id, user_id);
+
+create table if not exists ask_messages (
+    id serial primary key,
+    thread_id int not null references ask_threads(id) on delete cascade,
+    role varchar(20) not null,
+    content text not null,
+    citations jsonb not null default '[]',
+    created_at timestamp default current_timestamp
+);
+
+create index if not exists ask_messages_thread_id_idx on ask_messages(thread_id, id);
+
+create table if not exists prompt_templates (
+    id serial primary key,
+    name varchar(50) not null,
+    version int not null,
+    -- null for the built-in styles, the owner for custom templates
+    user_id int references users(id) on delete cascade,
+    body text not null,
+    created_at timestamp default current_timestamp
+);
+
+create unique index if not exists prompt_templates_name_version_idx on prompt_templates(coalesce(user_id, 0), name, version);
+
+insert into prompt_templates (name, version, body) values
+    ('tldr', 1, 'Summarize the following text, {{length}}. Write the summary in {{language}}.'),
+    ('bullets', 1, 'List the key points of the following text as a markdown bullet list, {{length}}. Write the list in {{language}}.'),
+    ('executive', 1, 'Write an executive summary of the following text for a busy decision maker: the situation, the key findings and what needs to be decided or done, {{length}}. Write the summary in {{language}}.'),
+    ('deep-dive', 1, 'Write a technical deep-dive of the following text for engineers: the architecture, the key mechanisms, trade-offs and caveats, {{length}}. Use markdown headings where they help. Write the summary in {{language}}.')
+on conflict do nothing;
+
+create table if not exists summary_preferences (
+    user_id int primary key references users(id) on delete cascade,
+    style varchar(50) not null,
+    length varchar(20) not null,
+    language varchar(40) not null,
+    updated_at timestamp default current_timestamp
+);
+
+alter table jobs add column if not exists template_id int references prompt_templates(id) on delete set null;
+alter table jobs add column if not exists template_version int;
+alter table summary_cache add column if not exists template_id int references prompt_templates(id) on delete set null;
+alter table summary_cache add column if not exists template_version int;
+
+alter table summary_preferences add column if not exists format varchar(20) not null default 'text';
+alter table jobs add column if not exists format varchar(20) not null default 'text';
+
+create table if not exists llm_models (
+    id varchar(255) primary key,
+    name varchar(255) not null,
+    context_tokens int not null,
+    -- USD per million tokens
+    prompt_price double precision not null default 0,
+    completion_price double precision not null default 0,
+    -- 1 is enough for short summaries, 3 for the most demanding styles
+    quality int not null default 1,
+    enabled boolean not null default true,
+    created_at timestamp default current_timestamp
+);
+
+insert into llm_models (id, name, context_tokens, prompt_price, completion_price, quality) values
+    ('x-ai/grok-4-fast:free', 'Grok 4 Fast (free)', 2000000, 0, 0, 2),
+    ('openai/gpt-4o-mini', 'GPT-4o mini', 128000, 0.15, 0.60, 1),
+    ('google/gemini-2.0-flash-001', 'Gemini 2.0 Flash', 1000000, 0.10, 0.40, 2),
+    ('anthropic/claude-sonnet-4', 'Claude Sonnet 4', 200000, 3.00, 15.00, 3)
+on conflict do nothing;
+
+alter table summary_preferences add column if not exists model varchar(255) not null default 'auto';
+
+alter table jobs add column if not exists prompt_tokens int not null default 0;
+alter table jobs add column if not exists completion_tokens int not null default 0;
+alter table jobs add column if not exists latency_ms int not null default 0;
+alter table jobs add column if not exists cost double precision not null default 0;
+
+create index if not exists jobs_user_created_idx on jobs(user_id, created_at);
+
+alter table users add column if not exists is_admin boolean not null default false;
+
+create table if not exists quota_usage (
+    user_id int primary key references users(id) on delete cascade,
+    bytes_stored bigint not null default 0,
+    pending_jobs int not null default 0,
+    -- uploads counts the uploads on uploads_day, tokens and cost the spend
+    -- in the month starting on spend_month
+    uploads_day date not null default current_date,
+    uploads int not null default 0,
+    spend_month date not null default date_trunc('month', current_date),
+    tokens bigint not null default 0,
+    cost double precision not null default 0,
+    updated_at timestamp default current_timestamp
+);
+
+-- null columns fall back to the configured defaults
+create table if not exists quota_overrides (
+    user_id int primary key references users(id) on delete cascade,
+    max_bytes bigint,
+    uploads_per_day int,
+    tokens_per_month bigint,
+    cost_per_month double precision,
+    pending_jobs int,
+    note text not null default '',
+    granted_by int references users(id) on delete set null,
+    updated_at timestamp default current_timestamp
+);
+
+create table if not exists workspace_quota_overrides (
+    workspace_id int primary key references workspaces(id) on delete cascade,
+    max_bytes bigint not null,
+    granted_by int references users(id) on delete set null,
+    updated_at timestamp default current_timestamp
+);
+
+-- existing data counts against the new quotas
+insert into quota_usage (user_id, bytes_stored, pending_jobs)
+select u.id,
+       coalesce((select sum(d.size) from documents d where d.user_id = u.id), 0),
+       (select count(*) from jobs j where j.user_id = u.id and j.status in ('queued', 'running'))
+from users u
+on conflict do nothing;
+
+-- lane and priority decide the order the dispatcher sends jobs in; task is
+-- the message it sends and dispatched_at when it did
+alter table jobs add column if not exists lane varchar(20) not null default 'interactive';
+alter table jobs add column if not exists priority int not null default 0;
+alter table jobs add column if not exists task text;
+alter table jobs add column if not exists dispatched_at timestamp;
+
+-- jobs created before the dispatcher went to the task queue right away
+update jobs set dispatched_at = created_at where dispatched_at is null;
+
+create index if not exists jobs_waiting_idx on jobs(lane, user_id, priority desc, id)
+    where dispatched_at is null and status = 'queued';
+create index if not exists jobs_dispatched_idx on jobs(dispatched_at)
+    where status in ('queued', 'running');
+
+-- messages taken off a queue because they can't be processed, either right
+-- away (unparseable, object missing) or after too many receives from the
+-- queue's dead-letter queue
+create table if not exists quarantined_messages (
+    id serial primary key,
+    queue_name text not null,
+    message_id text not null,
+    body text not null,
+    reason text not null,
+    receive_count int not null default 0,
+    job_id int references jobs(id) on delete set null,
+    status text not null default 'quarantined',
+    created_at timestamp default current_timestamp,
+    resolved_at timestamp,
+    resolved_by int references users(id) on delete set null
+);
+
+create unique index if not exists quarantined_messages_message_idx on quarantined_messages (queue_name, message_id);
+create index if not exists quarantined_messages_status_idx on quarantined_messages (status, created_at);
+
+-- messages to publish, written in the same transaction as the change they
+-- announce and sent by the relay until SQS accepts them
+create table if not exists outbox_messages (
+    id serial primary key,
+    -- the queue's role, e.g. tasks; the relay maps it to the SQS queue
+    queue_name text not null,
+    body text not null,
+    job_id int references jobs(id) on delete set null,
+    attempts int not null default 0,
+    last_error text,
+    available_at timestamp not null default current_timestamp,
+    created_at timestamp default current_timestamp,
+    sent_at timestamp
+);
+
+create index if not exists outbox_messages_unsent_idx on outbox_messages (available_at)
+    where sent_at is null;
+create index if not exists outbox_messages_job_idx on outbox_messages (job_id);
+
+-- jobs dispatched before the outbox were sent directly; record them as sent
+-- so they aren't taken for jobs whose message was lost
+insert into outbox_messages (queue_name, body, job_id, available_at, created_at, sent_at)
+select 'tasks', coalesce(task, ''), id, dispatched_at, dispatched_at, dispatched_at
+from jobs
+where dispatched_at is not null and status in ('queued', 'running');
+
+-- responses of requests sent with an Idempotency-Key, replayed when the same
+-- request comes again before expires_at
+create table if not exists idempotency_keys (
+    user_id int not null references users(id) on delete cascade,
+    key text not null,
+    fingerprint text not null,
+    status text not null default 'processing',
+    response_status int,
+    response_body bytea,
+    content_type text,
+    created_at timestamp default current_timestamp,
+    expires_at timestamp not null,
+    primary key (user_id, key)
+);
+
+create index if not exists idempotency_keys_expires_idx on idempotency_keys (expires_at);
+
+-- W3C trace context of the request that created a job, so the task the
+-- dispatcher sends later continues the same trace
+alter table jobs add column if not exists trace_context jsonb;
+alter table outbox_messages add column if not exists trace_context jsonb;
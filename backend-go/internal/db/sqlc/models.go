@@ -8,12 +8,243 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AskMessage struct {
+	ID        int32
+	ThreadID  int32
+	Role      string
+	Content   string
+	Citations []byte
+	CreatedAt pgtype.Timestamp
+}
+
+type AskThread struct {
+	ID         int32
+	DocumentID int32
+	UserID     int32
+	Title      string
+	CreatedAt  pgtype.Timestamp
+	UpdatedAt  pgtype.Timestamp
+}
+
+type Batch struct {
+	ID          int32
+	UserID      int32
+	Name        string
+	Status      string
+	Total       int32
+	Completed   int32
+	Failed      int32
+	CreatedAt   pgtype.Timestamp
+	CompletedAt pgtype.Timestamp
+}
+
+type Blob struct {
+	Hash       string
+	Size       int64
+	StorageKey string
+	RefCount   int32
+	CreatedAt  pgtype.Timestamp
+}
+
+type Document struct {
+	ID             int32
+	UserID         int32
+	Path           string
+	BlobHash       string
+	Size           int64
+	Status         string
+	SummaryKey     pgtype.Text
+	CreatedAt      pgtype.Timestamp
+	UpdatedAt      pgtype.Timestamp
+	RepositoryID   pgtype.Int4
+	SourceSha      pgtype.Text
+	SummaryPreview pgtype.Text
+	WorkspaceID    pgtype.Int4
+}
+
+type DocumentChunk struct {
+	ID          int32
+	DocumentID  int32
+	ChunkIndex  int32
+	Heading     string
+	StartLine   int32
+	EndLine     int32
+	Content     string
+	ContentHash string
+	Model       string
+	Embedding   []float32
+	CreatedAt   pgtype.Timestamp
+}
+
+type DocumentSearch struct {
+	DocumentID   int32
+	Title        string
+	Content      string
+	Summary      string
+	SearchVector interface{}
+}
+
+type DocumentTag struct {
+	DocumentID int32
+	Tag        string
+}
+
+type DocumentVersion struct {
+	ID         int32
+	DocumentID int32
+	BlobHash   string
+	CommitSha  pgtype.Text
+	CreatedAt  pgtype.Timestamp
+}
+
+type IdempotencyKey struct {
+	UserID         int32
+	Key            string
+	Fingerprint    string
+	Status         string
+	ResponseStatus pgtype.Int4
+	ResponseBody   []byte
+	ContentType    pgtype.Text
+	CreatedAt      pgtype.Timestamp
+	ExpiresAt      pgtype.Timestamp
+}
+
+type Job struct {
+	ID               int32
+	DocumentID       int32
+	UserID           int32
+	ContentHash      string
+	Model            string
+	PromptVersion    string
+	Status           string
+	Error            pgtype.Text
+	CreatedAt        pgtype.Timestamp
+	UpdatedAt        pgtype.Timestamp
+	CompletedAt      pgtype.Timestamp
+	BatchID          pgtype.Int4
+	TemplateID       pgtype.Int4
+	TemplateVersion  pgtype.Int4
+	Format           string
+	PromptTokens     int32
+	CompletionTokens int32
+	LatencyMs        int32
+	Cost             float64
+	Lane             string
+	Priority         int32
+	Task             pgtype.Text
+	DispatchedAt     pgtype.Timestamp
+	TraceContext     []byte
+}
+
+type LlmModel struct {
+	ID              string
+	Name            string
+	ContextTokens   int32
+	PromptPrice     float64
+	CompletionPrice float64
+	Quality         int32
+	Enabled         bool
+	CreatedAt       pgtype.Timestamp
+}
+
+type OutboxMessage struct {
+	ID           int32
+	QueueName    string
+	Body         string
+	JobID        pgtype.Int4
+	Attempts     int32
+	LastError    pgtype.Text
+	AvailableAt  pgtype.Timestamp
+	CreatedAt    pgtype.Timestamp
+	SentAt       pgtype.Timestamp
+	TraceContext []byte
+}
+
+type PromptTemplate struct {
+	ID        int32
+	Name      string
+	Version   int32
+	UserID    pgtype.Int4
+	Body      string
+	CreatedAt pgtype.Timestamp
+}
+
+type QuarantinedMessage struct {
+	ID           int32
+	QueueName    string
+	MessageID    string
+	Body         string
+	Reason       string
+	ReceiveCount int32
+	JobID        pgtype.Int4
+	Status       string
+	CreatedAt    pgtype.Timestamp
+	ResolvedAt   pgtype.Timestamp
+	ResolvedBy   pgtype.Int4
+}
+
+type QuotaOverride struct {
+	UserID         int32
+	MaxBytes       pgtype.Int8
+	UploadsPerDay  pgtype.Int4
+	TokensPerMonth pgtype.Int8
+	CostPerMonth   pgtype.Float8
+	PendingJobs    pgtype.Int4
+	Note           string
+	GrantedBy      pgtype.Int4
+	UpdatedAt      pgtype.Timestamp
+}
+
+type QuotaUsage struct {
+	UserID      int32
+	BytesStored int64
+	PendingJobs int32
+	UploadsDay  pgtype.Date
+	Uploads     int32
+	SpendMonth  pgtype.Date
+	Tokens      int64
+	Cost        float64
+	UpdatedAt   pgtype.Timestamp
+}
+
+type Repository struct {
+	ID         int32
+	UserID     int32
+	Path       string
+	Ref        string
+	LastCommit pgtype.Text
+	CreatedAt  pgtype.Timestamp
+	SyncedAt   pgtype.Timestamp
+}
+
+type SummaryCache struct {
+	ContentHash     string
+	Model           string
+	PromptVersion   string
+	SummaryKey      string
+	CreatedAt       pgtype.Timestamp
+	Preview         pgtype.Text
+	TemplateID      pgtype.Int4
+	TemplateVersion pgtype.Int4
+}
+
+type SummaryPreference struct {
+	UserID    int32
+	Style     string
+	Length    string
+	Language  string
+	UpdatedAt pgtype.Timestamp
+	Format    string
+	Model     string
+}
+
 type User struct {
 	ID        int32
 	Username  string
 	Email     string
 	Pass      string
 	CreatedAt pgtype.Timestamp
+	IsAdmin   bool
 }
 
 type UserSession struct {
@@ -23,3 +254,24 @@ type UserSession struct {
 	CreatedAt    pgtype.Timestamp
 	ExpiresAt    pgtype.Timestamp
 }
+
+type Workspace struct {
+	ID        int32
+	Name      string
+	OwnerID   int32
+	CreatedAt pgtype.Timestamp
+}
+
+type WorkspaceMember struct {
+	WorkspaceID int32
+	UserID      int32
+	Role        string
+	CreatedAt   pgtype.Timestamp
+}
+
+type WorkspaceQuotaOverride struct {
+	WorkspaceID int32
+	MaxBytes    int64
+	GrantedBy   pgtype.Int4
+	UpdatedAt   pgtype.Timestamp
+}
@@ -12,7 +12,7 @@ import (
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (username, email, pass)
 VALUES ($1, $2, $3)
-RETURNING id, username, email, pass, created_at
+RETURNING id, username, email, pass, created_at, is_admin
 `
 
 type CreateUserParams struct {
@@ -30,12 +30,33 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.Pass,
 		&i.CreatedAt,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, username, email, pass, created_at, is_admin
+FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id int32) (User, error) {
+	row := q.db.QueryRow(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.Pass,
+		&i.CreatedAt,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, username, email, pass, created_at
+SELECT id, username, email, pass, created_at, is_admin
 FROM users
 WHERE email = $1
 `
@@ -49,6 +70,7 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.Pass,
 		&i.CreatedAt,
+		&i.IsAdmin,
 	)
 	return i, err
 }
@@ -67,7 +89,7 @@ func (q *Queries) GetUserIdByUsername(ctx context.Context, username string) (int
 }
 
 const listUsers = `-- name: ListUsers :many
-SELECT id, username, email, pass, created_at
+SELECT id, username, email, pass, created_at, is_admin
 FROM users
 ORDER BY created_at DESC
 `
@@ -87,6 +109,7 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 			&i.Email,
 			&i.Pass,
 			&i.CreatedAt,
+			&i.IsAdmin,
 		); err != nil {
 			return nil, err
 		}
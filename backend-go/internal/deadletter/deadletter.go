@@ -0,0 +1,255 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	clients "backend-go/internal/clients"
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/ingest"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Statuses of a quarantined message.
+const (
+	StatusQuarantined = "quarantined"
+	StatusRedriven    = "redriven"
+	StatusDiscarded   = "discarded"
+)
+
+// ReasonAttribute is the message attribute that says why a message was sent
+// to a dead-letter queue directly instead of waiting for its receive count
+// to run out.
+const ReasonAttribute = "FailureReason"
+
+// ErrResolved is returned when redriving or discarding a message that has
+// already been redriven or discarded.
+var ErrResolved = errors.New("message has already been redriven or discarded")
+
+type Config struct {
+	// Queues are the queues whose dead-letter queues are swept.
+	Queues []string
+	// ResponseQueue gets a failed result for the unfinished job of a
+	// discarded message.
+	ResponseQueue string
+	// MaxReceiveCount is how often a message is received before SQS moves
+	// it to the dead-letter queue.
+	MaxReceiveCount int
+	// Interval is how often the dead-letter queues are swept.
+	Interval time.Duration
+}
+
+var DefaultConfig = Config{
+	MaxReceiveCount: 5,
+	Interval:        time.Minute,
+}
+
+// Service keeps messages that can't be processed in Postgres, where admins
+// can look at them and send them back or drop them.
+type Service struct {
+	sqsClient *sqs.Client
+	queries   *sqlc.Queries
+	cfg       Config
+}
+
+func NewService(sqsClient *sqs.Client, queries *sqlc.Queries, cfg Config) *Service {
+	return &Service{sqsClient: sqsClient, queries: queries, cfg: cfg}
+}
+
+// Quarantine records a message taken off queue. The caller deletes the
+// message once it is recorded.
+func (s *Service) Quarantine(ctx context.Context, queue string, m types.Message, reason string) error {
+	body := aws.ToString(m.Body)
+	receives, _ := strconv.Atoi(m.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+
+	var jobID pgtype.Int4
+	if id, ok := messageJob(body); ok {
+		// the job may be gone by now
+		if _, err := s.queries.GetJobStatus(ctx, id); err == nil {
+			jobID = pgtype.Int4{Int32: id, Valid: true}
+		}
+	}
+
+	return s.queries.QuarantineMessage(ctx, sqlc.QuarantineMessageParams{
+		QueueName:    queue,
+		MessageID:    aws.ToString(m.MessageId),
+		Body:         body,
+		Reason:       reason,
+		ReceiveCount: int32(receives),
+		JobID:        jobID,
+	})
+}
+
+// Start sweeps the dead-letter queues every Interval until ctx is done.
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			if _, err := s.Sweep(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("failed to sweep dead-letter queues", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Sweep quarantines every message in the dead-letter queues and returns how
+// many it moved.
+func (s *Service) Sweep(ctx context.Context) (int, error) {
+	moved := 0
+	for _, queue := range s.cfg.Queues {
+		n, err := s.sweep(ctx, queue)
+		moved += n
+		if err != nil {
+			return moved, err
+		}
+	}
+	return moved, nil
+}
+
+func (s *Service) sweep(ctx context.Context, queue string) (int, error) {
+	dlqName := clients.DeadLetterQueueName(queue)
+	getOut, err := s.sqsClient.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: &dlqName})
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up %s: %w", dlqName, err)
+	}
+
+	moved := 0
+	for {
+		resp, err := s.sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              getOut.QueueUrl,
+			MaxNumberOfMessages:   10,
+			MessageAttributeNames: []string{ReasonAttribute},
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{
+				types.MessageSystemAttributeNameApproximateReceiveCount,
+			},
+		})
+		if err != nil {
+			return moved, fmt.Errorf("failed to receive from %s: %w", dlqName, err)
+		}
+		if len(resp.Messages) == 0 {
+			return moved, nil
+		}
+
+		for _, m := range resp.Messages {
+			reason := fmt.Sprintf("not processed after %d receives", s.cfg.MaxReceiveCount)
+			if a, ok := m.MessageAttributes[ReasonAttribute]; ok && aws.ToString(a.StringValue) != "" {
+				reason = aws.ToString(a.StringValue)
+			}
+			if err := s.Quarantine(ctx, queue, m, reason); err != nil {
+				return moved, fmt.Errorf("failed to quarantine message %s: %w", aws.ToString(m.MessageId), err)
+			}
+			_, err := s.sqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      getOut.QueueUrl,
+				ReceiptHandle: m.ReceiptHandle,
+			})
+			if err != nil {
+				// quarantined already, so seeing it again does no harm
+				slog.ErrorContext(ctx, "failed to delete message", "message_id", aws.ToString(m.MessageId), "queue", dlqName, "error", err)
+			}
+			moved++
+		}
+	}
+}
+
+// Redrive sends a quarantined message back to the queue it was taken off.
+func (s *Service) Redrive(ctx context.Context, id int32, adminID int32) (sqlc.QuarantinedMessage, error) {
+	msg, err := s.resolve(ctx, id, StatusRedriven, adminID)
+	if err != nil {
+		return sqlc.QuarantinedMessage{}, err
+	}
+	if err := clients.SendMessage(ctx, s.sqsClient, msg.QueueName, msg.Body); err != nil {
+		if rerr := s.queries.ReopenQuarantinedMessage(ctx, id); rerr != nil {
+			slog.ErrorContext(ctx, "failed to put message back in quarantine", "quarantined_message_id", id, "error", rerr)
+		}
+		return sqlc.QuarantinedMessage{}, fmt.Errorf("failed to send message %d to %s: %w", id, msg.QueueName, err)
+	}
+	return msg, nil
+}
+
+// Discard drops a quarantined message for good. A job still waiting for the
+// message is failed the usual way, by a failed result on the response queue.
+func (s *Service) Discard(ctx context.Context, id int32, adminID int32) (sqlc.QuarantinedMessage, error) {
+	msg, err := s.resolve(ctx, id, StatusDiscarded, adminID)
+	if err != nil {
+		return sqlc.QuarantinedMessage{}, err
+	}
+	if msg.JobID.Valid && !isEvent(msg.Body) {
+		s.failJob(ctx, msg.JobID.Int32, msg.Reason)
+	}
+	return msg, nil
+}
+
+// resolve marks a message that is still quarantined as redriven or
+// discarded. pgx.ErrNoRows is returned when there is no such message.
+func (s *Service) resolve(ctx context.Context, id int32, status string, adminID int32) (sqlc.QuarantinedMessage, error) {
+	msg, err := s.queries.ResolveQuarantinedMessage(ctx, sqlc.ResolveQuarantinedMessageParams{
+		ID:         id,
+		Status:     status,
+		ResolvedBy: pgtype.Int4{Int32: adminID, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, gerr := s.queries.GetQuarantinedMessage(ctx, id); gerr == nil {
+			return sqlc.QuarantinedMessage{}, ErrResolved
+		}
+	}
+	return msg, err
+}
+
+func (s *Service) failJob(ctx context.Context, jobID int32, reason string) {
+	job, err := s.queries.GetJob(ctx, jobID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load job", "job_id", jobID, "error", err)
+		return
+	}
+	switch job.Status {
+	case ingest.StatusCompleted, ingest.StatusFailed, ingest.StatusCancelled:
+		return
+	}
+
+	body, _ := json.Marshal(map[string]any{
+		"userId":     strconv.Itoa(int(job.UserID)),
+		"documentId": job.DocumentID,
+		"jobId":      job.ID,
+		"status":     ingest.StatusFailed,
+		"error":      "discarded from quarantine: " + reason,
+	})
+	if err := clients.SendMessage(ctx, s.sqsClient, s.cfg.ResponseQueue, string(body)); err != nil {
+		slog.ErrorContext(ctx, "failed to fail job", "job_id", job.ID, "document_id", job.DocumentID, "error", err)
+	}
+}
+
+// messageJob finds the job a task or response message is about.
+func messageJob(body string) (int32, bool) {
+	var msg struct {
+		JobID int32 `json:"jobId"`
+	}
+	if err := json.Unmarshal([]byte(body), &msg); err != nil || msg.JobID == 0 {
+		return 0, false
+	}
+	return msg.JobID, true
+}
+
+// isEvent reports whether body is one of the worker's intermediate events
+// rather than a task or a result. Losing one doesn't leave a job hanging.
+func isEvent(body string) bool {
+	var msg struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal([]byte(body), &msg) == nil && msg.Type != ""
+}
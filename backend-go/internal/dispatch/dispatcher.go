@@ -0,0 +1,210 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	sqlc "backend-go/internal/db/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Store is the dispatcher's view of the jobs table.
+type Store interface {
+	// Heads returns the next waiting job of every user in every lane.
+	Heads(ctx context.Context) ([]Candidate, error)
+	// Claim marks a job dispatched and sends its task to queue, so that
+	// either both happen or neither does. ok is false when another
+	// dispatcher got there first or the job was cancelled.
+	Claim(ctx context.Context, jobID int32, queue Queue) (ok bool, err error)
+	// InFlight counts the unfinished jobs dispatched within timeout.
+	InFlight(ctx context.Context, timeout time.Duration) (int64, error)
+}
+
+type dbStore struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+}
+
+// NewStore keeps waiting jobs in Postgres.
+func NewStore(pool *pgxpool.Pool, queries *sqlc.Queries) Store {
+	return &dbStore{pool: pool, queries: queries}
+}
+
+func (s *dbStore) Heads(ctx context.Context) ([]Candidate, error) {
+	rows, err := s.queries.ListDispatchHeads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Candidate, len(rows))
+	for i, r := range rows {
+		res[i] = Candidate{JobID: r.ID, UserID: r.UserID, Lane: r.Lane, Priority: r.Priority}
+	}
+	return res, nil
+}
+
+func (s *dbStore) Claim(ctx context.Context, jobID int32, queue Queue) (bool, error) {
+	if q, ok := queue.(TxQueue); ok {
+		return s.claimTx(ctx, jobID, q)
+	}
+
+	task, err := s.queries.ClaimJob(ctx, jobID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := queue.Send(ctx, jobID, task.String); err != nil {
+		if uerr := s.queries.UnclaimJob(ctx, jobID); uerr != nil {
+			slog.Error("failed to put job back", "job_id", jobID, "error", uerr)
+		}
+		return false, fmt.Errorf("failed to send task: %w", err)
+	}
+	return true, nil
+}
+
+// claimTx claims the job and adds its task to queue in one transaction.
+func (s *dbStore) claimTx(ctx context.Context, jobID int32, queue TxQueue) (bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	task, err := s.queries.WithTx(tx).ClaimJob(ctx, jobID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := queue.SendTx(ctx, tx, jobID, task.String); err != nil {
+		return false, fmt.Errorf("failed to send task: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	queue.Notify()
+	return true, nil
+}
+
+func (s *dbStore) InFlight(ctx context.Context, timeout time.Duration) (int64, error) {
+	return s.queries.CountInFlightJobs(ctx, timeout.Seconds())
+}
+
+type Config struct {
+	// MaxInFlight caps the jobs sent to the queue that have not finished.
+	// Keeping the queue short is what lets later, more urgent jobs overtake.
+	MaxInFlight int
+	// InFlightTimeout stops counting jobs that were sent this long ago, so
+	// tasks lost on the way don't block dispatching forever.
+	InFlightTimeout time.Duration
+	// Interval is how often the dispatcher looks for work without being
+	// notified.
+	Interval time.Duration
+	Weights  map[string]int
+}
+
+var DefaultConfig = Config{
+	MaxInFlight:     10,
+	InFlightTimeout: 30 * time.Minute,
+	Interval:        2 * time.Second,
+	Weights:         DefaultWeights,
+}
+
+// Dispatcher moves waiting jobs to the task queue in the order the
+// Scheduler picks, keeping at most MaxInFlight of them there.
+type Dispatcher struct {
+	store     Store
+	queue     Queue
+	cfg       Config
+	scheduler *Scheduler
+	// mu serializes Dispatch so the scheduler sees one caller at a time
+	mu   sync.Mutex
+	wake chan struct{}
+}
+
+func NewDispatcher(store Store, queue Queue, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		store:     store,
+		queue:     queue,
+		cfg:       cfg,
+		scheduler: NewScheduler(cfg.Weights),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Notify asks for a dispatch round soon, e.g. after a job was created or
+// finished. It never blocks.
+func (d *Dispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start dispatches whenever notified and every Interval until ctx is done.
+func (d *Dispatcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			if _, err := d.Dispatch(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("failed to dispatch jobs", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-d.wake:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Dispatch sends jobs until the queue is full or nothing is waiting, and
+// returns how many it sent.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	inFlight, err := d.InFlight(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count jobs in flight: %w", err)
+	}
+
+	sent := 0
+	for inFlight < int64(d.cfg.MaxInFlight) {
+		heads, err := d.store.Heads(ctx)
+		if err != nil {
+			return sent, fmt.Errorf("failed to list waiting jobs: %w", err)
+		}
+		next, ok := d.scheduler.Next(heads)
+		if !ok {
+			return sent, nil
+		}
+
+		ok, err = d.store.Claim(ctx, next.JobID, d.queue)
+		if err != nil {
+			return sent, fmt.Errorf("failed to dispatch job %d: %w", next.JobID, err)
+		}
+		if !ok {
+			// taken by another backend or cancelled; look again
+			continue
+		}
+		sent++
+		inFlight++
+	}
+	return sent, nil
+}
+
+// InFlight counts the jobs in the queue or running.
+func (d *Dispatcher) InFlight(ctx context.Context) (int64, error) {
+	return d.store.InFlight(ctx, d.cfg.InFlightTimeout)
+}
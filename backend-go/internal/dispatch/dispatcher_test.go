@@ -0,0 +1,248 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeJob struct {
+	Candidate
+	dispatched bool
+	finished   bool
+}
+
+// fakeStore keeps jobs in memory the way the jobs table does: the head of a
+// user's lane is its highest priority job, oldest first.
+type fakeStore struct {
+	mu   sync.Mutex
+	jobs []*fakeJob
+}
+
+func (s *fakeStore) add(userID int32, lane string, priority int32) int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := int32(len(s.jobs) + 1)
+	s.jobs = append(s.jobs, &fakeJob{Candidate: Candidate{JobID: id, UserID: userID, Lane: lane, Priority: priority}})
+	return id
+}
+
+func (s *fakeStore) finish(jobID int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[jobID-1].finished = true
+}
+
+func (s *fakeStore) Heads(ctx context.Context) ([]Candidate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	heads := map[string]Candidate{}
+	for _, j := range s.jobs {
+		if j.dispatched {
+			continue
+		}
+		key := fmt.Sprintf("%s/%d", j.Lane, j.UserID)
+		h, ok := heads[key]
+		if !ok || j.Priority > h.Priority || (j.Priority == h.Priority && j.JobID < h.JobID) {
+			heads[key] = j.Candidate
+		}
+	}
+	res := make([]Candidate, 0, len(heads))
+	for _, h := range heads {
+		res = append(res, h)
+	}
+	sort.Slice(res, func(a, b int) bool { return res[a].JobID < res[b].JobID })
+	return res, nil
+}
+
+func (s *fakeStore) Claim(ctx context.Context, jobID int32, queue Queue) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := s.jobs[jobID-1]
+	if j.dispatched {
+		return false, nil
+	}
+	if err := queue.Send(ctx, jobID, strconv.Itoa(int(jobID))); err != nil {
+		return false, err
+	}
+	j.dispatched = true
+	return true, nil
+}
+
+func (s *fakeStore) InFlight(ctx context.Context, timeout time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := int64(0)
+	for _, j := range s.jobs {
+		if j.dispatched && !j.finished {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func newTestDispatcher(store Store, queue Queue, maxInFlight int) *Dispatcher {
+	cfg := DefaultConfig
+	cfg.MaxInFlight = maxInFlight
+	return NewDispatcher(store, queue, cfg)
+}
+
+// drain returns the jobs in the queue in the order they were sent.
+func drain(t *testing.T, q *MemoryQueue) []int32 {
+	t.Helper()
+	var ids []int32
+	for {
+		body, ok := q.Receive()
+		if !ok {
+			return ids
+		}
+		id, err := strconv.Atoi(body)
+		if err != nil {
+			t.Fatalf("unexpected task %q", body)
+		}
+		ids = append(ids, int32(id))
+	}
+}
+
+func mustDispatch(t *testing.T, d *Dispatcher, want int) {
+	t.Helper()
+	sent, err := d.Dispatch(context.Background())
+	if err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if sent != want {
+		t.Fatalf("Dispatch sent %d jobs, want %d", sent, want)
+	}
+}
+
+func TestDispatchLaneWeights(t *testing.T) {
+	store := &fakeStore{}
+	lane := map[int32]string{}
+	for i := 0; i < 20; i++ {
+		for _, l := range lanes {
+			lane[store.add(1, l, 0)] = l
+		}
+	}
+	queue := NewMemoryQueue()
+	d := newTestDispatcher(store, queue, 20)
+
+	mustDispatch(t, d, 20)
+
+	got := drain(t, queue)
+	// every round of 10 follows the weights
+	for round := 0; round < 2; round++ {
+		counts := map[string]int{}
+		for _, id := range got[round*10 : (round+1)*10] {
+			counts[lane[id]]++
+		}
+		for _, l := range lanes {
+			if counts[l] != DefaultWeights[l] {
+				t.Fatalf("round %d sent %d %s jobs, want %d (order %v)", round, counts[l], l, DefaultWeights[l], got)
+			}
+		}
+	}
+	// and spreads them out rather than sending 6 interactive in a row
+	if lane[got[1]] == LaneInteractive {
+		t.Fatalf("second job is interactive again, want another lane (order %v)", got)
+	}
+}
+
+func TestDispatchUserFairness(t *testing.T) {
+	store := &fakeStore{}
+	user := map[int32]int32{}
+	// user 1 uploaded a pile of files before the others
+	for i := 0; i < 10; i++ {
+		user[store.add(1, LaneBulk, 0)] = 1
+	}
+	user[store.add(2, LaneBulk, 0)] = 2
+	user[store.add(3, LaneBulk, 0)] = 3
+	queue := NewMemoryQueue()
+	d := newTestDispatcher(store, queue, 3)
+
+	mustDispatch(t, d, 3)
+
+	seen := map[int32]bool{}
+	for _, id := range drain(t, queue) {
+		if seen[user[id]] {
+			t.Fatalf("user %d got a second job before every user got one", user[id])
+		}
+		seen[user[id]] = true
+	}
+}
+
+func TestDispatchBumpedFirst(t *testing.T) {
+	store := &fakeStore{}
+	for i := 0; i < 5; i++ {
+		store.add(1, LaneInteractive, 0)
+	}
+	bumped := store.add(2, LaneScheduled, 10)
+	queue := NewMemoryQueue()
+	d := newTestDispatcher(store, queue, 1)
+
+	mustDispatch(t, d, 1)
+
+	if got := drain(t, queue); got[0] != bumped {
+		t.Fatalf("sent job %d first, want bumped job %d", got[0], bumped)
+	}
+}
+
+func TestDispatchDeprioritizedLast(t *testing.T) {
+	store := &fakeStore{}
+	low := store.add(1, LaneInteractive, -1)
+	for i := 0; i < 5; i++ {
+		store.add(2, LaneScheduled, 0)
+	}
+	queue := NewMemoryQueue()
+	d := newTestDispatcher(store, queue, 10)
+
+	mustDispatch(t, d, 6)
+
+	got := drain(t, queue)
+	if got[len(got)-1] != low {
+		t.Fatalf("sent jobs %v, want deprioritized job %d last", got, low)
+	}
+}
+
+func TestDispatchMaxInFlight(t *testing.T) {
+	store := &fakeStore{}
+	for i := 0; i < 5; i++ {
+		store.add(1, LaneInteractive, 0)
+	}
+	queue := NewMemoryQueue()
+	d := newTestDispatcher(store, queue, 2)
+
+	mustDispatch(t, d, 2)
+	mustDispatch(t, d, 0)
+
+	// a finished job makes room for the next
+	store.finish(drain(t, queue)[0])
+	mustDispatch(t, d, 1)
+	if n := queue.Len(); n != 1 {
+		t.Fatalf("queue holds %d tasks, want 1", n)
+	}
+}
+
+type failingQueue struct{}
+
+func (failingQueue) Send(ctx context.Context, jobID int32, body string) error {
+	return fmt.Errorf("queue unavailable")
+}
+
+func TestDispatchKeepsUnsent(t *testing.T) {
+	store := &fakeStore{}
+	id := store.add(1, LaneInteractive, 0)
+	d := newTestDispatcher(store, failingQueue{}, 1)
+
+	if _, err := d.Dispatch(context.Background()); err == nil {
+		t.Fatal("Dispatch succeeded with a failing queue")
+	}
+
+	heads, _ := store.Heads(context.Background())
+	if len(heads) != 1 || heads[0].JobID != id {
+		t.Fatalf("job %d is no longer waiting: %v", id, heads)
+	}
+}
@@ -0,0 +1,74 @@
+package dispatch
+
+import (
+	"context"
+	"sync"
+
+	clients "backend-go/internal/clients"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/jackc/pgx/v5"
+)
+
+// Queue is where dispatched tasks go.
+type Queue interface {
+	Send(ctx context.Context, jobID int32, body string) error
+}
+
+// TxQueue is a Queue kept in Postgres, such as the outbox. A task added
+// with SendTx is only queued if tx commits; Notify is called after it does.
+type TxQueue interface {
+	Queue
+	SendTx(ctx context.Context, tx pgx.Tx, jobID int32, body string) error
+	Notify()
+}
+
+// SQSQueue sends tasks to the worker's SQS queue.
+type SQSQueue struct {
+	client *sqs.Client
+	name   string
+}
+
+func NewSQSQueue(client *sqs.Client, name string) *SQSQueue {
+	return &SQSQueue{client: client, name: name}
+}
+
+func (q *SQSQueue) Send(ctx context.Context, jobID int32, body string) error {
+	return clients.SendMessage(ctx, q.client, q.name, body)
+}
+
+// MemoryQueue keeps tasks in memory, in the order they were sent. It stands
+// in for SQS when exercising the Dispatcher.
+type MemoryQueue struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{}
+}
+
+func (q *MemoryQueue) Send(ctx context.Context, jobID int32, body string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.messages = append(q.messages, body)
+	return nil
+}
+
+// Receive takes the oldest task off the queue.
+func (q *MemoryQueue) Receive() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.messages) == 0 {
+		return "", false
+	}
+	body := q.messages[0]
+	q.messages = q.messages[1:]
+	return body, true
+}
+
+func (q *MemoryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
@@ -0,0 +1,169 @@
+package dispatch
+
+import "sort"
+
+// Lanes separate jobs by how urgently someone is waiting for them.
+const (
+	// LaneInteractive is a single upload whose author watches the dashboard.
+	LaneInteractive = "interactive"
+	// LaneBulk is an entry of an archive upload or a git import.
+	LaneBulk = "bulk"
+	// LaneScheduled is re-summarization nobody is waiting for.
+	LaneScheduled = "scheduled"
+)
+
+// DefaultWeights is how many jobs each lane gets per round when all of them
+// have work waiting.
+var DefaultWeights = map[string]int{
+	LaneInteractive: 6,
+	LaneBulk:        3,
+	LaneScheduled:   1,
+}
+
+var lanes = []string{LaneInteractive, LaneBulk, LaneScheduled}
+
+// ValidLane reports whether lane is one of the known lanes.
+func ValidLane(lane string) bool {
+	for _, l := range lanes {
+		if l == lane {
+			return true
+		}
+	}
+	return false
+}
+
+// Candidate is the next waiting job of one user in one lane.
+type Candidate struct {
+	JobID    int32
+	UserID   int32
+	Lane     string
+	Priority int32
+}
+
+// Scheduler decides which waiting job goes next. It is not safe for
+// concurrent use; the Dispatcher serializes calls.
+//
+// Jobs an admin bumped (priority above zero) go first, highest priority
+// first. The rest is shared between the lanes by smooth weighted
+// round-robin, and within a lane between users by round-robin, so a user
+// with hundreds of jobs waits their turn like everyone else. Deprioritized
+// jobs (priority below zero) only go when nothing else is waiting.
+type Scheduler struct {
+	weights map[string]int
+	credit  map[string]int
+	served  map[int32]uint64
+	seq     uint64
+}
+
+func NewScheduler(weights map[string]int) *Scheduler {
+	if weights == nil {
+		weights = DefaultWeights
+	}
+	return &Scheduler{
+		weights: weights,
+		credit:  map[string]int{},
+		served:  map[int32]uint64{},
+	}
+}
+
+// Next picks among the heads of the per-user, per-lane queues.
+func (s *Scheduler) Next(candidates []Candidate) (Candidate, bool) {
+	if len(candidates) == 0 {
+		return Candidate{}, false
+	}
+
+	var bumped, normal []Candidate
+	for _, c := range candidates {
+		switch {
+		case c.Priority > 0:
+			bumped = append(bumped, c)
+		case c.Priority == 0:
+			normal = append(normal, c)
+		}
+	}
+
+	var next Candidate
+	switch {
+	case len(bumped) > 0:
+		next = s.highest(bumped)
+	case len(normal) > 0:
+		next = s.fairest(s.lane(normal))
+	default:
+		next = s.highest(candidates)
+	}
+
+	s.seq++
+	s.served[next.UserID] = s.seq
+	return next, true
+}
+
+// lane runs one step of smooth weighted round-robin over the lanes that
+// have candidates and returns the candidates of the chosen one.
+func (s *Scheduler) lane(candidates []Candidate) []Candidate {
+	byLane := map[string][]Candidate{}
+	for _, c := range candidates {
+		byLane[c.Lane] = append(byLane[c.Lane], c)
+	}
+	if len(byLane) == 1 {
+		return candidates
+	}
+
+	total := 0
+	chosen := ""
+	for _, l := range s.order(byLane) {
+		w := max(s.weights[l], 1)
+		total += w
+		s.credit[l] += w
+		if chosen == "" || s.credit[l] > s.credit[chosen] {
+			chosen = l
+		}
+	}
+	s.credit[chosen] -= total
+	return byLane[chosen]
+}
+
+// order lists the known lanes first, in their usual order, and then any
+// other lane an older or newer backend may have written.
+func (s *Scheduler) order(byLane map[string][]Candidate) []string {
+	var res, other []string
+	for _, l := range lanes {
+		if _, ok := byLane[l]; ok {
+			res = append(res, l)
+		}
+	}
+	for l := range byLane {
+		if !ValidLane(l) {
+			other = append(other, l)
+		}
+	}
+	sort.Strings(other)
+	return append(res, other...)
+}
+
+// fairest picks the candidate whose user was served longest ago.
+func (s *Scheduler) fairest(candidates []Candidate) Candidate {
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		cs, bs := s.served[c.UserID], s.served[best.UserID]
+		if cs < bs || (cs == bs && c.JobID < best.JobID) {
+			best = c
+		}
+	}
+	return best
+}
+
+// highest picks the candidate with the highest priority, falling back to
+// fairness among equals.
+func (s *Scheduler) highest(candidates []Candidate) Candidate {
+	top := candidates[0].Priority
+	for _, c := range candidates[1:] {
+		top = max(top, c.Priority)
+	}
+	var tied []Candidate
+	for _, c := range candidates {
+		if c.Priority == top {
+			tied = append(tied, c)
+		}
+	}
+	return s.fairest(tied)
+}
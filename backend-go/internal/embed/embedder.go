@@ -0,0 +1,79 @@
+package embed
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Embedder turns texts into vectors whose cosine similarity reflects how
+// related the texts are. Vectors from different models can't be compared,
+// so everything stored is tagged with Model.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Model() string
+}
+
+const (
+	ProviderHash   = "hash"
+	ProviderOpenAI = "openai"
+)
+
+// New builds the embedder for provider. The hashing embedder is the default
+// since it needs no network access.
+func New(provider, baseURL, apiKey, model string) (Embedder, error) {
+	switch provider {
+	case "", ProviderHash:
+		return NewHashEmbedder(DefaultHashDimensions), nil
+	case ProviderOpenAI:
+		return NewOpenAIEmbedder(baseURL, apiKey, model), nil
+	default:
+		return nil, fmt.Errorf("unknown embeddings provider %q", provider)
+	}
+}
+
+// normalize scales v to unit length in place, so cosine similarity becomes
+// a dot product.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+func dot(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// centroid averages unit vectors into a unit vector pointing at their
+// common direction.
+func centroid(vectors [][]float32) []float32 {
+	if len(vectors) == 0 {
+		return nil
+	}
+	c := make([]float32, len(vectors[0]))
+	for _, v := range vectors {
+		if len(v) != len(c) {
+			continue
+		}
+		for i := range v {
+			c[i] += v[i]
+		}
+	}
+	return normalize(c)
+}
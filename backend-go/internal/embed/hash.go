@@ -0,0 +1,61 @@
+package embed
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+const DefaultHashDimensions = 256
+
+// HashEmbedder is a deterministic, offline embedder based on feature
+// hashing of words and word pairs. It only captures vocabulary overlap, not
+// meaning, but keeps semantic search and related files working without an
+// embeddings API.
+type HashEmbedder struct {
+	dims int
+}
+
+func NewHashEmbedder(dims int) *HashEmbedder {
+	return &HashEmbedder{dims: dims}
+}
+
+func (e *HashEmbedder) Model() string {
+	return fmt.Sprintf("hash-%d", e.dims)
+}
+
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = e.embed(text)
+	}
+	return out, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	v := make([]float32, e.dims)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		e.add(v, w, 1)
+		if i > 0 {
+			e.add(v, words[i-1]+" "+w, 0.5)
+		}
+	}
+	return normalize(v)
+}
+
+// add hashes feature into one dimension with a hash-derived sign, so
+// collisions cancel out on average instead of piling up.
+func (e *HashEmbedder) add(v []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	v[sum%uint64(e.dims)] += weight
+}
@@ -0,0 +1,209 @@
+package embed
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	sqlc "backend-go/internal/db/sqlc"
+)
+
+// Hit is the best matching chunk of one document.
+type Hit struct {
+	DocumentID int32
+	ChunkID    int32
+	Score      float32
+}
+
+type Entry struct {
+	ChunkID int32
+	Vector  []float32
+}
+
+// Index finds the chunks nearest to a query vector among the documents a
+// user can read.
+type Index interface {
+	// Search returns at most one hit per document, best first. Hits from
+	// exclude are skipped.
+	Search(ctx context.Context, userID int32, vec []float32, limit int, exclude int32) ([]Hit, error)
+	// Put replaces the entries of a document.
+	Put(documentID int32, entries []Entry)
+	Remove(documentID int32)
+}
+
+// MemoryIndex keeps every chunk vector of one model in memory and scans the
+// readable ones on each query. It is used when the database has no pgvector.
+// Other instances embed documents too, so it is reloaded every
+// RefreshInterval to pick up their chunks.
+type MemoryIndex struct {
+	queries *sqlc.Queries
+
+	mu   sync.RWMutex
+	docs map[int32][]Entry
+	// changed holds the documents put or removed while a load runs, whose
+	// entries are newer than what the load read.
+	changed map[int32]bool
+}
+
+// RefreshInterval is how often a MemoryIndex is reloaded from the database.
+const RefreshInterval = time.Minute
+
+func NewMemoryIndex(queries *sqlc.Queries) *MemoryIndex {
+	return &MemoryIndex{
+		queries: queries,
+		docs:    make(map[int32][]Entry),
+	}
+}
+
+// Load replaces the index with the chunks stored for model.
+func (m *MemoryIndex) Load(ctx context.Context, model string) error {
+	m.mu.Lock()
+	m.changed = make(map[int32]bool)
+	m.mu.Unlock()
+
+	rows, err := m.queries.ListChunkEmbeddings(ctx, model)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed := m.changed
+	m.changed = nil
+	if err != nil {
+		return err
+	}
+	docs := make(map[int32][]Entry)
+	for _, row := range rows {
+		if !changed[row.DocumentID] {
+			docs[row.DocumentID] = append(docs[row.DocumentID], Entry{ChunkID: row.ID, Vector: row.Embedding})
+		}
+	}
+	for id := range changed {
+		if entries, ok := m.docs[id]; ok {
+			docs[id] = entries
+		}
+	}
+	m.docs = docs
+	return nil
+}
+
+// Refresh reloads the index every RefreshInterval until ctx is done.
+func (m *MemoryIndex) Refresh(ctx context.Context, model string) {
+	ticker := time.NewTicker(RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Load(ctx, model); err != nil && ctx.Err() == nil {
+				slog.Error("failed to refresh embeddings", "error", err)
+			}
+		}
+	}
+}
+
+func (m *MemoryIndex) Search(ctx context.Context, userID int32, vec []float32, limit int, exclude int32) ([]Hit, error) {
+	readable, err := m.queries.ListReadableDocumentIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	hits := make([]Hit, 0, len(readable))
+	for _, id := range readable {
+		if id == exclude {
+			continue
+		}
+		entries := m.docs[id]
+		if len(entries) == 0 {
+			continue
+		}
+		best := Hit{DocumentID: id, Score: -2}
+		for _, e := range entries {
+			if s := dot(vec, e.Vector); s > best.Score {
+				best.ChunkID, best.Score = e.ChunkID, s
+			}
+		}
+		hits = append(hits, best)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].DocumentID > hits[j].DocumentID
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+func (m *MemoryIndex) Put(documentID int32, entries []Entry) {
+	m.mu.Lock()
+	m.docs[documentID] = entries
+	m.markChanged(documentID)
+	m.mu.Unlock()
+}
+
+func (m *MemoryIndex) Remove(documentID int32) {
+	m.mu.Lock()
+	delete(m.docs, documentID)
+	m.markChanged(documentID)
+	m.mu.Unlock()
+}
+
+// markChanged records a change during a load. m.mu must be held.
+func (m *MemoryIndex) markChanged(documentID int32) {
+	if m.changed != nil {
+		m.changed[documentID] = true
+	}
+}
+
+// VectorIndex leaves nearest-neighbour search to pgvector. Chunks are read
+// straight from document_chunks, so Put and Remove have nothing to do.
+type VectorIndex struct {
+	queries *sqlc.Queries
+	model   string
+}
+
+func NewVectorIndex(queries *sqlc.Queries, model string) *VectorIndex {
+	return &VectorIndex{queries: queries, model: model}
+}
+
+// overfetch is how many chunks are read per requested document, since the
+// best few chunks often come from the same document.
+const overfetch = 8
+
+func (v *VectorIndex) Search(ctx context.Context, userID int32, vec []float32, limit int, exclude int32) ([]Hit, error) {
+	rows, err := v.queries.SearchChunksByVector(ctx, sqlc.SearchChunksByVectorParams{
+		Query:     vec,
+		Model:     v.model,
+		ExcludeID: exclude,
+		UserID:    userID,
+		RowLimit:  int32(limit * overfetch),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, 0, limit)
+	seen := make(map[int32]bool)
+	for _, row := range rows {
+		if seen[row.DocumentID] {
+			continue
+		}
+		seen[row.DocumentID] = true
+		hits = append(hits, Hit{DocumentID: row.DocumentID, ChunkID: row.ID, Score: row.Score})
+		if len(hits) == limit {
+			break
+		}
+	}
+	return hits, nil
+}
+
+func (v *VectorIndex) Put(documentID int32, entries []Entry) {}
+
+func (v *VectorIndex) Remove(documentID int32) {}
@@ -0,0 +1,348 @@
+package embed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"backend-go/internal/chunk"
+	clients "backend-go/internal/clients"
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/logging"
+	"backend-go/internal/storage"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Match is a document found by a semantic lookup, with the chunk that
+// matched best.
+type Match struct {
+	DocumentID int32
+	Path       string
+	Score      float32
+	Heading    string
+	StartLine  int32
+	EndLine    int32
+	Content    string
+}
+
+// Indexer chunks and embeds documents in the background and answers
+// nearest-neighbour queries over them.
+type Indexer struct {
+	pool       *pgxpool.Pool
+	queries    *sqlc.Queries
+	embedder   Embedder
+	s3Client   *s3.Client
+	bucketName string
+	index      Index
+	queue      chan int32
+}
+
+func NewIndexer(pool *pgxpool.Pool, queries *sqlc.Queries, embedder Embedder, s3Client *s3.Client, bucketName string) *Indexer {
+	return &Indexer{
+		pool:       pool,
+		queries:    queries,
+		embedder:   embedder,
+		s3Client:   s3Client,
+		bucketName: bucketName,
+		queue:      make(chan int32, 1024),
+	}
+}
+
+// Start picks pgvector when the extension is installed and the in-memory
+// index otherwise, then embeds documents in the background. Documents whose
+// current content has no embeddings yet are picked up first, which also
+// covers anything dropped from the queue before a restart.
+func (ix *Indexer) Start(ctx context.Context) error {
+	model := ix.embedder.Model()
+
+	hasVector, err := ix.queries.HasVectorExtension(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check for pgvector: %w", err)
+	}
+	if hasVector {
+		ix.index = NewVectorIndex(ix.queries, model)
+	} else {
+		mem := NewMemoryIndex(ix.queries)
+		if err := mem.Load(ctx, model); err != nil {
+			return fmt.Errorf("failed to load embeddings: %w", err)
+		}
+		go mem.Refresh(ctx, model)
+		ix.index = mem
+	}
+	slog.Info("semantic index started", "model", model, "pgvector", hasVector)
+
+	go func() {
+		pending, err := ix.queries.ListDocumentsToEmbed(ctx, model)
+		if err != nil {
+			slog.Error("failed to list documents to embed", "error", err)
+		}
+		for _, id := range pending {
+			ix.indexDocument(ctx, id)
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case id := <-ix.queue:
+				ix.indexDocument(ctx, id)
+			}
+		}
+	}()
+	return nil
+}
+
+// Enqueue schedules a document for embedding without blocking the caller.
+func (ix *Indexer) Enqueue(documentID int32) {
+	select {
+	case ix.queue <- documentID:
+	default:
+		slog.Warn("embedding queue full, document is indexed on next start", "document_id", documentID)
+	}
+}
+
+// Forget drops a deleted document from the index.
+func (ix *Indexer) Forget(documentID int32) {
+	if ix.index != nil {
+		ix.index.Remove(documentID)
+	}
+}
+
+func (ix *Indexer) indexDocument(ctx context.Context, documentID int32) {
+	model := ix.embedder.Model()
+	ctx = logging.WithJob(ctx, 0, documentID)
+
+	doc, err := ix.queries.GetDocument(ctx, documentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		ix.Forget(documentID)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load document for embedding", "error", err)
+		return
+	}
+
+	current, err := ix.queries.HasCurrentChunks(ctx, sqlc.HasCurrentChunksParams{
+		DocumentID:  doc.ID,
+		Model:       model,
+		ContentHash: doc.BlobHash,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to check embeddings", "error", err)
+		return
+	}
+	if current {
+		return
+	}
+
+	body, err := clients.ReadObject(ctx, ix.s3Client, ix.bucketName, storage.BlobKey(doc.BlobHash))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read document for embedding", "error", err)
+		return
+	}
+
+	chunks := chunk.Split(string(body))
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = embeddingText(doc.Path, c)
+	}
+	vectors, err := ix.embedder.Embed(ctx, texts)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to embed document", "error", err)
+		return
+	}
+
+	entries, err := ix.storeChunks(ctx, doc, model, chunks, vectors)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to store embeddings", "error", err)
+		return
+	}
+	ix.index.Put(doc.ID, entries)
+}
+
+// storeChunks replaces the document's chunks for model in one transaction,
+// so searches never see it half embedded or without chunks.
+func (ix *Indexer) storeChunks(ctx context.Context, doc sqlc.Document, model string, chunks []chunk.Chunk, vectors [][]float32) ([]Entry, error) {
+	tx, err := ix.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := ix.queries.WithTx(tx)
+
+	err = queries.DeleteDocumentChunks(ctx, sqlc.DeleteDocumentChunksParams{DocumentID: doc.ID, Model: model})
+	if err != nil {
+		return nil, fmt.Errorf("failed to clear chunks: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(chunks))
+	for i, c := range chunks {
+		id, err := queries.InsertDocumentChunk(ctx, sqlc.InsertDocumentChunkParams{
+			DocumentID:  doc.ID,
+			ChunkIndex:  int32(c.Index),
+			Heading:     c.Heading,
+			StartLine:   int32(c.StartLine),
+			EndLine:     int32(c.EndLine),
+			Content:     c.Text,
+			ContentHash: doc.BlobHash,
+			Model:       model,
+			Embedding:   vectors[i],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to store chunk %d: %w", c.Index, err)
+		}
+		entries = append(entries, Entry{ChunkID: id, Vector: vectors[i]})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return entries, nil
+}
+
+// embeddingText gives the embedder the file name and heading path along
+// with the chunk, since a section often doesn't repeat its own topic.
+func embeddingText(path string, c chunk.Chunk) string {
+	var b strings.Builder
+	b.WriteString(path)
+	if c.Heading != "" {
+		b.WriteString(" > ")
+		b.WriteString(c.Heading)
+	}
+	b.WriteString("\n\n")
+	b.WriteString(c.Text)
+	return b.String()
+}
+
+// Search finds the readable documents closest in meaning to query.
+func (ix *Indexer) Search(ctx context.Context, userID int32, query string, limit int) ([]Match, error) {
+	vectors, err := ix.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	hits, err := ix.index.Search(ctx, userID, vectors[0], limit, 0)
+	if err != nil {
+		return nil, err
+	}
+	return ix.matches(ctx, hits)
+}
+
+// Related finds the readable documents closest to the centroid of the
+// document's chunks. It returns nothing until the document is embedded.
+func (ix *Indexer) Related(ctx context.Context, userID int32, documentID int32, limit int) ([]Match, error) {
+	vectors, err := ix.queries.ListDocumentEmbeddings(ctx, sqlc.ListDocumentEmbeddingsParams{
+		DocumentID: documentID,
+		Model:      ix.embedder.Model(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) == 0 {
+		return []Match{}, nil
+	}
+	hits, err := ix.index.Search(ctx, userID, centroid(vectors), limit, documentID)
+	if err != nil {
+		return nil, err
+	}
+	return ix.matches(ctx, hits)
+}
+
+func (ix *Indexer) matches(ctx context.Context, hits []Hit) ([]Match, error) {
+	ids := make([]int32, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ChunkID
+	}
+	rows, err := ix.queries.ListChunkHits(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int32]sqlc.ListChunkHitsRow, len(rows))
+	for _, row := range rows {
+		byID[row.ID] = row
+	}
+
+	matches := make([]Match, 0, len(hits))
+	for _, h := range hits {
+		row, ok := byID[h.ChunkID]
+		if !ok {
+			// deleted since the lookup
+			continue
+		}
+		matches = append(matches, Match{
+			DocumentID: row.DocumentID,
+			Path:       row.Path,
+			Score:      h.Score,
+			Heading:    row.Heading,
+			StartLine:  row.StartLine,
+			EndLine:    row.EndLine,
+			Content:    row.Content,
+		})
+	}
+	return matches, nil
+}
+
+// Passage is a chunk of a document picked to answer a question.
+type Passage struct {
+	chunk.Chunk
+	Score float32
+}
+
+// Passages returns the k chunks of doc closest to query, in document order.
+// A document that hasn't been embedded yet is chunked and embedded on the
+// spot instead of waiting for the background queue.
+func (ix *Indexer) Passages(ctx context.Context, doc sqlc.Document, query string, k int) ([]Passage, error) {
+	rows, err := ix.queries.ListDocumentChunks(ctx, sqlc.ListDocumentChunksParams{
+		DocumentID:  doc.ID,
+		Model:       ix.embedder.Model(),
+		ContentHash: doc.BlobHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	passages := make([]Passage, 0, len(rows))
+	vectors := make([][]float32, 0, len(rows))
+	for _, row := range rows {
+		passages = append(passages, Passage{Chunk: chunk.Chunk{
+			Index:     int(row.ChunkIndex),
+			Heading:   row.Heading,
+			StartLine: int(row.StartLine),
+			EndLine:   int(row.EndLine),
+			Text:      row.Content,
+		}})
+		vectors = append(vectors, row.Embedding)
+	}
+
+	texts := []string{query}
+	if len(rows) == 0 {
+		body, err := clients.ReadObject(ctx, ix.s3Client, ix.bucketName, storage.BlobKey(doc.BlobHash))
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range chunk.Split(string(body)) {
+			passages = append(passages, Passage{Chunk: c})
+			texts = append(texts, embeddingText(doc.Path, c))
+		}
+	}
+
+	embedded, err := ix.embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	vectors = append(vectors, embedded[1:]...)
+
+	for i := range passages {
+		passages[i].Score = dot(embedded[0], vectors[i])
+	}
+	sort.SliceStable(passages, func(i, j int) bool { return passages[i].Score > passages[j].Score })
+	if len(passages) > k {
+		passages = passages[:k]
+	}
+	sort.Slice(passages, func(i, j int) bool { return passages[i].Index < passages[j].Index })
+	return passages, nil
+}
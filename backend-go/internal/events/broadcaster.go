@@ -1,41 +1,189 @@
 package events
 
-import "sync"
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"backend-go/internal/metrics"
+)
+
+// MaxPending is how many events a subscription holds for a client that
+// reads them slower than they are published. Past it the subscription is
+// closed, so the client reconnects and fetches the current state.
+const MaxPending = 256
+
+// Event is one server-sent event. Type becomes the SSE "event:" field and
+// Data is the JSON payload.
+type Event struct {
+	Type string
+	Data string
+	// key is set for events that supersede earlier ones with the same key
+	// still waiting to be sent, e.g. the partial summaries of a job.
+	key string
+}
+
+// recipient is implemented by every payload; it names the user the event
+// is for.
+type recipient interface {
+	recipient() string
+}
+
+func (m JobMessage) recipient() string      { return m.UserID }
+func (m ProgressMessage) recipient() string { return m.UserID }
+func (m PartialMessage) recipient() string  { return m.UserID }
+func (m SSEMessage) recipient() string      { return m.UserID }
+func (m BatchMessage) recipient() string    { return m.UserID }
+
+// Subscription receives the events published for one user. Events wait in
+// it until taken, so none are lost to a client that is briefly slow.
+type Subscription struct {
+	userID string
+	ready  chan struct{}
+
+	mu      sync.Mutex
+	pending []Event
+	closed  bool
+}
+
+// Ready is signalled when events are waiting or the subscription is closed.
+func (s *Subscription) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Take returns the waiting events, oldest first, and whether the
+// subscription is still open. Once it is closed, no more events follow the
+// ones returned.
+func (s *Subscription) Take() ([]Event, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := s.pending
+	s.pending = nil
+	return events, !s.closed
+}
+
+// push queues ev, replacing a waiting event it supersedes. It reports false
+// if the subscription is full.
+func (s *Subscription) push(ev Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+	if ev.key != "" {
+		for i := range s.pending {
+			if s.pending[i].key == ev.key {
+				s.pending[i] = ev
+				s.signal()
+				return true
+			}
+		}
+	}
+	if len(s.pending) >= MaxPending {
+		return false
+	}
+	s.pending = append(s.pending, ev)
+	s.signal()
+	return true
+}
+
+func (s *Subscription) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.signal()
+	s.mu.Unlock()
+}
+
+func (s *Subscription) signal() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
 
 type Broadcaster struct {
-	clients map[chan string]bool
+	clients map[*Subscription]bool
+	closed  bool
 	mu      sync.Mutex
 }
 
 func NewBroadcaster() *Broadcaster {
 	return &Broadcaster{
-		clients: make(map[chan string]bool),
+		clients: make(map[*Subscription]bool),
 	}
 }
 
-func (b *Broadcaster) Subscribe() chan string {
-	ch := make(chan string, 1)
+// Subscribe returns a subscription to the events for userID. It is closed
+// when the broadcaster is, right away if it already was.
+func (b *Broadcaster) Subscribe(userID string) *Subscription {
+	s := &Subscription{userID: userID, ready: make(chan struct{}, 1)}
 	b.mu.Lock()
-	b.clients[ch] = true
+	if b.closed {
+		s.close()
+	} else {
+		b.clients[s] = true
+		metrics.SSESubscribers.Inc()
+	}
 	b.mu.Unlock()
-	return ch
+	return s
 }
 
-func (b *Broadcaster) Unsubscribe(ch chan string) {
+func (b *Broadcaster) Unsubscribe(s *Subscription) {
 	b.mu.Lock()
-	delete(b.clients, ch)
-	close(ch)
+	b.remove(s)
 	b.mu.Unlock()
 }
 
-func (b *Broadcaster) Publish(msg string) {
+// Publish sends data to the subscriptions of the user it is for. Progress
+// and partial summaries of a job replace the ones not yet sent; other
+// events are all kept, in order.
+func (b *Broadcaster) Publish(eventType string, data any) {
+	to, ok := data.(recipient)
+	if !ok {
+		slog.Error("event has no recipient, not published", "event", eventType)
+		return
+	}
+	payload, _ := json.Marshal(data)
+	msg := Event{Type: eventType, Data: string(payload)}
+	switch d := data.(type) {
+	case ProgressMessage:
+		msg.key = fmt.Sprintf("%s/%d", eventType, d.JobID)
+	case PartialMessage:
+		msg.key = fmt.Sprintf("%s/%d", eventType, d.JobID)
+	}
+
 	b.mu.Lock()
-	for ch := range b.clients {
-		select {
-		case ch <- msg:
-		default:
-			// if channel is full, drop message
+	for s := range b.clients {
+		if s.userID != to.recipient() {
+			continue
+		}
+		if !s.push(msg) {
+			// too far behind; the client reconnects and refetches
+			slog.Warn("event stream fell behind, closing it", "user_id", s.userID, "pending", MaxPending)
+			metrics.SSEDropped.WithLabelValues(eventType).Inc()
+			b.remove(s)
 		}
 	}
 	b.mu.Unlock()
 }
+
+// Close ends every subscription, e.g. when the server shuts down, so the
+// streams can tell their clients to reconnect.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	for s := range b.clients {
+		b.remove(s)
+	}
+	b.closed = true
+	b.mu.Unlock()
+}
+
+// remove closes s if it is subscribed. b.mu must be held.
+func (b *Broadcaster) remove(s *Subscription) {
+	if b.clients[s] {
+		delete(b.clients, s)
+		s.close()
+		metrics.SSESubscribers.Dec()
+	}
+}
@@ -0,0 +1,88 @@
+package events
+
+import (
+	"time"
+
+	"backend-go/internal/structured"
+)
+
+// SSE event types. Every payload also carries its type in a "type" field.
+const (
+	JobAccepted    = "job_accepted"
+	JobStarted     = "job_started"
+	JobProgress    = "job_progress"
+	JobPartial     = "job_partial"
+	JobCompleted   = "job_completed"
+	JobFailed      = "job_failed"
+	JobCancelled   = "job_cancelled"
+	BatchCompleted = "batch_completed"
+	// Reconnect is the last event of a stream closed by the server, e.g.
+	// because it is shutting down.
+	Reconnect = "reconnect"
+)
+
+// ReconnectDelay is how long clients wait before opening a new stream after
+// a reconnect event.
+const ReconnectDelay = time.Second
+
+// JobMessage is the payload of job_accepted, job_started, job_failed and
+// job_cancelled.
+type JobMessage struct {
+	Type       string `json:"type"`
+	UserID     string `json:"userId"`
+	DocumentID int32  `json:"documentId"`
+	JobID      int32  `json:"jobId"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ProgressMessage is sent after each chunk of a long document is
+// summarized. Chunk counts from 1.
+type ProgressMessage struct {
+	Type       string `json:"type"`
+	UserID     string `json:"userId"`
+	DocumentID int32  `json:"documentId"`
+	JobID      int32  `json:"jobId"`
+	Chunk      int    `json:"chunk"`
+	Chunks     int    `json:"chunks"`
+}
+
+// PartialMessage carries the summary generated so far. The queue it travels
+// through doesn't keep order, so clients should ignore a Seq lower than the
+// last one they saw.
+type PartialMessage struct {
+	Type       string `json:"type"`
+	UserID     string `json:"userId"`
+	DocumentID int32  `json:"documentId"`
+	JobID      int32  `json:"jobId"`
+	Seq        int    `json:"seq"`
+	Text       string `json:"text"`
+}
+
+// SSEMessage is the payload of job_completed.
+type SSEMessage struct {
+	Type       string `json:"type"`
+	UserID     string `json:"userId"`
+	DocumentID int32  `json:"documentId,omitempty"`
+	JobID      int32  `json:"jobId,omitempty"`
+	Cached     bool   `json:"cached,omitempty"`
+	Content    string `json:"content"`
+	// Structured is set for jobs that asked for a structured summary;
+	// Content is then its text rendering.
+	Structured *structured.Summary `json:"structured,omitempty"`
+}
+
+type BatchMessage struct {
+	Type      string `json:"type"`
+	UserID    string `json:"userId"`
+	BatchID   int32  `json:"batchId"`
+	Name      string `json:"name"`
+	Total     int32  `json:"total"`
+	Completed int32  `json:"completed"`
+	Failed    int32  `json:"failed"`
+}
+
+// ReconnectMessage is the payload of reconnect.
+type ReconnectMessage struct {
+	Type    string `json:"type"`
+	RetryMs int64  `json:"retryMs"`
+}
@@ -0,0 +1,94 @@
+package gitimport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidRef = errors.New("invalid git ref")
+
+// Repo runs read-only git plumbing commands against a bare repository or a
+// working tree. Nothing is ever checked out; content is read from objects.
+type Repo struct {
+	path string
+}
+
+type File struct {
+	Path string
+	Sha  string
+	Size int64
+}
+
+func Open(ctx context.Context, path string) (*Repo, error) {
+	r := &Repo{path: path}
+	if _, err := r.git(ctx, "rev-parse", "--git-dir"); err != nil {
+		return nil, fmt.Errorf("not a git repository: %w", err)
+	}
+	return r, nil
+}
+
+// Resolve turns a branch, tag or sha into a full commit sha.
+func (r *Repo) Resolve(ctx context.Context, ref string) (string, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return "", ErrInvalidRef
+	}
+	out, err := r.git(ctx, "rev-parse", "--verify", "--quiet", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidRef, ref)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Files lists every blob in the commit's tree with its git object sha.
+func (r *Repo) Files(ctx context.Context, commit string) ([]File, error) {
+	out, err := r.git(ctx, "ls-tree", "-r", "-z", "--long", commit)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []File{}
+	for _, line := range bytes.Split(out, []byte{0}) {
+		if len(line) == 0 {
+			continue
+		}
+		// <mode> SP <type> SP <sha> SP+ <size> TAB <path>
+		meta, path, ok := strings.Cut(string(line), "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 4 || fields[1] != "blob" {
+			continue
+		}
+		// symlinks are blobs too; only regular files are imported
+		if fields[0] != "100644" && fields[0] != "100755" {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			continue
+		}
+		files = append(files, File{Path: path, Sha: fields[2], Size: size})
+	}
+	return files, nil
+}
+
+func (r *Repo) ReadBlob(ctx context.Context, sha string) ([]byte, error) {
+	return r.git(ctx, "cat-file", "blob", sha)
+}
+
+func (r *Repo) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", r.path}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
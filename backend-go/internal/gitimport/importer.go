@@ -0,0 +1,291 @@
+package gitimport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"backend-go/internal/archive"
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/dispatch"
+	"backend-go/internal/events"
+	"backend-go/internal/ingest"
+	"backend-go/internal/quota"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrDisabled       = errors.New("git import is disabled")
+	ErrOutsideRoot    = errors.New("repository path is outside the import root")
+	ErrSyncInProgress = errors.New("repository sync already in progress")
+)
+
+var Extensions = []string{".md", ".markdown"}
+
+const (
+	FileAdded     = "added"
+	FileUpdated   = "updated"
+	FileUnchanged = "unchanged"
+	FileRemoved   = "removed"
+	FileSkipped   = "skipped"
+	FileFailed    = "failed"
+)
+
+type FileResult struct {
+	Path       string `json:"path"`
+	Status     string `json:"status"`
+	DocumentID int32  `json:"documentId,omitempty"`
+	JobID      int32  `json:"jobId,omitempty"`
+	Cached     bool   `json:"cached,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+type SyncResult struct {
+	Commit    string       `json:"commit"`
+	BatchID   int32        `json:"batchId"`
+	Added     int          `json:"added"`
+	Updated   int          `json:"updated"`
+	Unchanged int          `json:"unchanged"`
+	Removed   int          `json:"removed"`
+	Files     []FileResult `json:"files"`
+}
+
+// Importer keeps repository documents in step with a git ref. Only files
+// whose git blob sha changed since the last sync are re-ingested, so
+// unchanged docs never cost another summarization.
+type Importer struct {
+	queries     *sqlc.Queries
+	ingester    *ingest.Service
+	broadcaster *events.Broadcaster
+	root        string
+
+	mu      sync.Mutex
+	syncing map[int32]bool
+}
+
+// NewImporter only allows repositories below root. An empty root disables
+// the feature, since the path would otherwise reach anywhere on the host.
+func NewImporter(queries *sqlc.Queries, ingester *ingest.Service, broadcaster *events.Broadcaster, root string) *Importer {
+	return &Importer{
+		queries:     queries,
+		ingester:    ingester,
+		broadcaster: broadcaster,
+		root:        root,
+		syncing:     make(map[int32]bool),
+	}
+}
+
+// ResolvePath maps a user supplied path, absolute or relative to the import
+// root, to a real path inside the root.
+func (im *Importer) ResolvePath(p string) (string, error) {
+	if im.root == "" {
+		return "", ErrDisabled
+	}
+	root, err := filepath.EvalSymlinks(im.root)
+	if err != nil {
+		return "", fmt.Errorf("invalid import root: %w", err)
+	}
+
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", fmt.Errorf("repository path not found: %w", err)
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrOutsideRoot
+	}
+	return resolved, nil
+}
+
+// Check reports whether ref names a commit of the repository at repoPath, as
+// resolved by ResolvePath, so a repository that can't be imported isn't
+// registered.
+func (im *Importer) Check(ctx context.Context, repoPath string, ref string) error {
+	repo, err := Open(ctx, repoPath)
+	if err != nil {
+		return err
+	}
+	_, err = repo.Resolve(ctx, ref)
+	return err
+}
+
+func (im *Importer) Sync(ctx context.Context, repository sqlc.Repository, ref string) (SyncResult, error) {
+	if !im.lock(repository.ID) {
+		return SyncResult{}, ErrSyncInProgress
+	}
+	defer im.unlock(repository.ID)
+
+	// re-check on every sync; the root may have changed since registration
+	repoPath, err := im.ResolvePath(repository.Path)
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	repo, err := Open(ctx, repoPath)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	commit, err := repo.Resolve(ctx, ref)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	files, err := repo.Files(ctx, commit)
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	docs, err := im.queries.ListRepositoryDocuments(ctx, pgtype.Int4{Int32: repository.ID, Valid: true})
+	if err != nil {
+		return SyncResult{}, fmt.Errorf("failed to load repository documents: %w", err)
+	}
+	existing := make(map[string]sqlc.Document, len(docs))
+	for _, doc := range docs {
+		existing[doc.Path] = doc
+	}
+
+	batch, err := im.queries.CreateBatch(ctx, sqlc.CreateBatchParams{
+		UserID: repository.UserID,
+		Name:   fmt.Sprintf("%s@%s", filepath.Base(repository.Path), commit[:12]),
+	})
+	if err != nil {
+		return SyncResult{}, fmt.Errorf("failed to create batch: %w", err)
+	}
+
+	result := SyncResult{Commit: commit, BatchID: batch.ID, Files: []FileResult{}}
+	var total, cached int32
+	seen := make(map[string]bool, len(files))
+
+	for _, file := range files {
+		if !hasExtension(file.Path) {
+			continue
+		}
+		seen[file.Path] = true
+
+		doc, known := existing[file.Path]
+		if known && doc.SourceSha.Valid && doc.SourceSha.String == file.Sha {
+			result.Unchanged++
+			result.Files = append(result.Files, FileResult{Path: file.Path, Status: FileUnchanged, DocumentID: doc.ID})
+			continue
+		}
+		if file.Size > archive.DefaultLimits.MaxEntrySize {
+			result.Files = append(result.Files, FileResult{Path: file.Path, Status: FileSkipped, Reason: archive.ErrEntryTooLarge.Error()})
+			continue
+		}
+
+		content, err := repo.ReadBlob(ctx, file.Sha)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to read file", "path", file.Path, "commit", commit, "error", err)
+			result.Files = append(result.Files, FileResult{Path: file.Path, Status: FileFailed, Reason: "failed to read file"})
+			continue
+		}
+
+		req := ingest.Request{
+			UserID:       repository.UserID,
+			Path:         file.Path,
+			Body:         bytes.NewReader(content),
+			BatchID:      batch.ID,
+			RepositoryID: repository.ID,
+			SourceSha:    file.Sha,
+			CommitSha:    commit,
+			Lane:         dispatch.LaneBulk,
+		}
+		status := FileAdded
+		if known {
+			req.DocumentID = doc.ID
+			status = FileUpdated
+		}
+
+		res, err := im.ingester.Ingest(ctx, req)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to import file", "path", file.Path, "commit", commit, "error", err)
+			reason := "failed to store file"
+			var exceeded *quota.ExceededError
+			if errors.As(err, &exceeded) {
+				reason = exceeded.Error()
+			}
+			result.Files = append(result.Files, FileResult{Path: file.Path, Status: FileFailed, Reason: reason})
+			continue
+		}
+
+		total++
+		fr := FileResult{Path: file.Path, Status: status, DocumentID: res.Document.ID, Cached: res.Cached}
+		if res.Cached {
+			cached++
+		} else {
+			fr.JobID = res.Job.ID
+		}
+		if known {
+			result.Updated++
+		} else {
+			result.Added++
+		}
+		result.Files = append(result.Files, fr)
+	}
+
+	for p, doc := range existing {
+		if seen[p] {
+			continue
+		}
+		if err := im.ingester.Delete(ctx, repository.UserID, doc.ID); err != nil {
+			slog.ErrorContext(ctx, "failed to remove file from repository", "path", p, "repository_id", repository.ID, "error", err)
+			continue
+		}
+		result.Removed++
+		result.Files = append(result.Files, FileResult{Path: p, Status: FileRemoved, DocumentID: doc.ID})
+	}
+
+	finished, done, err := im.ingester.SealBatch(ctx, batch.ID, total, cached)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to seal batch", "batch_id", batch.ID, "error", err)
+	} else if done {
+		ingest.PublishBatchCompleted(im.broadcaster, finished)
+	}
+
+	err = im.queries.UpdateRepositorySync(ctx, sqlc.UpdateRepositorySyncParams{
+		ID:         repository.ID,
+		Ref:        ref,
+		LastCommit: pgtype.Text{String: commit, Valid: true},
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to record sync: %w", err)
+	}
+
+	return result, nil
+}
+
+func (im *Importer) lock(id int32) bool {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if im.syncing[id] {
+		return false
+	}
+	im.syncing[id] = true
+	return true
+}
+
+func (im *Importer) unlock(id int32) {
+	im.mu.Lock()
+	delete(im.syncing, id)
+	im.mu.Unlock()
+}
+
+func hasExtension(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
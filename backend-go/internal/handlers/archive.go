@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+
+	"backend-go/internal/archive"
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/dispatch"
+	"backend-go/internal/events"
+	"backend-go/internal/ingest"
+	"backend-go/internal/quota"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ArchiveEntryResult struct {
+	Path       string `json:"path"`
+	Status     string `json:"status"`
+	DocumentID int32  `json:"documentId,omitempty"`
+	JobID      int32  `json:"jobId,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+const (
+	entryQueued  = "queued"
+	entryCached  = "cached"
+	entrySkipped = "skipped"
+	entryFailed  = "failed"
+)
+
+func ArchiveUploadHandler(queries *sqlc.Queries, ingester *ingest.Service, broadcaster *events.Broadcaster) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to get file"})
+			return
+		}
+
+		prefix := ""
+		if p := strings.TrimSpace(c.PostForm("prefix")); p != "" {
+			cleaned, ok := archive.CleanPath(p)
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid prefix"})
+				return
+			}
+			prefix = cleaned
+		}
+
+		tags := ingest.NormalizeTags(c.PostFormArray("tags"))
+
+		// check the overrides once instead of failing every entry
+		summary := summaryOptionsFromForm(c)
+		if _, err := ingester.Prompt(c, userID, summary); err != nil {
+			if errors.Is(err, ingest.ErrInvalidOptions) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load summary options"})
+			return
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file"})
+			return
+		}
+		defer src.Close()
+
+		batch, err := queries.CreateBatch(c, sqlc.CreateBatchParams{
+			UserID: userID,
+			Name:   file.Filename,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create batch"})
+			return
+		}
+
+		results := []ArchiveEntryResult{}
+		var total, cached int32
+
+		extractErr := archive.Extract(file.Filename, src, file.Size, archive.DefaultLimits, func(entry archive.Entry) error {
+			if entry.Skipped != "" {
+				results = append(results, ArchiveEntryResult{
+					Path:   entry.Path,
+					Status: entrySkipped,
+					Reason: entry.Skipped,
+				})
+				return nil
+			}
+
+			docPath := entry.Path
+			if prefix != "" {
+				docPath = path.Join(prefix, entry.Path)
+			}
+
+			result, err := ingester.Ingest(c, ingest.Request{
+				UserID:  userID,
+				Path:    docPath,
+				Body:    entry.Body,
+				BatchID: batch.ID,
+				Tags:    tags,
+				Summary: summary,
+				Lane:    dispatch.LaneBulk,
+			})
+			if err != nil {
+				if errors.Is(err, archive.ErrTooLarge) {
+					return err
+				}
+				slog.ErrorContext(c, "archive entry failed", "path", entry.Path, "batch_id", batch.ID, "error", err)
+				reason := "failed to store file"
+				var exceeded *quota.ExceededError
+				if errors.Is(err, archive.ErrEntryTooLarge) {
+					reason = archive.ErrEntryTooLarge.Error()
+				} else if errors.As(err, &exceeded) {
+					reason = exceeded.Error()
+				}
+				results = append(results, ArchiveEntryResult{
+					Path:   docPath,
+					Status: entryFailed,
+					Reason: reason,
+				})
+				return nil
+			}
+
+			total++
+			res := ArchiveEntryResult{
+				Path:       docPath,
+				Status:     entryQueued,
+				DocumentID: result.Document.ID,
+			}
+			if result.Cached {
+				cached++
+				res.Status = entryCached
+			} else {
+				res.JobID = result.Job.ID
+			}
+			results = append(results, res)
+			return nil
+		})
+
+		// seal even after a partial extraction so the batch can still finish
+		// for the documents that were created
+		finished, done, err := ingester.SealBatch(context.WithoutCancel(c), batch.ID, total, cached)
+		if err != nil {
+			slog.ErrorContext(c, "failed to seal batch", "batch_id", batch.ID, "error", err)
+		} else if done {
+			ingest.PublishBatchCompleted(broadcaster, finished)
+		}
+
+		if extractErr != nil {
+			status := http.StatusBadRequest
+			if errors.Is(extractErr, archive.ErrTooManyEntries) || errors.Is(extractErr, archive.ErrTooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.JSON(status, gin.H{
+				"error":   extractErr.Error(),
+				"batchId": batch.ID,
+				"entries": results,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "archive uploaded successfully",
+			"batchId": batch.ID,
+			"total":   total,
+			"entries": results,
+		})
+	}
+}
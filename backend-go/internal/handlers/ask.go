@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend-go/internal/ask"
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/quota"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const maxQuestionLength = 2000
+
+type AskRequest struct {
+	Question string `json:"question"`
+	// ThreadID continues an earlier conversation; zero starts a new one.
+	ThreadID int32 `json:"threadId"`
+}
+
+type AskMessageItem struct {
+	ID        int32           `json:"id"`
+	Role      string          `json:"role"`
+	Content   string          `json:"content"`
+	Citations json.RawMessage `json:"citations"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// AskHandler answers a question about a document and streams the answer as
+// server-sent events: "thread" with the thread id, "sources" with the
+// excerpts handed to the model, a "token" per piece of the answer and
+// finally "done" with the citations the answer used, or "error".
+func AskHandler(queries *sqlc.Queries, quotas *quota.Service, asker *ask.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+			return
+		}
+
+		var req AskRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		question := strings.TrimSpace(req.Question)
+		if question == "" || len(question) > maxQuestionLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "question must be between 1 and 2000 characters"})
+			return
+		}
+
+		doc, ok := readableDocument(c, queries, userID, int32(id))
+		if !ok {
+			return
+		}
+
+		// answers are charged to the asker's monthly spend like summaries
+		if err := quotas.CheckSpend(c, userID); err != nil {
+			if !writeQuotaError(c, err) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check quota"})
+			}
+			return
+		}
+
+		thread, err := asker.Thread(c, userID, doc.ID, req.ThreadID, question)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "thread not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start thread"})
+			return
+		}
+
+		// an answer can take longer to stream than the server's write timeout
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+			slog.WarnContext(c, "failed to lift write deadline of answer stream", "error", err)
+		}
+		c.Writer.Header().Set("Content-Type", "text/event-stream")
+		c.Writer.Header().Set("Cache-Control", "no-cache")
+		c.Writer.Header().Set("Connection", "keep-alive")
+
+		send := func(event string, data any) error {
+			c.SSEvent(event, data)
+			c.Writer.Flush()
+			return c.Request.Context().Err()
+		}
+		send("thread", gin.H{"threadId": thread.ID})
+
+		answer, err := asker.Ask(c, thread, doc, question, send)
+		if err != nil {
+			slog.ErrorContext(c, "failed to answer question", "document_id", doc.ID, "error", err)
+			send("error", gin.H{"error": "failed to answer the question"})
+			return
+		}
+
+		send("done", gin.H{
+			"threadId":  thread.ID,
+			"messageId": answer.Message.ID,
+			"citations": answer.Citations,
+		})
+	}
+}
+
+func ListAskThreadsHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+			return
+		}
+		doc, ok := readableDocument(c, queries, userID, int32(id))
+		if !ok {
+			return
+		}
+
+		threads, err := queries.ListAskThreads(c, sqlc.ListAskThreadsParams{
+			DocumentID: doc.ID,
+			UserID:     userID,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list threads"})
+			return
+		}
+		if threads == nil {
+			threads = []sqlc.AskThread{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"threads": threads})
+	}
+}
+
+func GetAskThreadHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+			return
+		}
+		threadID, err := strconv.Atoi(c.Param("threadId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid thread id"})
+			return
+		}
+
+		thread, err := queries.GetAskThread(c, sqlc.GetAskThreadParams{
+			ID:         int32(threadID),
+			DocumentID: int32(id),
+			UserID:     userID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "thread not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load thread"})
+			return
+		}
+
+		messages, err := queries.ListAskMessages(c, thread.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load thread"})
+			return
+		}
+		items := make([]AskMessageItem, 0, len(messages))
+		for _, m := range messages {
+			items = append(items, AskMessageItem{
+				ID:        m.ID,
+				Role:      m.Role,
+				Content:   m.Content,
+				Citations: m.Citations,
+				CreatedAt: m.CreatedAt.Time,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"thread": thread, "messages": items})
+	}
+}
+
+// readableDocument loads a document the caller owns or can read through a
+// workspace, writing a 404 otherwise.
+func readableDocument(c *gin.Context, queries *sqlc.Queries, userID int32, id int32) (sqlc.Document, bool) {
+	doc, err := queries.GetReadableDocument(c, sqlc.GetReadableDocumentParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return doc, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load file"})
+		return doc, false
+	}
+	return doc, true
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/deadletter"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const maxQuarantinedMessages = 200
+
+type QuarantineStats struct {
+	Queue    string `json:"queue"`
+	Status   string `json:"status"`
+	Messages int64  `json:"messages"`
+}
+
+type QuarantinedMessageResponse struct {
+	ID           int32            `json:"id"`
+	Queue        string           `json:"queue"`
+	MessageID    string           `json:"messageId"`
+	Body         string           `json:"body"`
+	Reason       string           `json:"reason"`
+	ReceiveCount int32            `json:"receiveCount"`
+	JobID        *int32           `json:"jobId"`
+	Status       string           `json:"status"`
+	CreatedAt    pgtype.Timestamp `json:"createdAt"`
+	ResolvedAt   pgtype.Timestamp `json:"resolvedAt"`
+	ResolvedBy   *int32           `json:"resolvedBy"`
+}
+
+func newQuarantinedMessageResponse(m sqlc.QuarantinedMessage) QuarantinedMessageResponse {
+	res := QuarantinedMessageResponse{
+		ID:           m.ID,
+		Queue:        m.QueueName,
+		MessageID:    m.MessageID,
+		Body:         m.Body,
+		Reason:       m.Reason,
+		ReceiveCount: m.ReceiveCount,
+		Status:       m.Status,
+		CreatedAt:    m.CreatedAt,
+		ResolvedAt:   m.ResolvedAt,
+	}
+	if m.JobID.Valid {
+		res.JobID = &m.JobID.Int32
+	}
+	if m.ResolvedBy.Valid {
+		res.ResolvedBy = &m.ResolvedBy.Int32
+	}
+	return res
+}
+
+// AdminListQuarantineHandler lists quarantined messages, newest first,
+// along with the number of messages per queue and status.
+func AdminListQuarantineHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := int32(50)
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxQuarantinedMessages {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+				return
+			}
+			limit = int32(n)
+		}
+		status := c.DefaultQuery("status", deadletter.StatusQuarantined)
+		switch status {
+		case deadletter.StatusQuarantined, deadletter.StatusRedriven, deadletter.StatusDiscarded:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+			return
+		}
+
+		stats, err := queries.GetQuarantineStats(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load quarantine"})
+			return
+		}
+		messages, err := queries.ListQuarantinedMessages(c, sqlc.ListQuarantinedMessagesParams{
+			Status:      status,
+			QueueName:   optionalQuery(c, "queue"),
+			MaxMessages: limit,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load quarantine"})
+			return
+		}
+
+		counts := make([]QuarantineStats, 0, len(stats))
+		for _, s := range stats {
+			counts = append(counts, QuarantineStats{Queue: s.QueueName, Status: s.Status, Messages: s.Messages})
+		}
+		res := make([]QuarantinedMessageResponse, 0, len(messages))
+		for _, m := range messages {
+			res = append(res, newQuarantinedMessageResponse(m))
+		}
+
+		c.JSON(http.StatusOK, gin.H{"stats": counts, "messages": res})
+	}
+}
+
+func AdminGetQuarantinedMessageHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
+			return
+		}
+
+		m, err := queries.GetQuarantinedMessage(c, int32(id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load message"})
+			return
+		}
+
+		c.JSON(http.StatusOK, newQuarantinedMessageResponse(m))
+	}
+}
+
+// AdminRedriveHandler sends a quarantined message back to its queue, e.g.
+// once whatever made it fail has been fixed.
+func AdminRedriveHandler(deadLetters *deadletter.Service) gin.HandlerFunc {
+	return resolveQuarantinedMessage(deadLetters.Redrive, "redrive")
+}
+
+// AdminDiscardHandler drops a quarantined message, failing the job it was
+// for if that job hasn't finished.
+func AdminDiscardHandler(deadLetters *deadletter.Service) gin.HandlerFunc {
+	return resolveQuarantinedMessage(deadLetters.Discard, "discard")
+}
+
+func resolveQuarantinedMessage(resolve func(ctx context.Context, id int32, adminID int32) (sqlc.QuarantinedMessage, error), action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID := getUserIdFromContext(c)
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
+			return
+		}
+
+		m, err := resolve(context.WithoutCancel(c), int32(id), adminID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+			return
+		}
+		if errors.Is(err, deadletter.ErrResolved) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(c, "failed to "+action+" message", "quarantined_message_id", id, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to " + action + " message"})
+			return
+		}
+
+		c.JSON(http.StatusOK, newQuarantinedMessageResponse(m))
+	}
+}
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"backend-go/internal/events"
 
@@ -11,6 +15,11 @@ import (
 
 func EventHandler(b *events.Broadcaster) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+		if userID == -1 {
+			return
+		}
+
 		c.Writer.Header().Set("Content-Type", "text/event-stream")
 		c.Writer.Header().Set("Cache-Control", "no-cache")
 		c.Writer.Header().Set("Connection", "keep-alive")
@@ -21,16 +30,34 @@ func EventHandler(b *events.Broadcaster) gin.HandlerFunc {
 			return
 		}
 
-		// Subscribe client
-		ch := b.Subscribe()
-		defer b.Unsubscribe(ch)
+		// the stream lasts as long as the client stays
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+			slog.WarnContext(c, "failed to lift write deadline of event stream", "error", err)
+		}
+
+		// Subscribe client to its own events
+		sub := b.Subscribe(strconv.Itoa(int(userID)))
+		defer b.Unsubscribe(sub)
 
 		// Send messages until client disconnects
 		notify := c.Writer.CloseNotify()
 		for {
 			select {
-			case msg := <-ch:
-				fmt.Fprintf(c.Writer, "data: %s\n\n", msg)
+			case <-sub.Ready():
+				msgs, open := sub.Take()
+				for _, msg := range msgs {
+					fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", msg.Type, msg.Data)
+				}
+				if !open {
+					// the server is shutting down or the client fell
+					// behind; EventSource reconnects by itself after the
+					// retry delay
+					retry := events.ReconnectDelay.Milliseconds()
+					data, _ := json.Marshal(events.ReconnectMessage{Type: events.Reconnect, RetryMs: retry})
+					fmt.Fprintf(c.Writer, "retry: %d\nevent: %s\ndata: %s\n\n", retry, events.Reconnect, data)
+					flusher.Flush()
+					return
+				}
 				flusher.Flush()
 			case <-notify:
 				return
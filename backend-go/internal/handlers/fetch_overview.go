@@ -1,46 +1,137 @@
 package handlers
 
 import (
-	"context"
-	"io"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	clients "backend-go/internal/clients"
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/ingest"
+	"backend-go/internal/structured"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gin-gonic/gin"
 )
 
-func FetchSummaryHandler(s3Client *s3.Client, bucketName string) gin.HandlerFunc {
+const (
+	mimeJSON     = "application/json"
+	mimeText     = "text/plain"
+	mimeMarkdown = "text/markdown"
+)
+
+type SummaryResponse struct {
+	ID          int32     `json:"id"`
+	Name        string    `json:"name"`
+	Path        string    `json:"path"`
+	Status      string    `json:"status"`
+	Summary     string    `json:"summary"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Structured is only set when the summary was generated in structured
+	// mode; Summary is then its text rendering.
+	Structured *structured.Summary `json:"structured,omitempty"`
+}
+
+// FetchSummaryHandler serves a document's summary to its owner or to members
+// of the workspace it is shared with. Everyone else gets a 404 so document
+// ids can't be probed.
+func FetchSummaryHandler(queries *sqlc.Queries, s3Client *s3.Client, bucketName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
 
-		userName := c.Query("userName")
-		fileName := c.Query("file")
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+			return
+		}
 
-		if userName == "" || fileName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "missing username or file"})
+		doc, ok := readableDocument(c, queries, userID, int32(id))
+		if !ok {
 			return
 		}
 
-		summaryKey := userName + "/" + fileName + "_overview.txt"
+		switch {
+		case doc.Status == ingest.StatusPending:
+			c.Header("Retry-After", "5")
+			c.JSON(http.StatusAccepted, gin.H{"id": doc.ID, "status": doc.Status})
+			return
+		case doc.Status != ingest.StatusCompleted || !doc.SummaryKey.Valid:
+			c.JSON(http.StatusNotFound, gin.H{"error": "summary not available", "status": doc.Status})
+			return
+		}
 
-		out, err := s3Client.GetObject(context.TODO(), &s3.GetObjectInput{
-			Bucket: &bucketName,
-			Key:    &summaryKey,
-		})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch summary"})
+		format := c.NegotiateFormat(mimeJSON, mimeText, mimeMarkdown)
+		if format == "" {
+			c.JSON(http.StatusNotAcceptable, gin.H{"error": "supported formats are application/json, text/plain and text/markdown"})
 			return
 		}
-		defer out.Body.Close()
 
-		body, err := io.ReadAll(out.Body)
+		// summary objects are immutable per job, so the key identifies the
+		// summary; the path and update time cover the rest of the body
+		sum := sha256.Sum256([]byte(doc.SummaryKey.String + "\x00" + format + "\x00" + doc.Path + "\x00" +
+			strconv.FormatInt(doc.UpdatedAt.Time.UnixNano(), 10)))
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", "private, no-cache")
+		c.Header("Vary", "Accept, Cookie")
+
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		body, err := clients.ReadObject(c, s3Client, bucketName, doc.SummaryKey.String)
+		var noSuchKey *s3types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "summary not found"})
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read summary"})
+			slog.ErrorContext(c, "failed to fetch summary", "document_id", doc.ID, "key", doc.SummaryKey.String, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch summary"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"file":    summaryKey,
-			"summary": string(body),
-		})
+		name := path.Base(doc.Path)
+		switch format {
+		case mimeText:
+			c.Data(http.StatusOK, mimeText+"; charset=utf-8", body)
+		case mimeMarkdown:
+			c.Data(http.StatusOK, mimeMarkdown+"; charset=utf-8", []byte("# "+name+"\n\n"+string(body)+"\n"))
+		default:
+			summary, err := ingest.ReadStructured(c, s3Client, bucketName, doc.SummaryKey.String)
+			if err != nil {
+				slog.ErrorContext(c, "failed to fetch structured summary", "document_id", doc.ID, "key", doc.SummaryKey.String, "error", err)
+			}
+			c.JSON(http.StatusOK, SummaryResponse{
+				ID:          doc.ID,
+				Name:        name,
+				Path:        doc.Path,
+				Status:      doc.Status,
+				Summary:     string(body),
+				GeneratedAt: doc.UpdatedAt.Time,
+				Structured:  summary,
+			})
+		}
+	}
+}
+
+// etagMatches reports whether an If-None-Match header, a list of entity
+// tags or "*", names etag. The comparison is weak, as RFC 9110 asks for
+// If-None-Match, so W/"x" matches "x".
+func etagMatches(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/ingest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+type FileItem struct {
+	ID             int32     `json:"id"`
+	Name           string    `json:"name"`
+	Path           string    `json:"path"`
+	Folder         string    `json:"folder"`
+	Size           int64     `json:"size"`
+	UploadedAt     time.Time `json:"uploadedAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	Status         string    `json:"status"`
+	JobStatus      string    `json:"jobStatus,omitempty"`
+	SummaryPreview string    `json:"summaryPreview,omitempty"`
+	Tags           []string  `json:"tags"`
+}
+
+// fileCursor is handed out base64 encoded. It pins the sort it was created
+// for, so a cursor can't be replayed against a different ordering.
+type fileCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Key   string `json:"k"`
+	ID    int32  `json:"i"`
+}
+
+type SetTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+func ListFilesHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		sortBy := c.DefaultQuery("sort", "date")
+		if sortBy != "date" && sortBy != "name" && sortBy != "size" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of date, name, size"})
+			return
+		}
+		defaultOrder := "desc"
+		if sortBy == "name" {
+			defaultOrder = "asc"
+		}
+		order := c.DefaultQuery("order", defaultOrder)
+		if order != "asc" && order != "desc" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+			return
+		}
+
+		limit := defaultPageSize
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+				return
+			}
+			limit = min(n, maxPageSize)
+		}
+
+		params := sqlc.ListDocumentsPageParams{
+			SortBy:     sortBy,
+			UserID:     userID,
+			Status:     optionalQuery(c, "status"),
+			Tag:        optionalQuery(c, "tag"),
+			Descending: order == "desc",
+			// one extra row tells us whether there is a next page
+			PageSize: int32(limit + 1),
+		}
+		if params.Tag.Valid {
+			params.Tag.String = strings.ToLower(params.Tag.String)
+		}
+		if folder := strings.Trim(c.Query("folder"), "/"); folder != "" {
+			params.Folder = pgtype.Text{String: folder, Valid: true}
+		}
+
+		var err error
+		if params.CreatedFrom, err = parseDateQuery(c.Query("from"), false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+			return
+		}
+		if params.CreatedTo, err = parseDateQuery(c.Query("to"), true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+			return
+		}
+
+		if v := c.Query("cursor"); v != "" {
+			cur, err := decodeFileCursor(v)
+			if err != nil || cur.Sort != sortBy || cur.Order != order {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+				return
+			}
+			params.CursorKey = pgtype.Text{String: cur.Key, Valid: true}
+			params.CursorID = pgtype.Int4{Int32: cur.ID, Valid: true}
+		}
+
+		rows, err := queries.ListDocumentsPage(c, params)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list files"})
+			return
+		}
+
+		nextCursor := ""
+		if len(rows) > limit {
+			rows = rows[:limit]
+			last := rows[len(rows)-1]
+			nextCursor = encodeFileCursor(fileCursor{Sort: sortBy, Order: order, Key: last.SortKey, ID: last.ID})
+		}
+
+		items := make([]FileItem, 0, len(rows))
+		for _, row := range rows {
+			folder := path.Dir(row.Path)
+			if folder == "." {
+				folder = ""
+			}
+			items = append(items, FileItem{
+				ID:             row.ID,
+				Name:           path.Base(row.Path),
+				Path:           row.Path,
+				Folder:         folder,
+				Size:           row.Size,
+				UploadedAt:     row.CreatedAt.Time,
+				UpdatedAt:      row.UpdatedAt.Time,
+				Status:         row.Status,
+				JobStatus:      row.JobStatus,
+				SummaryPreview: row.SummaryPreview.String,
+				Tags:           row.Tags,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"items":      items,
+			"nextCursor": nextCursor,
+		})
+	}
+}
+
+func SetFileTagsHandler(queries *sqlc.Queries, ingester *ingest.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+			return
+		}
+
+		var req SetTagsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		doc, err := queries.GetDocument(c, int32(id))
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && doc.UserID != userID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load file"})
+			return
+		}
+
+		tags := ingest.NormalizeTags(req.Tags)
+		if err := ingester.SetTags(c, doc.ID, tags); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update tags"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"id": doc.ID, "tags": tags})
+	}
+}
+
+func optionalQuery(c *gin.Context, key string) pgtype.Text {
+	v := strings.TrimSpace(c.Query(key))
+	return pgtype.Text{String: v, Valid: v != ""}
+}
+
+// parseDateQuery accepts RFC 3339 timestamps or plain dates. A plain date
+// used as an upper bound includes the whole day.
+func parseDateQuery(v string, upper bool) (pgtype.Timestamp, error) {
+	if v == "" {
+		return pgtype.Timestamp{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return pgtype.Timestamp{Time: t.UTC(), Valid: true}, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return pgtype.Timestamp{}, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return pgtype.Timestamp{Time: t, Valid: true}, nil
+}
+
+func encodeFileCursor(cur fileCursor) string {
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeFileCursor(v string) (fileCursor, error) {
+	var cur fileCursor
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return cur, err
+	}
+	err = json.Unmarshal(b, &cur)
+	return cur, err
+}
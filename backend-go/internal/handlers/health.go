@@ -3,9 +3,25 @@ package handlers
 import (
 	"net/http"
 
+	"backend-go/internal/health"
+
 	"github.com/gin-gonic/gin"
 )
 
 func HealthHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Server is running!"})
 }
+
+// HealthCheckHandler answers with the checker's report, with 503 when a
+// check failed.
+func HealthCheckHandler(checker *health.Checker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := checker.Run(c)
+		c.Header("Cache-Control", "no-store")
+		if !report.OK() {
+			c.JSON(http.StatusServiceUnavailable, report)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
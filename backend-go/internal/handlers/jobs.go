@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/dispatch"
+	"backend-go/internal/ingest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	defaultReprocessLimit = 100
+	maxReprocessLimit     = 1000
+)
+
+// ReprocessRequest selects documents by the latest job that summarized them.
+// Unset filters match everything; Options override the owner's preferences,
+// and the style and format default to those of the matched job.
+type ReprocessRequest struct {
+	UserID          *int32                `json:"userId"`
+	Style           string                `json:"style"`
+	TemplateVersion *int32                `json:"templateVersion"`
+	Model           string                `json:"model"`
+	PromptVersion   string                `json:"promptVersion"`
+	CompletedBefore string                `json:"completedBefore"`
+	Options         ingest.SummaryOptions `json:"options"`
+	Limit           int32                 `json:"limit"`
+	DryRun          bool                  `json:"dryRun"`
+}
+
+type ReprocessResult struct {
+	DocumentID int32  `json:"documentId"`
+	UserID     int32  `json:"userId"`
+	JobID      int32  `json:"jobId,omitempty"`
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+const (
+	reprocessMatched = "matched"
+	reprocessQueued  = "queued"
+	reprocessFailed  = "failed"
+)
+
+// CancelJobHandler cancels one of the caller's jobs that is still waiting or
+// running.
+func CancelJobHandler(ingester *ingest.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+			return
+		}
+
+		job, err := ingester.Cancel(context.WithoutCancel(c), userID, int32(id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+			return
+		}
+		if errors.Is(err, ingest.ErrJobFinished) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(c, "failed to cancel job", "job_id", id, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel job"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"id": job.ID, "documentId": job.DocumentID, "status": job.Status})
+	}
+}
+
+// ResummarizeHandler summarizes a stored file again, optionally with another
+// style, length, language, format or model. The body may be empty.
+func ResummarizeHandler(ingester *ingest.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+			return
+		}
+
+		var req ingest.SummaryOptions
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		result, err := ingester.Resummarize(c, userID, int32(id), req, dispatch.LaneInteractive)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+			return
+		}
+		if errors.Is(err, ingest.ErrSummaryPending) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, ingest.ErrInvalidOptions) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if writeQuotaError(c, err) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(c, "failed to resummarize file", "document_id", id, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resummarize file"})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":    "summary requested",
+			"documentId": result.Document.ID,
+			"jobId":      result.Job.ID,
+		})
+	}
+}
+
+// AdminReprocessHandler summarizes every document matching the filter again,
+// in the scheduled lane so it doesn't hold up anyone's uploads. With dryRun
+// it only lists the matches.
+func AdminReprocessHandler(queries *sqlc.Queries, ingester *ingest.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ReprocessRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if req.Limit == 0 {
+			req.Limit = defaultReprocessLimit
+		}
+		if req.Limit < 0 || req.Limit > maxReprocessLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		completedBefore, err := parseDateQuery(req.CompletedBefore, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid completedBefore date"})
+			return
+		}
+
+		params := sqlc.ListReprocessCandidatesParams{
+			Style:           optionalText(req.Style),
+			Model:           optionalText(req.Model),
+			PromptVersion:   optionalText(req.PromptVersion),
+			CompletedBefore: completedBefore,
+			MaxDocuments:    req.Limit,
+		}
+		if req.UserID != nil {
+			params.UserID = pgtype.Int4{Int32: *req.UserID, Valid: true}
+		}
+		if req.TemplateVersion != nil {
+			params.TemplateVersion = pgtype.Int4{Int32: *req.TemplateVersion, Valid: true}
+		}
+
+		candidates, err := queries.ListReprocessCandidates(c, params)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find documents"})
+			return
+		}
+
+		ctx := context.WithoutCancel(c)
+		results := make([]ReprocessResult, 0, len(candidates))
+		var queued int
+		for _, d := range candidates {
+			res := ReprocessResult{DocumentID: d.DocumentID, UserID: d.UserID, Status: reprocessMatched}
+			if req.DryRun {
+				results = append(results, res)
+				continue
+			}
+
+			opts := req.Options
+			if opts.Style == "" && d.Style.Valid {
+				opts.Style = d.Style.String
+			}
+			if opts.Format == "" {
+				opts.Format = d.Format
+			}
+
+			result, err := ingester.Resummarize(ctx, d.UserID, d.DocumentID, opts, dispatch.LaneScheduled)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to reprocess document", "document_id", d.DocumentID, "error", err)
+				res.Status = reprocessFailed
+				res.Reason = err.Error()
+			} else {
+				res.Status = reprocessQueued
+				res.JobID = result.Job.ID
+				queued++
+			}
+			results = append(results, res)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"matched":   len(candidates),
+			"queued":    queued,
+			"dryRun":    req.DryRun,
+			"documents": results,
+		})
+	}
+}
+
+func optionalText(v string) pgtype.Text {
+	return pgtype.Text{String: v, Valid: v != ""}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/ingest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type PromptTemplateResponse struct {
+	ID        int32            `json:"id"`
+	Name      string           `json:"name"`
+	Version   int32            `json:"version"`
+	Custom    bool             `json:"custom"`
+	Body      string           `json:"body"`
+	CreatedAt pgtype.Timestamp `json:"createdAt"`
+}
+
+type SaveTemplateRequest struct {
+	Body string `json:"body"`
+}
+
+func newPromptTemplateResponse(t sqlc.PromptTemplate) PromptTemplateResponse {
+	return PromptTemplateResponse{
+		ID:        t.ID,
+		Name:      t.Name,
+		Version:   t.Version,
+		Custom:    t.UserID.Valid,
+		Body:      t.Body,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+// ListPromptTemplatesHandler lists the latest version of every built-in
+// style and of the caller's custom template.
+func ListPromptTemplatesHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		templates, err := queries.ListPromptTemplates(c, pgtype.Int4{Int32: userID, Valid: true})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list prompt templates"})
+			return
+		}
+
+		res := make([]PromptTemplateResponse, 0, len(templates))
+		for _, t := range templates {
+			res = append(res, newPromptTemplateResponse(t))
+		}
+
+		c.JSON(http.StatusOK, gin.H{"templates": res})
+	}
+}
+
+// SaveCustomTemplateHandler stores a new version of the caller's custom
+// template. Earlier versions are kept so existing summaries stay traceable.
+func SaveCustomTemplateHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		var req SaveTemplateRequest
+		if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Body) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if len(req.Body) > ingest.MaxTemplateLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "template is too long"})
+			return
+		}
+
+		template, err := queries.CreateCustomPromptTemplate(c, sqlc.CreateCustomPromptTemplateParams{
+			UserID: userID,
+			Body:   strings.TrimSpace(req.Body),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save template"})
+			return
+		}
+
+		c.JSON(http.StatusOK, newPromptTemplateResponse(template))
+	}
+}
+
+// GetSummaryPreferencesHandler returns the caller's defaults, filled in with
+// the system defaults where they have not chosen anything.
+func GetSummaryPreferencesHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		prefs, err := queries.GetSummaryPreferences(c, userID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusOK, ingest.DefaultOptions)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load preferences"})
+			return
+		}
+
+		c.JSON(http.StatusOK, ingest.SummaryOptions{
+			Style:    prefs.Style,
+			Length:   prefs.Length,
+			Language: prefs.Language,
+			Format:   prefs.Format,
+			Model:    prefs.Model,
+		})
+	}
+}
+
+func UpdateSummaryPreferencesHandler(queries *sqlc.Queries, ingester *ingest.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		var req ingest.SummaryOptions
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		// resolving the prompt checks that the style exists for this user
+		prompt, err := ingester.Prompt(c, userID, req)
+		if errors.Is(err, ingest.ErrInvalidOptions) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save preferences"})
+			return
+		}
+
+		prefs, err := queries.UpsertSummaryPreferences(c, sqlc.UpsertSummaryPreferencesParams{
+			UserID:   userID,
+			Style:    prompt.Options.Style,
+			Length:   prompt.Options.Length,
+			Language: prompt.Options.Language,
+			Format:   prompt.Options.Format,
+			Model:    prompt.Options.Model,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save preferences"})
+			return
+		}
+
+		c.JSON(http.StatusOK, ingest.SummaryOptions{
+			Style:    prefs.Style,
+			Length:   prefs.Length,
+			Language: prefs.Language,
+			Format:   prefs.Format,
+			Model:    prefs.Model,
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/dispatch"
+	"backend-go/internal/worker"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const maxWaitingJobs = 200
+
+type LaneStats struct {
+	Lane    string `json:"lane"`
+	Waiting int64  `json:"waiting"`
+	Users   int64  `json:"users"`
+}
+
+type WaitingJobResponse struct {
+	ID         int32            `json:"id"`
+	UserID     int32            `json:"userId"`
+	Username   string           `json:"username"`
+	DocumentID int32            `json:"documentId"`
+	Lane       string           `json:"lane"`
+	Priority   int32            `json:"priority"`
+	CreatedAt  pgtype.Timestamp `json:"createdAt"`
+}
+
+// OutboxStats describes the tasks dispatched but not yet accepted by SQS.
+type OutboxStats struct {
+	Unsent       int64            `json:"unsent"`
+	Failing      int64            `json:"failing"`
+	OldestUnsent pgtype.Timestamp `json:"oldestUnsent"`
+}
+
+// JobPriorityRequest moves a waiting job. A positive priority sends it
+// before everything else, a negative one after everything else.
+type JobPriorityRequest struct {
+	Priority *int32 `json:"priority"`
+	Lane     string `json:"lane"`
+}
+
+// AdminQueueHandler shows what is waiting to be dispatched, per lane and
+// job by job in the order bumped jobs go first, and what the response
+// worker has handled.
+func AdminQueueHandler(queries *sqlc.Queries, dispatcher *dispatch.Dispatcher, responses *worker.ResponseWorker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := int32(50)
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxWaitingJobs {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+				return
+			}
+			limit = int32(n)
+		}
+
+		inFlight, err := dispatcher.InFlight(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load queue"})
+			return
+		}
+		stats, err := queries.GetLaneStats(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load queue"})
+			return
+		}
+		waiting, err := queries.ListWaitingJobs(c, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load queue"})
+			return
+		}
+		unsent, err := queries.GetOutboxStats(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load queue"})
+			return
+		}
+
+		lanes := make([]LaneStats, 0, len(stats))
+		for _, s := range stats {
+			lanes = append(lanes, LaneStats{Lane: s.Lane, Waiting: s.Waiting, Users: s.Users})
+		}
+		jobs := make([]WaitingJobResponse, 0, len(waiting))
+		for _, j := range waiting {
+			jobs = append(jobs, WaitingJobResponse{
+				ID:         j.ID,
+				UserID:     j.UserID,
+				Username:   j.Username,
+				DocumentID: j.DocumentID,
+				Lane:       j.Lane,
+				Priority:   j.Priority,
+				CreatedAt:  j.CreatedAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"inFlight": inFlight,
+			"lanes":    lanes,
+			"jobs":     jobs,
+			"outbox": OutboxStats{
+				Unsent:       unsent.Pending,
+				Failing:      unsent.Failing,
+				OldestUnsent: unsent.Oldest,
+			},
+			"responseWorker": responses.Stats(),
+		})
+	}
+}
+
+// AdminSetJobPriorityHandler bumps or deprioritizes a job that has not been
+// dispatched yet, optionally moving it to another lane.
+func AdminSetJobPriorityHandler(queries *sqlc.Queries, dispatcher *dispatch.Dispatcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+			return
+		}
+
+		var req JobPriorityRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Priority == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if req.Lane != "" && !dispatch.ValidLane(req.Lane) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid lane"})
+			return
+		}
+
+		job, err := queries.GetJob(c, int32(id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load job"})
+			return
+		}
+
+		lane := req.Lane
+		if lane == "" {
+			lane = job.Lane
+		}
+		n, err := queries.SetJobPriority(c, sqlc.SetJobPriorityParams{
+			ID:       job.ID,
+			Priority: *req.Priority,
+			Lane:     lane,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update job"})
+			return
+		}
+		if n == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "job has already been dispatched"})
+			return
+		}
+		dispatcher.Notify()
+
+		c.JSON(http.StatusOK, gin.H{"id": job.ID, "priority": *req.Priority, "lane": lane})
+	}
+}
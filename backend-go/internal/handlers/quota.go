@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/quota"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// QuotaOverrideRequest sets a user's limits. Omitted or null fields fall
+// back to the configured defaults; zero means unlimited.
+type QuotaOverrideRequest struct {
+	MaxBytes       *int64   `json:"maxBytes"`
+	UploadsPerDay  *int32   `json:"uploadsPerDay"`
+	TokensPerMonth *int64   `json:"tokensPerMonth"`
+	CostPerMonth   *float64 `json:"costPerMonth"`
+	PendingJobs    *int32   `json:"pendingJobs"`
+	Note           string   `json:"note"`
+}
+
+type WorkspaceQuotaRequest struct {
+	MaxBytes *int64 `json:"maxBytes"`
+}
+
+type QuotaOverrideResponse struct {
+	QuotaOverrideRequest
+	GrantedBy *int32           `json:"grantedBy"`
+	UpdatedAt pgtype.Timestamp `json:"updatedAt"`
+}
+
+func newQuotaOverrideResponse(o sqlc.QuotaOverride) QuotaOverrideResponse {
+	res := QuotaOverrideResponse{
+		QuotaOverrideRequest: QuotaOverrideRequest{Note: o.Note},
+		UpdatedAt:            o.UpdatedAt,
+	}
+	if o.MaxBytes.Valid {
+		res.MaxBytes = &o.MaxBytes.Int64
+	}
+	if o.UploadsPerDay.Valid {
+		res.UploadsPerDay = &o.UploadsPerDay.Int32
+	}
+	if o.TokensPerMonth.Valid {
+		res.TokensPerMonth = &o.TokensPerMonth.Int64
+	}
+	if o.CostPerMonth.Valid {
+		res.CostPerMonth = &o.CostPerMonth.Float64
+	}
+	if o.PendingJobs.Valid {
+		res.PendingJobs = &o.PendingJobs.Int32
+	}
+	if o.GrantedBy.Valid {
+		res.GrantedBy = &o.GrantedBy.Int32
+	}
+	return res
+}
+
+// writeQuotaError answers with 429 and Retry-After for limits that lift with
+// time, and 403 for storage. It reports whether err was a quota error.
+func writeQuotaError(c *gin.Context, err error) bool {
+	var exceeded *quota.ExceededError
+	if !errors.As(err, &exceeded) {
+		return false
+	}
+	if exceeded.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(exceeded.RetryAfter.Seconds()))))
+	}
+	c.JSON(exceeded.Status(), gin.H{
+		"error": exceeded.Error(),
+		"limit": exceeded.Limit,
+		"used":  exceeded.Used,
+		"max":   exceeded.Max,
+	})
+	return true
+}
+
+// GetQuotaHandler reports the caller's limits and what they have used of
+// them today and this month.
+func GetQuotaHandler(quotas *quota.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		limits, err := quotas.Limits(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load quota"})
+			return
+		}
+		usage, err := quotas.Usage(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load quota"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"limits": limits, "usage": usage})
+	}
+}
+
+// WorkspaceQuotaHandler reports a workspace's storage limit and how much of
+// it the shared documents take up. Any member may look.
+func WorkspaceQuotaHandler(queries *sqlc.Queries, quotas *quota.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		workspaceID, ok := workspaceRole(c, queries, userID, roleMember)
+		if !ok {
+			return
+		}
+
+		limit, used, err := quotas.WorkspaceLimit(c, workspaceID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load quota"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"maxBytes": limit, "bytesStored": used})
+	}
+}
+
+// AdminGetUserQuotaHandler shows a user's effective limits, usage and
+// override, if any.
+func AdminGetUserQuotaHandler(queries *sqlc.Queries, quotas *quota.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := userParam(c, queries)
+		if !ok {
+			return
+		}
+
+		limits, err := quotas.Limits(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load quota"})
+			return
+		}
+		usage, err := quotas.Usage(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load quota"})
+			return
+		}
+
+		var override *QuotaOverrideResponse
+		o, err := queries.GetQuotaOverride(c, userID)
+		if err == nil {
+			res := newQuotaOverrideResponse(o)
+			override = &res
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load quota"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"limits": limits, "usage": usage, "override": override})
+	}
+}
+
+// AdminSetUserQuotaHandler replaces a user's override.
+func AdminSetUserQuotaHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID := getUserIdFromContext(c)
+
+		userID, ok := userParam(c, queries)
+		if !ok {
+			return
+		}
+
+		var req QuotaOverrideRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if negative(req.MaxBytes) || negative(req.UploadsPerDay) || negative(req.TokensPerMonth) ||
+			negative(req.CostPerMonth) || negative(req.PendingJobs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limits must not be negative"})
+			return
+		}
+
+		params := sqlc.UpsertQuotaOverrideParams{
+			UserID:    userID,
+			Note:      req.Note,
+			GrantedBy: pgtype.Int4{Int32: adminID, Valid: true},
+		}
+		if req.MaxBytes != nil {
+			params.MaxBytes = pgtype.Int8{Int64: *req.MaxBytes, Valid: true}
+		}
+		if req.UploadsPerDay != nil {
+			params.UploadsPerDay = pgtype.Int4{Int32: *req.UploadsPerDay, Valid: true}
+		}
+		if req.TokensPerMonth != nil {
+			params.TokensPerMonth = pgtype.Int8{Int64: *req.TokensPerMonth, Valid: true}
+		}
+		if req.CostPerMonth != nil {
+			params.CostPerMonth = pgtype.Float8{Float64: *req.CostPerMonth, Valid: true}
+		}
+		if req.PendingJobs != nil {
+			params.PendingJobs = pgtype.Int4{Int32: *req.PendingJobs, Valid: true}
+		}
+
+		o, err := queries.UpsertQuotaOverride(c, params)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save quota"})
+			return
+		}
+
+		c.JSON(http.StatusOK, newQuotaOverrideResponse(o))
+	}
+}
+
+// AdminDeleteUserQuotaHandler puts a user back on the defaults.
+func AdminDeleteUserQuotaHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		n, err := queries.DeleteQuotaOverride(c, int32(id))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete quota"})
+			return
+		}
+		if n == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no quota override"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// AdminSetWorkspaceQuotaHandler sets a workspace's storage limit.
+func AdminSetWorkspaceQuotaHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID := getUserIdFromContext(c)
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspace id"})
+			return
+		}
+
+		var req WorkspaceQuotaRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.MaxBytes == nil || *req.MaxBytes < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if _, err := queries.GetWorkspace(c, int32(id)); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "workspace not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load workspace"})
+			return
+		}
+
+		o, err := queries.UpsertWorkspaceQuotaOverride(c, sqlc.UpsertWorkspaceQuotaOverrideParams{
+			WorkspaceID: int32(id),
+			MaxBytes:    *req.MaxBytes,
+			GrantedBy:   pgtype.Int4{Int32: adminID, Valid: true},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save quota"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"workspaceId": o.WorkspaceID, "maxBytes": o.MaxBytes, "updatedAt": o.UpdatedAt})
+	}
+}
+
+// AdminDeleteWorkspaceQuotaHandler puts a workspace back on the default
+// storage limit.
+func AdminDeleteWorkspaceQuotaHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspace id"})
+			return
+		}
+
+		n, err := queries.DeleteWorkspaceQuotaOverride(c, int32(id))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete quota"})
+			return
+		}
+		if n == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no quota override"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// userParam reads the :id param and checks that the user exists, writing
+// the error response if not.
+func userParam(c *gin.Context, queries *sqlc.Queries) (int32, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return 0, false
+	}
+
+	_, err = queries.GetUser(c, int32(id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+		return 0, false
+	}
+	return int32(id), true
+}
+
+func negative[T int32 | int64 | float64](v *T) bool {
+	return v != nil && *v < 0
+}
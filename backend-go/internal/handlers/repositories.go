@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/gitimport"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type CreateRepositoryRequest struct {
+	Path string `json:"path"`
+	Ref  string `json:"ref"`
+}
+
+type SyncRepositoryRequest struct {
+	Ref string `json:"ref"`
+}
+
+func CreateRepositoryHandler(queries *sqlc.Queries, importer *gitimport.Importer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		var req CreateRepositoryRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Path == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if req.Ref == "" {
+			req.Ref = "HEAD"
+		}
+
+		repoPath, err := importer.ResolvePath(req.Path)
+		if err != nil {
+			respondImportError(c, err)
+			return
+		}
+		if err := importer.Check(c, repoPath, req.Ref); err != nil {
+			respondImportError(c, err)
+			return
+		}
+
+		repository, err := queries.CreateRepository(c, sqlc.CreateRepositoryParams{
+			UserID: userID,
+			Path:   repoPath,
+			Ref:    req.Ref,
+		})
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "repository already registered"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create repository"})
+			return
+		}
+
+		// the import runs to the end even if the client leaves, so the
+		// batch is sealed and the sync recorded
+		result, err := importer.Sync(context.WithoutCancel(c), repository, req.Ref)
+		if err != nil && result.BatchID == 0 {
+			// nothing was imported; don't keep the repository registered
+			// so the request can be retried
+			if derr := queries.DeleteRepository(context.WithoutCancel(c), repository.ID); derr != nil {
+				slog.ErrorContext(c, "failed to remove repository after failed import", "repository_id", repository.ID, "error", derr)
+			}
+		}
+		if err != nil {
+			respondImportError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"repository": repository,
+			"sync":       result,
+		})
+	}
+}
+
+func SyncRepositoryHandler(queries *sqlc.Queries, importer *gitimport.Importer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid repository id"})
+			return
+		}
+
+		var req SyncRepositoryRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+				return
+			}
+		}
+
+		repository, err := queries.GetRepository(c, sqlc.GetRepositoryParams{
+			ID:     int32(id),
+			UserID: userID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "repository not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load repository"})
+			return
+		}
+
+		ref := req.Ref
+		if ref == "" {
+			ref = repository.Ref
+		}
+
+		// the sync runs to the end even if the client leaves, so the
+		// batch is sealed and the sync recorded
+		result, err := importer.Sync(context.WithoutCancel(c), repository, ref)
+		if err != nil {
+			respondImportError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func ListRepositoriesHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		repositories, err := queries.ListRepositoriesByUser(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list repositories"})
+			return
+		}
+		if repositories == nil {
+			repositories = []sqlc.Repository{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"repositories": repositories})
+	}
+}
+
+func respondImportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gitimport.ErrDisabled):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	case errors.Is(err, gitimport.ErrOutsideRoot):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, gitimport.ErrSyncInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, gitimport.ErrInvalidRef):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		slog.ErrorContext(c, "repository import failed", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to import repository"})
+	}
+}
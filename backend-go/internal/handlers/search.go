@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"html"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	sqlc "backend-go/internal/db/sqlc"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const maxSearchTerms = 16
+
+type SearchResult struct {
+	ID             int32     `json:"id"`
+	Name           string    `json:"name"`
+	Path           string    `json:"path"`
+	Status         string    `json:"status"`
+	WorkspaceID    *int32    `json:"workspaceId,omitempty"`
+	UploadedAt     time.Time `json:"uploadedAt"`
+	Rank           float32   `json:"rank"`
+	Snippet        string    `json:"snippet"`
+	SummarySnippet string    `json:"summarySnippet,omitempty"`
+	SummaryPreview string    `json:"summaryPreview,omitempty"`
+}
+
+// searchCursor pins the query it was issued for; ranks from one query mean
+// nothing for another.
+type searchCursor struct {
+	Query string  `json:"q"`
+	Rank  float32 `json:"r"`
+	ID    int32   `json:"i"`
+}
+
+// SearchHandler runs a ranked full-text search over the names, contents and
+// summaries of every document the caller can read. Snippets are HTML escaped
+// with the matches wrapped in <mark>.
+func SearchHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		query := buildTSQuery(c.Query("q"))
+		if query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "q must contain at least one word"})
+			return
+		}
+
+		limit := defaultPageSize
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+				return
+			}
+			limit = min(n, maxPageSize)
+		}
+
+		params := sqlc.SearchDocumentsParams{
+			Query:    query,
+			UserID:   userID,
+			Tag:      optionalQuery(c, "tag"),
+			PageSize: int32(limit + 1),
+		}
+		if params.Tag.Valid {
+			params.Tag.String = strings.ToLower(params.Tag.String)
+		}
+		if v := c.Query("workspace"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspace"})
+				return
+			}
+			params.WorkspaceID = pgtype.Int4{Int32: int32(id), Valid: true}
+		}
+
+		var err error
+		if params.CreatedFrom, err = parseDateQuery(c.Query("from"), false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+			return
+		}
+		if params.CreatedTo, err = parseDateQuery(c.Query("to"), true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+			return
+		}
+
+		if v := c.Query("cursor"); v != "" {
+			cur, err := decodeSearchCursor(v)
+			if err != nil || cur.Query != query {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+				return
+			}
+			params.CursorRank = pgtype.Float4{Float32: cur.Rank, Valid: true}
+			params.CursorID = pgtype.Int4{Int32: cur.ID, Valid: true}
+		}
+
+		rows, err := queries.SearchDocuments(c, params)
+		if err != nil {
+			slog.ErrorContext(c, "search failed", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "search failed"})
+			return
+		}
+
+		nextCursor := ""
+		if len(rows) > limit {
+			rows = rows[:limit]
+			last := rows[len(rows)-1]
+			nextCursor = encodeSearchCursor(searchCursor{Query: query, Rank: last.Rank, ID: last.ID})
+		}
+
+		results := make([]SearchResult, 0, len(rows))
+		for _, row := range rows {
+			res := SearchResult{
+				ID:             row.ID,
+				Name:           path.Base(row.Path),
+				Path:           row.Path,
+				Status:         row.Status,
+				UploadedAt:     row.CreatedAt.Time,
+				Rank:           row.Rank,
+				Snippet:        escapeHeadline(row.Snippet),
+				SummarySnippet: escapeHeadline(row.SummarySnippet),
+				SummaryPreview: row.SummaryPreview.String,
+			}
+			if row.WorkspaceID.Valid {
+				res.WorkspaceID = &row.WorkspaceID.Int32
+			}
+			results = append(results, res)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"items":      results,
+			"nextCursor": nextCursor,
+		})
+	}
+}
+
+// buildTSQuery turns free text into a to_tsquery expression that matches
+// documents containing every word. The last word is matched as a prefix so
+// results show up while the user is still typing it. Only letters and
+// digits survive, which keeps tsquery operators out of user input.
+func buildTSQuery(q string) string {
+	terms := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(terms) == 0 {
+		return ""
+	}
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	} else if !strings.HasSuffix(q, " ") {
+		// a trailing space means the last word is finished
+		terms[len(terms)-1] += ":*"
+	}
+	return strings.Join(terms, " & ")
+}
+
+// escapeHeadline escapes a ts_headline result for HTML while keeping the
+// <mark> tags Postgres put around the matches.
+func escapeHeadline(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, "&lt;mark&gt;", "<mark>")
+	return strings.ReplaceAll(s, "&lt;/mark&gt;", "</mark>")
+}
+
+func encodeSearchCursor(cur searchCursor) string {
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSearchCursor(v string) (searchCursor, error) {
+	var cur searchCursor
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return cur, err
+	}
+	err = json.Unmarshal(b, &cur)
+	return cur, err
+}
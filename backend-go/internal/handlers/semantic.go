@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/embed"
+	"backend-go/internal/ingest"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSemanticLimit = 10
+	maxSemanticLimit     = 50
+)
+
+type SemanticResult struct {
+	ID        int32   `json:"id"`
+	Name      string  `json:"name"`
+	Path      string  `json:"path"`
+	Score     float32 `json:"score"`
+	Heading   string  `json:"heading,omitempty"`
+	StartLine int32   `json:"startLine"`
+	EndLine   int32   `json:"endLine"`
+	Snippet   string  `json:"snippet"`
+}
+
+func SemanticSearchHandler(indexer *embed.Indexer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		q := strings.TrimSpace(c.Query("q"))
+		if q == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+			return
+		}
+		limit, ok := semanticLimit(c)
+		if !ok {
+			return
+		}
+
+		matches, err := indexer.Search(c, userID, q, limit)
+		if err != nil {
+			slog.ErrorContext(c, "semantic search failed", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "search failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"items": semanticResults(matches)})
+	}
+}
+
+func RelatedFilesHandler(queries *sqlc.Queries, indexer *embed.Indexer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+			return
+		}
+		limit, ok := semanticLimit(c)
+		if !ok {
+			return
+		}
+
+		if _, ok := readableDocument(c, queries, userID, int32(id)); !ok {
+			return
+		}
+
+		matches, err := indexer.Related(c, userID, int32(id), limit)
+		if err != nil {
+			slog.ErrorContext(c, "related lookup failed", "document_id", id, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find related files"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"items": semanticResults(matches)})
+	}
+}
+
+func semanticLimit(c *gin.Context) (int, bool) {
+	v := c.Query("limit")
+	if v == "" {
+		return defaultSemanticLimit, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return 0, false
+	}
+	return min(n, maxSemanticLimit), true
+}
+
+func semanticResults(matches []embed.Match) []SemanticResult {
+	results := make([]SemanticResult, 0, len(matches))
+	for _, m := range matches {
+		results = append(results, SemanticResult{
+			ID:        m.DocumentID,
+			Name:      path.Base(m.Path),
+			Path:      m.Path,
+			Score:     m.Score,
+			Heading:   m.Heading,
+			StartLine: m.StartLine,
+			EndLine:   m.EndLine,
+			Snippet:   ingest.Preview(m.Content),
+		})
+	}
+	return results
+}
@@ -2,16 +2,16 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
 
-	clients "backend-go/internal/clients"
+	"backend-go/internal/events"
+	"backend-go/internal/ingest"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
 func getUserIdFromContext(c *gin.Context) int32 {
@@ -24,7 +24,7 @@ func getUserIdFromContext(c *gin.Context) int32 {
 	return userID
 }
 
-func UploadHandler(s3Client *s3.Client, sqsClient *sqs.Client, bucketName string, queueName string) gin.HandlerFunc {
+func UploadHandler(ingester *ingest.Service, broadcaster *events.Broadcaster) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID := getUserIdFromContext(c)
 
@@ -41,58 +41,90 @@ func UploadHandler(s3Client *s3.Client, sqsClient *sqs.Client, bucketName string
 		}
 		defer src.Close()
 
-		key := fmt.Sprintf("users/%d/%s", userID, file.Filename)
-
-		_, err = s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
-			Bucket: &bucketName,
-			Key:    &key,
-			Body:   src,
+		result, err := ingester.Ingest(c, ingest.Request{
+			UserID:  userID,
+			Path:    file.Filename,
+			Body:    src,
+			Tags:    ingest.NormalizeTags(c.PostFormArray("tags")),
+			Summary: summaryOptionsFromForm(c),
 		})
-
+		if errors.Is(err, ingest.ErrInvalidOptions) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if writeQuotaError(c, err) {
+			return
+		}
 		if err != nil {
-			log.Printf("upload failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": ""})
+			slog.ErrorContext(c, "upload failed", "path", file.Filename, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store file"})
 			return
 		}
 
-		event := map[string]string{
-			"bucket": bucketName,
-			"key":    key,
-			"userId": string(userID),
+		if !result.Cached {
+			c.JSON(http.StatusOK, gin.H{
+				"message":    "file uploaded successfully",
+				"file":       file.Filename,
+				"documentId": result.Document.ID,
+				"jobId":      result.Job.ID,
+			})
+			return
 		}
-		body, _ := json.Marshal(event)
 
-		err = clients.SendMessage(sqsClient, queueName, string(body))
-		if err != nil {
-			log.Printf("failed to send SQS message: %v", err)
+		if result.Summary != "" {
+			broadcaster.Publish(events.JobCompleted, events.SSEMessage{
+				Type:       events.JobCompleted,
+				UserID:     strconv.Itoa(int(userID)),
+				DocumentID: result.Document.ID,
+				Cached:     true,
+				Content:    result.Summary,
+				Structured: result.Structured,
+			})
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"message": "file uploaded successfully",
-			"file":    file.Filename,
+			"message":    "file uploaded successfully",
+			"file":       file.Filename,
+			"documentId": result.Document.ID,
+			"cached":     true,
+			"summary":    result.Summary,
+			"structured": result.Structured,
 		})
 	}
 }
 
-func ListFilesHandler(s3Client *s3.Client, bucketName string) gin.HandlerFunc {
+// summaryOptionsFromForm reads the per-upload summary overrides. Unset
+// fields fall back to the user's preferences.
+func summaryOptionsFromForm(c *gin.Context) ingest.SummaryOptions {
+	return ingest.SummaryOptions{
+		Style:    c.PostForm("style"),
+		Length:   c.PostForm("length"),
+		Language: c.PostForm("language"),
+		Format:   c.PostForm("format"),
+		Model:    c.PostForm("model"),
+	}
+}
+
+func DeleteFileHandler(ingester *ingest.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID := getUserIdFromContext(c)
-		prefix := fmt.Sprintf("users/%d/", userID)
 
-		out, err := s3Client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
-			Bucket: &bucketName,
-			Prefix: &prefix,
-		})
+		id, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list files"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
 			return
 		}
 
-		files := []string{}
-		for _, obj := range out.Contents {
-			files = append(files, *obj.Key)
+		err = ingester.Delete(context.WithoutCancel(c), userID, int32(id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete file"})
+			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"files": files})
+		c.JSON(http.StatusOK, gin.H{"message": "file deleted"})
 	}
 }
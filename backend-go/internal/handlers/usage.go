@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"net/http"
+
+	sqlc "backend-go/internal/db/sqlc"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type ModelResponse struct {
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	ContextTokens   int32   `json:"contextTokens"`
+	PromptPrice     float64 `json:"promptPrice"`
+	CompletionPrice float64 `json:"completionPrice"`
+	Quality         int32   `json:"quality"`
+}
+
+type UsageTotals struct {
+	Jobs             int64   `json:"jobs"`
+	PromptTokens     int64   `json:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens"`
+	Cost             float64 `json:"cost"`
+}
+
+type ModelUsage struct {
+	Model string `json:"model"`
+	UsageTotals
+	AvgLatencyMs float64 `json:"avgLatencyMs"`
+}
+
+type MemberUsage struct {
+	UserID   int32  `json:"userId"`
+	Username string `json:"username"`
+	UsageTotals
+}
+
+func (t *UsageTotals) add(o UsageTotals) {
+	t.Jobs += o.Jobs
+	t.PromptTokens += o.PromptTokens
+	t.CompletionTokens += o.CompletionTokens
+	t.Cost += o.Cost
+}
+
+// ListModelsHandler lists the models users can pick instead of "auto".
+// Prices are USD per million tokens.
+func ListModelsHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		models, err := queries.ListLlmModels(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list models"})
+			return
+		}
+
+		res := make([]ModelResponse, 0, len(models))
+		for _, m := range models {
+			res = append(res, ModelResponse{
+				ID:              m.ID,
+				Name:            m.Name,
+				ContextTokens:   m.ContextTokens,
+				PromptPrice:     m.PromptPrice,
+				CompletionPrice: m.CompletionPrice,
+				Quality:         m.Quality,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"models": res})
+	}
+}
+
+// UserUsageHandler reports the caller's jobs, tokens and estimated cost per
+// model, optionally limited to a from/to range.
+func UserUsageHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		from, to, ok := usageRange(c)
+		if !ok {
+			return
+		}
+
+		rows, err := queries.GetUserUsage(c, sqlc.GetUserUsageParams{
+			UserID:      userID,
+			CreatedFrom: from,
+			CreatedTo:   to,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load usage"})
+			return
+		}
+
+		total, models := modelUsage(rows)
+		c.JSON(http.StatusOK, gin.H{"total": total, "models": models})
+	}
+}
+
+// WorkspaceUsageHandler reports usage of the documents shared with a
+// workspace, per model and per member. Any member may look.
+func WorkspaceUsageHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		workspaceID, ok := workspaceRole(c, queries, userID, roleMember)
+		if !ok {
+			return
+		}
+		from, to, ok := usageRange(c)
+		if !ok {
+			return
+		}
+
+		rows, err := queries.GetWorkspaceUsage(c, sqlc.GetWorkspaceUsageParams{
+			WorkspaceID: workspaceID,
+			CreatedFrom: from,
+			CreatedTo:   to,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load usage"})
+			return
+		}
+		memberRows, err := queries.GetWorkspaceUsageByUser(c, sqlc.GetWorkspaceUsageByUserParams{
+			WorkspaceID: workspaceID,
+			CreatedFrom: from,
+			CreatedTo:   to,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load usage"})
+			return
+		}
+
+		total, models := modelUsage(toUserUsageRows(rows))
+		members := make([]MemberUsage, 0, len(memberRows))
+		for _, r := range memberRows {
+			members = append(members, MemberUsage{
+				UserID:   r.UserID,
+				Username: r.Username,
+				UsageTotals: UsageTotals{
+					Jobs:             r.Jobs,
+					PromptTokens:     r.PromptTokens,
+					CompletionTokens: r.CompletionTokens,
+					Cost:             r.Cost,
+				},
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"total": total, "models": models, "members": members})
+	}
+}
+
+func usageRange(c *gin.Context) (pgtype.Timestamp, pgtype.Timestamp, bool) {
+	from, err := parseDateQuery(c.Query("from"), false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+		return from, pgtype.Timestamp{}, false
+	}
+	to, err := parseDateQuery(c.Query("to"), true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+		return from, to, false
+	}
+	return from, to, true
+}
+
+func modelUsage(rows []sqlc.GetUserUsageRow) (UsageTotals, []ModelUsage) {
+	var total UsageTotals
+	models := make([]ModelUsage, 0, len(rows))
+	for _, r := range rows {
+		usage := UsageTotals{
+			Jobs:             r.Jobs,
+			PromptTokens:     r.PromptTokens,
+			CompletionTokens: r.CompletionTokens,
+			Cost:             r.Cost,
+		}
+		total.add(usage)
+		models = append(models, ModelUsage{Model: r.Model, UsageTotals: usage, AvgLatencyMs: r.AvgLatencyMs})
+	}
+	return total, models
+}
+
+func toUserUsageRows(rows []sqlc.GetWorkspaceUsageRow) []sqlc.GetUserUsageRow {
+	res := make([]sqlc.GetUserUsageRow, len(rows))
+	for i, r := range rows {
+		res[i] = sqlc.GetUserUsageRow(r)
+	}
+	return res
+}
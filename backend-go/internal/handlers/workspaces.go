@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/quota"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	roleOwner  = "owner"
+	roleMember = "member"
+)
+
+type CreateWorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+type AddMemberRequest struct {
+	Email string `json:"email"`
+}
+
+type ShareFileRequest struct {
+	// WorkspaceID nil unshares the file.
+	WorkspaceID *int32 `json:"workspaceId"`
+}
+
+func CreateWorkspaceHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		var req CreateWorkspaceRequest
+		if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		workspace, err := queries.CreateWorkspace(c, sqlc.CreateWorkspaceParams{
+			Name:    strings.TrimSpace(req.Name),
+			OwnerID: userID,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create workspace"})
+			return
+		}
+
+		err = queries.AddWorkspaceMember(c, sqlc.AddWorkspaceMemberParams{
+			WorkspaceID: workspace.ID,
+			UserID:      userID,
+			Role:        roleOwner,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create workspace"})
+			return
+		}
+
+		c.JSON(http.StatusOK, workspace)
+	}
+}
+
+func ListWorkspacesHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		workspaces, err := queries.ListWorkspacesByMember(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list workspaces"})
+			return
+		}
+		if workspaces == nil {
+			workspaces = []sqlc.ListWorkspacesByMemberRow{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"workspaces": workspaces})
+	}
+}
+
+func ListWorkspaceMembersHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		workspaceID, ok := workspaceRole(c, queries, userID, roleMember)
+		if !ok {
+			return
+		}
+
+		members, err := queries.ListWorkspaceMembers(c, workspaceID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list members"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"members": members})
+	}
+}
+
+func AddWorkspaceMemberHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		workspaceID, ok := workspaceRole(c, queries, userID, roleOwner)
+		if !ok {
+			return
+		}
+
+		var req AddMemberRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Email == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		user, err := queries.GetUserByEmail(c, req.Email)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		err = queries.AddWorkspaceMember(c, sqlc.AddWorkspaceMemberParams{
+			WorkspaceID: workspaceID,
+			UserID:      user.ID,
+			Role:        roleMember,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not add member"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "member added", "userId": user.ID})
+	}
+}
+
+func RemoveWorkspaceMemberHandler(queries *sqlc.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		workspaceID, ok := workspaceRole(c, queries, userID, roleOwner)
+		if !ok {
+			return
+		}
+
+		memberID, err := strconv.Atoi(c.Param("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+		if int32(memberID) == userID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the owner can't leave the workspace"})
+			return
+		}
+
+		err = queries.RemoveWorkspaceMember(c, sqlc.RemoveWorkspaceMemberParams{
+			WorkspaceID: workspaceID,
+			UserID:      int32(memberID),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not remove member"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "member removed"})
+	}
+}
+
+// ShareFileHandler moves one of the caller's files into a workspace they
+// belong to, which makes it readable by every member.
+func ShareFileHandler(queries *sqlc.Queries, quotas *quota.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := getUserIdFromContext(c)
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+			return
+		}
+
+		var req ShareFileRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		workspaceID := pgtype.Int4{}
+		if req.WorkspaceID != nil {
+			_, err := queries.GetWorkspaceMemberRole(c, sqlc.GetWorkspaceMemberRoleParams{
+				WorkspaceID: *req.WorkspaceID,
+				UserID:      userID,
+			})
+			if err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "not a member of this workspace"})
+				return
+			}
+			workspaceID = pgtype.Int4{Int32: *req.WorkspaceID, Valid: true}
+
+			// moving a document within the workspace it is already in adds nothing
+			doc, err := queries.GetDocument(c, int32(id))
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to share file"})
+				return
+			}
+			if err == nil && doc.UserID == userID && doc.WorkspaceID != workspaceID {
+				err = quotas.CheckWorkspace(c, *req.WorkspaceID, doc.Size)
+				if writeQuotaError(c, err) {
+					return
+				}
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to share file"})
+					return
+				}
+			}
+		}
+
+		n, err := queries.SetDocumentWorkspace(c, sqlc.SetDocumentWorkspaceParams{
+			ID:          int32(id),
+			UserID:      userID,
+			WorkspaceID: workspaceID,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to share file"})
+			return
+		}
+		if n == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"id": id, "workspaceId": req.WorkspaceID})
+	}
+}
+
+// workspaceRole checks that the caller has at least the given role in the
+// workspace from the :id param and writes the error response if not.
+func workspaceRole(c *gin.Context, queries *sqlc.Queries, userID int32, required string) (int32, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspace id"})
+		return 0, false
+	}
+
+	role, err := queries.GetWorkspaceMemberRole(c, sqlc.GetWorkspaceMemberRoleParams{
+		WorkspaceID: int32(id),
+		UserID:      userID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "workspace not found"})
+		return 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load workspace"})
+		return 0, false
+	}
+	if required == roleOwner && role != roleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the workspace owner can do this"})
+		return 0, false
+	}
+
+	return int32(id), true
+}
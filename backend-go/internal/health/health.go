@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+type Config struct {
+	// Timeout bounds each check.
+	Timeout time.Duration
+	// CacheTTL is how long a report is reused, so frequent probes don't
+	// load the dependencies.
+	CacheTTL time.Duration
+}
+
+var DefaultConfig = Config{
+	Timeout:  2 * time.Second,
+	CacheTTL: 5 * time.Second,
+}
+
+// Check probes one dependency and returns an error if it is unusable.
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+}
+
+type Result struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+type Report struct {
+	Status    string            `json:"status"`
+	CheckedAt time.Time         `json:"checkedAt"`
+	Checks    map[string]Result `json:"checks"`
+}
+
+// OK reports whether every check passed.
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Checker runs a set of checks concurrently and caches the report.
+type Checker struct {
+	checks []Check
+	cfg    Config
+
+	mu   sync.Mutex
+	last Report
+}
+
+func NewChecker(cfg Config, checks ...Check) *Checker {
+	return &Checker{checks: checks, cfg: cfg}
+}
+
+// Run returns the latest report, running the checks again once it is older
+// than CacheTTL. Concurrent callers wait for the same run.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.last.CheckedAt.IsZero() && time.Since(c.last.CheckedAt) < c.cfg.CacheTTL {
+		return c.last
+	}
+
+	report := Report{Status: StatusOK, CheckedAt: time.Now(), Checks: make(map[string]Result, len(c.checks))}
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	for i, check := range c.checks {
+		report.Checks[check.Name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	c.last = report
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	// a probe cut short by a client that left would fail every caller
+	// sharing the report
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Probe(ctx)
+	res := Result{Status: StatusOK, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
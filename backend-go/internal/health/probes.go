@@ -0,0 +1,50 @@
+package health
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Database pings a connection from the pool.
+func Database(pool *pgxpool.Pool) Check {
+	return Check{
+		Name: "database",
+		Probe: func(ctx context.Context) error {
+			return pool.Ping(ctx)
+		},
+	}
+}
+
+// Bucket checks that the bucket exists and can be reached.
+func Bucket(client *s3.Client, bucketName string) Check {
+	return Check{
+		Name: "bucket",
+		Probe: func(ctx context.Context) error {
+			_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucketName)})
+			return err
+		},
+	}
+}
+
+// Queue checks that the queue exists and its attributes can be read.
+func Queue(client *sqs.Client, queueName string) Check {
+	return Check{
+		Name: "queue:" + queueName,
+		Probe: func(ctx context.Context) error {
+			out, err := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queueName)})
+			if err != nil {
+				return err
+			}
+			_, err = client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+				QueueUrl:       out.QueueUrl,
+				AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameApproximateNumberOfMessages},
+			})
+			return err
+		},
+	}
+}
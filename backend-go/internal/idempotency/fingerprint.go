@@ -0,0 +1,121 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// memoryLimit is the largest body kept in memory while fingerprinting;
+// larger ones, i.e. uploads, are spooled to a temporary file.
+const memoryLimit = 1 << 20
+
+// Fingerprint hashes the method, path, query and body of r, so a key reused
+// for a different request can be told apart from a retry. The body is read
+// in full and put back for the handler; call cleanup once the request is
+// done.
+//
+// Multipart bodies are hashed part by part, leaving out the boundary, which
+// browsers pick anew for every attempt.
+func Fingerprint(r *http.Request) (fingerprint string, cleanup func(), err error) {
+	body, cleanup, err := spool(r.Body)
+	if err != nil {
+		return "", nil, err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	hashed := false
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		hashed = hashMultipart(h, body, params["boundary"]) == nil
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
+	if !hashed {
+		// a malformed multipart body is hashed as it is
+		h.Reset()
+		fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+		if _, err := io.Copy(h, body); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	r.Body = io.NopCloser(body)
+	return hex.EncodeToString(h.Sum(nil)), cleanup, nil
+}
+
+func hashMultipart(h hash.Hash, body io.Reader, boundary string) error {
+	mr := multipart.NewReader(body, boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%q %q\n", part.FormName(), part.FileName())
+		content := sha256.New()
+		if _, err := io.Copy(content, part); err != nil {
+			return err
+		}
+		h.Write(content.Sum(nil))
+	}
+}
+
+// spool reads body into memory, or into a temporary file past memoryLimit,
+// so it can be read more than once.
+func spool(body io.ReadCloser) (io.ReadSeeker, func(), error) {
+	if body == nil || body == http.NoBody {
+		return bytes.NewReader(nil), func() {}, nil
+	}
+	defer body.Close()
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, body, memoryLimit+1)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	if n <= memoryLimit {
+		return bytes.NewReader(buf.Bytes()), func() {}, nil
+	}
+
+	f, err := os.CreateTemp("", "idempotency-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return f, cleanup, nil
+}
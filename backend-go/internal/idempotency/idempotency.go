@@ -0,0 +1,114 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	sqlc "backend-go/internal/db/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// Header carries the client's key for a mutating request.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed from an earlier request.
+	ReplayedHeader = "Idempotent-Replayed"
+	// MaxKeyLength is the longest key accepted.
+	MaxKeyLength = 255
+)
+
+// Statuses of a stored key.
+const (
+	StatusProcessing = "processing"
+	StatusCompleted  = "completed"
+)
+
+type Config struct {
+	// TTL is how long a key and its response are kept.
+	TTL time.Duration
+	// LockTimeout is how long a request may hold its key before a retry is
+	// allowed to take over, e.g. after the backend stopped mid-request.
+	LockTimeout time.Duration
+}
+
+var DefaultConfig = Config{
+	TTL:         24 * time.Hour,
+	LockTimeout: 5 * time.Minute,
+}
+
+// Store keeps idempotency keys and the responses they produced in Postgres,
+// per user.
+type Store struct {
+	queries *sqlc.Queries
+	cfg     Config
+}
+
+func NewStore(queries *sqlc.Queries, cfg Config) *Store {
+	return &Store{queries: queries, cfg: cfg}
+}
+
+// Start deletes expired keys every hour until ctx is done.
+func (s *Store) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			n, err := s.queries.DeleteExpiredIdempotencyKeys(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("failed to delete expired idempotency keys", "error", err)
+			} else if n > 0 {
+				slog.Info("deleted expired idempotency keys", "count", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Claim reserves key for the request with the given fingerprint. When
+// the key is already taken, the record holding it is returned with claimed
+// false.
+func (s *Store) Claim(ctx context.Context, userID int32, key string, fingerprint string) (sqlc.IdempotencyKey, bool, error) {
+	rec, err := s.queries.ClaimIdempotencyKey(ctx, sqlc.ClaimIdempotencyKeyParams{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		TtlSeconds:  s.cfg.TTL.Seconds(),
+		LockSeconds: s.cfg.LockTimeout.Seconds(),
+	})
+	if err == nil {
+		return rec, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return sqlc.IdempotencyKey{}, false, err
+	}
+
+	rec, err = s.queries.GetIdempotencyKey(ctx, sqlc.GetIdempotencyKeyParams{UserID: userID, Key: key})
+	if err != nil {
+		return sqlc.IdempotencyKey{}, false, err
+	}
+	return rec, false, nil
+}
+
+// Complete stores the response of the request holding key.
+func (s *Store) Complete(ctx context.Context, userID int32, key string, status int, body []byte, contentType string) error {
+	return s.queries.CompleteIdempotencyKey(ctx, sqlc.CompleteIdempotencyKeyParams{
+		UserID:         userID,
+		Key:            key,
+		ResponseStatus: pgtype.Int4{Int32: int32(status), Valid: true},
+		ResponseBody:   body,
+		ContentType:    pgtype.Text{String: contentType, Valid: contentType != ""},
+	})
+}
+
+// Release gives key up without a response, so the request can be retried.
+func (s *Store) Release(ctx context.Context, userID int32, key string) error {
+	return s.queries.DeleteIdempotencyKey(ctx, sqlc.DeleteIdempotencyKeyParams{UserID: userID, Key: key})
+}
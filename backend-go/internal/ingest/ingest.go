@@ -0,0 +1,499 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	clients "backend-go/internal/clients"
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/dispatch"
+	"backend-go/internal/embed"
+	"backend-go/internal/events"
+	"backend-go/internal/logging"
+	"backend-go/internal/metrics"
+	"backend-go/internal/quota"
+	"backend-go/internal/storage"
+	"backend-go/internal/structured"
+	"backend-go/internal/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const DefaultModel = "x-ai/grok-4-fast:free"
+
+const (
+	StatusPending   = "pending"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// TaskMessage is the body sent to the task queue for the summarization worker.
+type TaskMessage struct {
+	Bucket        string `json:"bucket"`
+	Key           string `json:"key"`
+	UserID        string `json:"userId"`
+	DocumentID    int32  `json:"documentId"`
+	JobID         int32  `json:"jobId"`
+	ContentHash   string `json:"contentHash"`
+	Model         string `json:"model"`
+	PromptVersion string `json:"promptVersion"`
+	SummaryKey    string `json:"summaryKey"`
+	// Prompt is the rendered instruction the worker puts before the text;
+	// the remaining fields record where it came from.
+	Prompt          string `json:"prompt"`
+	Style           string `json:"style"`
+	Length          string `json:"length"`
+	Language        string `json:"language"`
+	Format          string `json:"format"`
+	TemplateID      int32  `json:"templateId"`
+	TemplateVersion int32  `json:"templateVersion"`
+}
+
+type Request struct {
+	UserID int32
+	Path   string
+	Body   io.Reader
+	// BatchID groups the job with the other files of a bulk upload; zero
+	// means the upload stands alone.
+	BatchID int32
+	// DocumentID replaces the content of an existing document instead of
+	// creating a new one.
+	DocumentID   int32
+	RepositoryID int32
+	// SourceSha is the git blob the content was read from, CommitSha the
+	// commit it was imported at.
+	SourceSha string
+	CommitSha string
+	Tags      []string
+	// Summary overrides the user's summary preferences for this upload.
+	Summary SummaryOptions
+	// Lane is the dispatch lane of the job; empty means interactive.
+	Lane string
+}
+
+type Result struct {
+	Document sqlc.Document
+	// Job is nil when the summary was served from the cache.
+	Job    *sqlc.Job
+	Cached bool
+	// Summary is only set on a cache hit, and Structured only when the
+	// upload asked for a structured summary.
+	Summary    string
+	Structured *structured.Summary
+}
+
+// Service stores uploaded content and either answers from the summary cache
+// or enqueues a summarization job. Every way of getting a document into the
+// system goes through Ingest.
+type Service struct {
+	pool        *pgxpool.Pool
+	queries     *sqlc.Queries
+	blobs       *storage.BlobStore
+	s3Client    *s3.Client
+	dispatcher  *dispatch.Dispatcher
+	indexer     *embed.Indexer
+	broadcaster *events.Broadcaster
+	quotas      *quota.Service
+	bucketName  string
+}
+
+func NewService(pool *pgxpool.Pool, queries *sqlc.Queries, blobs *storage.BlobStore, s3Client *s3.Client, dispatcher *dispatch.Dispatcher, indexer *embed.Indexer, broadcaster *events.Broadcaster, quotas *quota.Service, bucketName string) *Service {
+	return &Service{
+		pool:        pool,
+		queries:     queries,
+		blobs:       blobs,
+		s3Client:    s3Client,
+		dispatcher:  dispatcher,
+		indexer:     indexer,
+		broadcaster: broadcaster,
+		quotas:      quotas,
+		bucketName:  bucketName,
+	}
+}
+
+func SummaryKey(contentHash string, jobID int32) string {
+	return fmt.Sprintf("summaries/%s/%d_overview.txt", contentHash, jobID)
+}
+
+// Ingest stores one document and answers from the summary cache or creates
+// its job, in a span whose trace the job's task carries on to the worker.
+func (s *Service) Ingest(ctx context.Context, req Request) (Result, error) {
+	ctx, span := tracing.Start(ctx, "ingest", trace.WithAttributes(
+		attribute.Int("user.id", int(req.UserID)),
+		attribute.String("document.path", req.Path),
+	))
+	defer span.End()
+
+	res, err := s.ingest(ctx, req)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return res, err
+	}
+	span.SetAttributes(
+		attribute.Int("document.id", int(res.Document.ID)),
+		attribute.Bool("summary.cached", res.Cached),
+	)
+	if res.Job != nil {
+		span.SetAttributes(attribute.Int("job.id", int(res.Job.ID)))
+	}
+	return res, nil
+}
+
+func (s *Service) ingest(ctx context.Context, req Request) (Result, error) {
+	prompt, err := s.Prompt(ctx, req.UserID, req.Summary)
+	if err != nil {
+		return Result{}, err
+	}
+
+	text := &searchBuffer{}
+	blob, err := s.blobs.Put(ctx, io.TeeReader(req.Body, text))
+	if err != nil {
+		return Result{}, err
+	}
+
+	model, err := s.chooseModel(ctx, prompt, blob.Size)
+	if err != nil {
+		s.release(ctx, blob.Hash)
+		return Result{}, err
+	}
+
+	cached, err := s.queries.GetCachedSummary(ctx, sqlc.GetCachedSummaryParams{
+		ContentHash:   blob.Hash,
+		Model:         model,
+		PromptVersion: prompt.Version,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		s.release(ctx, blob.Hash)
+		return Result{}, fmt.Errorf("failed to look up summary cache: %w", err)
+	}
+	hit := err == nil
+
+	status := StatusPending
+	summaryKey := pgtype.Text{}
+	preview := pgtype.Text{}
+	if hit {
+		status = StatusCompleted
+		summaryKey = pgtype.Text{String: cached.SummaryKey, Valid: true}
+		preview = cached.Preview
+	}
+
+	var prev *sqlc.Document
+	if req.DocumentID != 0 {
+		p, err := s.queries.GetDocument(ctx, req.DocumentID)
+		if err != nil {
+			s.release(ctx, blob.Hash)
+			return Result{}, fmt.Errorf("failed to load document: %w", err)
+		}
+		prev = &p
+	}
+
+	// a replacement only counts the difference in size
+	reserved := blob.Size
+	if prev != nil {
+		reserved -= prev.Size
+	}
+	if err := s.quotas.Reserve(ctx, req.UserID, reserved, !hit); err != nil {
+		s.release(ctx, blob.Hash)
+		return Result{}, err
+	}
+
+	// the document and its job are saved together: either the upload is
+	// there with a job to summarize it, or neither is
+	var doc sqlc.Document
+	var job sqlc.Job
+	var superseded []sqlc.Job
+	err = s.inTx(ctx, func(queries *sqlc.Queries) error {
+		var err error
+		if prev != nil {
+			// a job for the old content must not report over the new one;
+			// its row is locked before the document's, like the response
+			// worker does
+			superseded, err = queries.SupersedeDocumentJobs(ctx, prev.ID)
+			if err != nil {
+				return fmt.Errorf("failed to cancel jobs of document %d: %w", prev.ID, err)
+			}
+		}
+		doc, err = saveDocument(ctx, queries, req, prev, blob, status, summaryKey, preview)
+		if err != nil {
+			return err
+		}
+
+		_, err = queries.CreateDocumentVersion(ctx, sqlc.CreateDocumentVersionParams{
+			DocumentID: doc.ID,
+			BlobHash:   blob.Hash,
+			CommitSha:  optionalText(req.CommitSha),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record version of document %d: %w", doc.ID, err)
+		}
+
+		for _, tag := range req.Tags {
+			err := queries.AddDocumentTag(ctx, sqlc.AddDocumentTagParams{DocumentID: doc.ID, Tag: tag})
+			if err != nil {
+				return fmt.Errorf("failed to tag document %d: %w", doc.ID, err)
+			}
+		}
+
+		if hit {
+			return nil
+		}
+		job, err = s.createJob(ctx, queries, jobRequest{
+			UserID:   req.UserID,
+			Document: doc,
+			Blob:     blob,
+			BatchID:  req.BatchID,
+			Prompt:   prompt,
+			Model:    model,
+			Lane:     req.Lane,
+		})
+		return err
+	})
+	if err != nil {
+		s.unreserve(ctx, req.UserID, reserved, !hit)
+		s.release(ctx, blob.Hash)
+		return Result{}, err
+	}
+	if prev != nil {
+		// the document now holds a reference on the new blob instead
+		s.release(ctx, prev.BlobHash)
+	}
+	for _, old := range superseded {
+		slog.InfoContext(logging.WithJob(ctx, old.ID, old.DocumentID), "job superseded by new content", "dispatched", old.DispatchedAt.Valid)
+		s.cancelled(ctx, old)
+	}
+	metrics.RecordUpload(blob.Size)
+	ctx = logging.WithJob(ctx, job.ID, doc.ID)
+	slog.InfoContext(ctx, "document stored", "path", doc.Path, "size", blob.Size, "cached", hit)
+
+	summary := ""
+	var structuredSummary *structured.Summary
+	if hit {
+		content, err := clients.ReadObject(ctx, s.s3Client, s.bucketName, cached.SummaryKey)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to read cached summary", "key", cached.SummaryKey, "error", err)
+		}
+		summary = string(content)
+
+		if prompt.Options.Format == FormatStructured {
+			structuredSummary, err = ReadStructured(ctx, s.s3Client, s.bucketName, cached.SummaryKey)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to read cached structured summary", "key", cached.SummaryKey, "error", err)
+			}
+		}
+	}
+
+	err = s.queries.IndexDocumentContent(ctx, sqlc.IndexDocumentContentParams{
+		DocumentID: doc.ID,
+		Title:      doc.Path,
+		Content:    text.String(),
+		Summary:    SearchText(summary),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to index document", "error", err)
+	}
+	s.indexer.Enqueue(doc.ID)
+
+	if hit {
+		return Result{Document: doc, Cached: true, Summary: summary, Structured: structuredSummary}, nil
+	}
+
+	s.announce(job)
+	return Result{Document: doc, Job: &job}, nil
+}
+
+// ReadStructured loads the structured form of the summary stored at
+// summaryKey. It returns nil without an error when there is none.
+func ReadStructured(ctx context.Context, s3Client *s3.Client, bucketName string, summaryKey string) (*structured.Summary, error) {
+	content, err := clients.ReadObject(ctx, s3Client, bucketName, structured.Key(summaryKey))
+	var noSuchKey *s3types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var summary structured.Summary
+	if err := json.Unmarshal(content, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// Delete removes the caller's document and drops its reference on the blob.
+// pgx.ErrNoRows is returned when the document does not exist or belongs to
+// someone else.
+func (s *Service) Delete(ctx context.Context, userID int32, documentID int32) error {
+	deleted, err := s.queries.DeleteDocument(ctx, sqlc.DeleteDocumentParams{
+		ID:     documentID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	// the document is gone either way; a failed release only leaks storage
+	// and quota. A pending job can no longer report back, so its slot is
+	// freed here.
+	s.release(ctx, deleted.BlobHash)
+	s.unreserve(ctx, userID, deleted.Size, deleted.Status == StatusPending)
+	s.indexer.Forget(documentID)
+	return nil
+}
+
+// SetTags replaces the tags of a document in one transaction, so a failure
+// leaves the old tags rather than some or none.
+func (s *Service) SetTags(ctx context.Context, documentID int32, tags []string) error {
+	return s.inTx(ctx, func(queries *sqlc.Queries) error {
+		if err := queries.DeleteDocumentTags(ctx, documentID); err != nil {
+			return fmt.Errorf("failed to clear tags of document %d: %w", documentID, err)
+		}
+		for _, tag := range tags {
+			err := queries.AddDocumentTag(ctx, sqlc.AddDocumentTagParams{DocumentID: documentID, Tag: tag})
+			if err != nil {
+				return fmt.Errorf("failed to tag document %d: %w", documentID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// unreserve hands back quota that Reserve counted for an upload that didn't
+// happen or a document that is gone.
+func (s *Service) unreserve(ctx context.Context, userID int32, bytes int64, job bool) {
+	// undoes what a request that was cut short reserved, so it must outlive it
+	if err := s.quotas.Release(context.WithoutCancel(ctx), userID, bytes, job); err != nil {
+		slog.ErrorContext(ctx, "failed to release quota", "user_id", userID, "error", err)
+	}
+}
+
+func saveDocument(ctx context.Context, queries *sqlc.Queries, req Request, prev *sqlc.Document, blob storage.Blob, status string, summaryKey pgtype.Text, preview pgtype.Text) (sqlc.Document, error) {
+	if prev == nil {
+		doc, err := queries.CreateDocument(ctx, sqlc.CreateDocumentParams{
+			UserID:         req.UserID,
+			Path:           req.Path,
+			BlobHash:       blob.Hash,
+			Size:           blob.Size,
+			Status:         status,
+			SummaryKey:     summaryKey,
+			RepositoryID:   pgtype.Int4{Int32: req.RepositoryID, Valid: req.RepositoryID != 0},
+			SourceSha:      optionalText(req.SourceSha),
+			SummaryPreview: preview,
+		})
+		if err != nil {
+			return sqlc.Document{}, fmt.Errorf("failed to create document: %w", err)
+		}
+		return doc, nil
+	}
+
+	doc, err := queries.UpdateDocumentContent(ctx, sqlc.UpdateDocumentContentParams{
+		ID:             req.DocumentID,
+		BlobHash:       blob.Hash,
+		Size:           blob.Size,
+		Status:         status,
+		SummaryKey:     summaryKey,
+		SourceSha:      optionalText(req.SourceSha),
+		SummaryPreview: preview,
+	})
+	if err != nil {
+		return sqlc.Document{}, fmt.Errorf("failed to update document: %w", err)
+	}
+	return doc, nil
+}
+
+const previewLength = 280
+
+// Preview shortens a summary for listings, cutting at a word boundary.
+func Preview(summary string) string {
+	summary = strings.Join(strings.Fields(summary), " ")
+	runes := []rune(summary)
+	if len(runes) <= previewLength {
+		return summary
+	}
+	cut := string(runes[:previewLength])
+	if i := strings.LastIndex(cut, " "); i > previewLength/2 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
+
+// NormalizeTags lowercases, trims and deduplicates tags, dropping empty and
+// overlong ones.
+func NormalizeTags(raw []string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, r := range raw {
+		for _, t := range strings.Split(r, ",") {
+			t = strings.ToLower(strings.TrimSpace(t))
+			if t == "" || len(t) > 64 || seen[t] {
+				continue
+			}
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func optionalText(v string) pgtype.Text {
+	return pgtype.Text{String: v, Valid: v != ""}
+}
+
+func (s *Service) release(ctx context.Context, hash string) {
+	if err := s.blobs.Release(context.WithoutCancel(ctx), hash); err != nil {
+		slog.ErrorContext(ctx, "failed to release blob", "blob_hash", hash, "error", err)
+	}
+}
+
+// SealBatch fixes the number of documents in a batch once every entry has been
+// ingested. Cache hits never produce a job, so they are counted as completed
+// right away. The batch is returned when sealing it also finished it.
+func (s *Service) SealBatch(ctx context.Context, batchID int32, total int32, cached int32) (sqlc.Batch, bool, error) {
+	err := s.queries.SealBatch(ctx, sqlc.SealBatchParams{
+		ID:        batchID,
+		Total:     total,
+		Completed: cached,
+	})
+	if err != nil {
+		return sqlc.Batch{}, false, fmt.Errorf("failed to seal batch: %w", err)
+	}
+	return FinishBatch(ctx, s.queries, batchID)
+}
+
+// FinishBatch marks a sealed batch completed once all of its documents are
+// accounted for. Only the caller that flips the status gets ok == true, so
+// the aggregate event is published exactly once.
+func FinishBatch(ctx context.Context, queries *sqlc.Queries, batchID int32) (sqlc.Batch, bool, error) {
+	batch, err := queries.FinishBatch(ctx, batchID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sqlc.Batch{}, false, nil
+	}
+	if err != nil {
+		return sqlc.Batch{}, false, err
+	}
+	return batch, true, nil
+}
+
+func PublishBatchCompleted(broadcaster *events.Broadcaster, batch sqlc.Batch) {
+	broadcaster.Publish(events.BatchCompleted, events.BatchMessage{
+		Type:      events.BatchCompleted,
+		UserID:    strconv.Itoa(int(batch.UserID)),
+		BatchID:   batch.ID,
+		Name:      batch.Name,
+		Total:     batch.Total,
+		Completed: batch.Completed,
+		Failed:    batch.Failed,
+	})
+}
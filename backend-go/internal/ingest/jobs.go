@@ -0,0 +1,295 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	clients "backend-go/internal/clients"
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/dispatch"
+	"backend-go/internal/events"
+	"backend-go/internal/logging"
+	"backend-go/internal/storage"
+	"backend-go/internal/structured"
+	"backend-go/internal/tracing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// StatusCancelled is the status of a cancelled job, and of its document when
+// there is no earlier summary to fall back to.
+const StatusCancelled = "cancelled"
+
+var (
+	// ErrSummaryPending is returned when a document already has a job
+	// waiting or running.
+	ErrSummaryPending = errors.New("a summary of this file is already being made")
+	// ErrJobFinished is returned when cancelling a job that has completed,
+	// failed or been cancelled.
+	ErrJobFinished = errors.New("job has already finished")
+)
+
+// CancelKey is the marker the summarization worker looks for to stop a job
+// it has already received.
+func CancelKey(jobID int32) string {
+	return fmt.Sprintf("cancelled/%d", jobID)
+}
+
+type jobRequest struct {
+	UserID   int32
+	Document sqlc.Document
+	Blob     storage.Blob
+	BatchID  int32
+	Prompt   Prompt
+	Model    string
+	// Lane defaults to interactive.
+	Lane string
+}
+
+// createJob creates a job along with the task the dispatcher sends for it,
+// and keeps the trace context of ctx for the task to carry. queries belongs
+// to the transaction that also saves the document, so there is never a
+// pending document without a job; call announce once it commits.
+func (s *Service) createJob(ctx context.Context, queries *sqlc.Queries, req jobRequest) (sqlc.Job, error) {
+	lane := req.Lane
+	if lane == "" {
+		lane = dispatch.LaneInteractive
+	}
+	job, err := queries.CreateJob(ctx, sqlc.CreateJobParams{
+		DocumentID:      req.Document.ID,
+		UserID:          req.UserID,
+		ContentHash:     req.Blob.Hash,
+		Model:           req.Model,
+		PromptVersion:   req.Prompt.Version,
+		BatchID:         pgtype.Int4{Int32: req.BatchID, Valid: req.BatchID != 0},
+		TemplateID:      pgtype.Int4{Int32: req.Prompt.TemplateID, Valid: true},
+		TemplateVersion: pgtype.Int4{Int32: req.Prompt.TemplateVersion, Valid: true},
+		Format:          req.Prompt.Options.Format,
+		Lane:            lane,
+		TraceContext:    tracing.Encode(ctx),
+	})
+	if err != nil {
+		return sqlc.Job{}, fmt.Errorf("failed to create job: %w", err)
+	}
+
+	// structured jobs write their JSON first; the response worker validates
+	// it and adds the text rendering under the usual key
+	taskKey := SummaryKey(req.Blob.Hash, job.ID)
+	if job.Format == FormatStructured {
+		taskKey = structured.Key(taskKey)
+	}
+
+	body, _ := json.Marshal(TaskMessage{
+		Bucket:          s.bucketName,
+		Key:             req.Blob.StorageKey,
+		UserID:          strconv.Itoa(int(req.UserID)),
+		DocumentID:      req.Document.ID,
+		JobID:           job.ID,
+		ContentHash:     req.Blob.Hash,
+		Model:           job.Model,
+		PromptVersion:   job.PromptVersion,
+		SummaryKey:      taskKey,
+		Prompt:          req.Prompt.Text,
+		Style:           req.Prompt.Options.Style,
+		Length:          req.Prompt.Options.Length,
+		Language:        req.Prompt.Options.Language,
+		Format:          req.Prompt.Options.Format,
+		TemplateID:      req.Prompt.TemplateID,
+		TemplateVersion: req.Prompt.TemplateVersion,
+	})
+
+	// the dispatcher sends the task once it is the job's turn
+	err = queries.SetJobTask(ctx, sqlc.SetJobTaskParams{
+		ID:   job.ID,
+		Task: pgtype.Text{String: string(body), Valid: true},
+	})
+	if err != nil {
+		return sqlc.Job{}, fmt.Errorf("failed to store task of job %d: %w", job.ID, err)
+	}
+	return job, nil
+}
+
+// announce tells the dispatcher and the client about a committed job.
+func (s *Service) announce(job sqlc.Job) {
+	s.dispatcher.Notify()
+	s.broadcaster.Publish(events.JobAccepted, events.JobMessage{
+		Type:       events.JobAccepted,
+		UserID:     strconv.Itoa(int(job.UserID)),
+		DocumentID: job.DocumentID,
+		JobID:      job.ID,
+	})
+}
+
+// newJob builds the job for summarizing a stored document again, with the
+// given overrides over its owner's preferences.
+func (s *Service) newJob(ctx context.Context, doc sqlc.Document, overrides SummaryOptions, lane string) (jobRequest, error) {
+	prompt, err := s.Prompt(ctx, doc.UserID, overrides)
+	if err != nil {
+		return jobRequest{}, err
+	}
+	b, err := s.queries.GetBlob(ctx, doc.BlobHash)
+	if err != nil {
+		return jobRequest{}, fmt.Errorf("failed to load blob: %w", err)
+	}
+	blob := storage.Blob{Hash: b.Hash, Size: b.Size, StorageKey: b.StorageKey}
+
+	model, err := s.chooseModel(ctx, prompt, blob.Size)
+	if err != nil {
+		return jobRequest{}, err
+	}
+	return jobRequest{
+		UserID:   doc.UserID,
+		Document: doc,
+		Blob:     blob,
+		Prompt:   prompt,
+		Model:    model,
+		Lane:     lane,
+	}, nil
+}
+
+// inTx runs fn in a transaction, committing if it returns nil.
+func (s *Service) inTx(ctx context.Context, fn func(queries *sqlc.Queries) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(s.queries.WithTx(tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Resummarize creates a new job for the stored content of one of the
+// caller's documents, with the given overrides over their preferences. The
+// cache is skipped: asking again means the cached summary wasn't wanted.
+// pgx.ErrNoRows is returned when the document does not exist or belongs to
+// someone else.
+func (s *Service) Resummarize(ctx context.Context, userID int32, documentID int32, overrides SummaryOptions, lane string) (Result, error) {
+	doc, err := s.queries.GetDocument(ctx, documentID)
+	if err == nil && doc.UserID != userID {
+		err = pgx.ErrNoRows
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	if doc.Status == StatusPending {
+		return Result{}, ErrSummaryPending
+	}
+
+	jreq, err := s.newJob(ctx, doc, overrides, lane)
+	if err != nil {
+		return Result{}, err
+	}
+	if err := s.quotas.ReserveJob(ctx, userID); err != nil {
+		return Result{}, err
+	}
+
+	var job sqlc.Job
+	err = s.inTx(ctx, func(queries *sqlc.Queries) error {
+		var err error
+		job, err = s.createJob(ctx, queries, jreq)
+		if err != nil {
+			return err
+		}
+		_, err = queries.SetDocumentStatus(ctx, sqlc.SetDocumentStatusParams{ID: doc.ID, Status: StatusPending, JobID: job.ID})
+		if err != nil {
+			return fmt.Errorf("failed to update document: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.unreserve(ctx, userID, 0, true)
+		return Result{}, err
+	}
+	s.announce(job)
+
+	doc.Status = StatusPending
+	return Result{Document: doc, Job: &job}, nil
+}
+
+// Cancel stops one of the caller's jobs. A job still waiting for dispatch
+// never reaches the worker; one the worker may already have is flagged so
+// the worker skips or aborts it, and the response worker drops whatever it
+// reports. pgx.ErrNoRows is returned when the job does not exist or belongs
+// to someone else.
+func (s *Service) Cancel(ctx context.Context, userID int32, jobID int32) (sqlc.Job, error) {
+	job, err := s.queries.GetJob(ctx, jobID)
+	if err == nil && job.UserID != userID {
+		err = pgx.ErrNoRows
+	}
+	if err != nil {
+		return sqlc.Job{}, err
+	}
+
+	job, err = s.queries.CancelJob(ctx, sqlc.CancelJobParams{ID: jobID, UserID: userID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sqlc.Job{}, ErrJobFinished
+	}
+	if err != nil {
+		return sqlc.Job{}, fmt.Errorf("failed to cancel job: %w", err)
+	}
+	ctx = logging.WithJob(ctx, job.ID, job.DocumentID)
+	slog.InfoContext(ctx, "job cancelled", "dispatched", job.DispatchedAt.Valid)
+
+	doc, err := s.queries.GetDocument(ctx, job.DocumentID)
+	if err == nil && doc.Status == StatusPending {
+		status := StatusCancelled
+		if doc.SummaryKey.Valid {
+			// the summary from before this job is still there
+			status = StatusCompleted
+		}
+		_, err := s.queries.SetDocumentStatus(ctx, sqlc.SetDocumentStatusParams{ID: doc.ID, Status: status, JobID: job.ID})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to update document", "error", err)
+		}
+	} else if err != nil {
+		slog.ErrorContext(ctx, "failed to load document", "error", err)
+	}
+
+	s.cancelled(ctx, job)
+	return job, nil
+}
+
+// cancelled stops the worker from summarizing a job that was just
+// cancelled, frees what it holds and tells the client.
+func (s *Service) cancelled(ctx context.Context, job sqlc.Job) {
+	if job.DispatchedAt.Valid {
+		// the quota slot is freed when the worker reports back
+		if err := clients.WriteObject(ctx, s.s3Client, s.bucketName, CancelKey(job.ID), nil, "text/plain"); err != nil {
+			slog.ErrorContext(ctx, "failed to flag job as cancelled", "error", err)
+		}
+	} else {
+		s.unreserve(ctx, job.UserID, 0, true)
+	}
+	// either way the job no longer takes a slot in the task queue
+	s.dispatcher.Notify()
+
+	if job.BatchID.Valid {
+		if err := s.queries.IncrementBatchFailed(ctx, job.BatchID.Int32); err != nil {
+			slog.ErrorContext(ctx, "failed to update batch", "batch_id", job.BatchID.Int32, "error", err)
+		}
+		batch, done, err := FinishBatch(ctx, s.queries, job.BatchID.Int32)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to finish batch", "batch_id", job.BatchID.Int32, "error", err)
+		} else if done {
+			PublishBatchCompleted(s.broadcaster, batch)
+		}
+	}
+
+	s.broadcaster.Publish(events.JobCancelled, events.JobMessage{
+		Type:       events.JobCancelled,
+		UserID:     strconv.Itoa(int(job.UserID)),
+		DocumentID: job.DocumentID,
+		JobID:      job.ID,
+	})
+}
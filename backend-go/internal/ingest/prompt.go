@@ -0,0 +1,197 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/structured"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	StyleTLDR      = "tldr"
+	StyleBullets   = "bullets"
+	StyleExecutive = "executive"
+	StyleDeepDive  = "deep-dive"
+	// StyleCustom uses the latest version of the user's own template.
+	StyleCustom = "custom"
+)
+
+const (
+	LengthShort  = "short"
+	LengthMedium = "medium"
+	LengthLong   = "long"
+)
+
+const (
+	FormatText = "text"
+	// FormatStructured asks for JSON matching structured.Summary, stored
+	// next to its text rendering.
+	FormatStructured = "structured"
+)
+
+// DefaultOptions apply when neither the upload nor the user's preferences
+// choose otherwise. They match the prompt the worker used to hardcode.
+var DefaultOptions = SummaryOptions{
+	Style:    StyleTLDR,
+	Length:   LengthShort,
+	Language: "English",
+	Format:   FormatText,
+	Model:    AutoModel,
+}
+
+// ErrInvalidOptions wraps every problem with user supplied summary options,
+// so handlers can answer 400 with the message.
+var ErrInvalidOptions = errors.New("invalid summary options")
+
+// MaxTemplateLength bounds custom template bodies.
+const MaxTemplateLength = 4000
+
+var lengthInstructions = map[string]string{
+	LengthShort:  "keeping it very short (two sentences or about 50 words at most)",
+	LengthMedium: "in about 150 words",
+	LengthLong:   "in about 400 words",
+}
+
+var (
+	stylePattern    = regexp.MustCompile(`^[a-z0-9-]{1,50}$`)
+	languagePattern = regexp.MustCompile(`^\p{L}[\p{L} ()-]{0,31}$`)
+)
+
+// SummaryOptions selects how a document is summarized. Empty fields fall
+// back to the user's preferences and then to DefaultOptions.
+type SummaryOptions struct {
+	Style    string `json:"style"`
+	Length   string `json:"length"`
+	Language string `json:"language"`
+	Format   string `json:"format"`
+	// Model is a registry id, or AutoModel to route by size and style.
+	Model string `json:"model"`
+}
+
+// Normalize trims the options and checks the ones that are set.
+func (o SummaryOptions) Normalize() (SummaryOptions, error) {
+	o.Style = strings.ToLower(strings.TrimSpace(o.Style))
+	o.Length = strings.ToLower(strings.TrimSpace(o.Length))
+	o.Language = strings.Join(strings.Fields(o.Language), " ")
+	o.Format = strings.ToLower(strings.TrimSpace(o.Format))
+	o.Model = strings.TrimSpace(o.Model)
+
+	if o.Style != "" && !stylePattern.MatchString(o.Style) {
+		return o, fmt.Errorf("%w: unknown style %q", ErrInvalidOptions, o.Style)
+	}
+	if _, ok := lengthInstructions[o.Length]; o.Length != "" && !ok {
+		return o, fmt.Errorf("%w: length must be short, medium or long", ErrInvalidOptions)
+	}
+	if o.Language != "" && !languagePattern.MatchString(o.Language) {
+		return o, fmt.Errorf("%w: invalid language %q", ErrInvalidOptions, o.Language)
+	}
+	if o.Format != "" && o.Format != FormatText && o.Format != FormatStructured {
+		return o, fmt.Errorf("%w: format must be text or structured", ErrInvalidOptions)
+	}
+	if len(o.Model) > 255 {
+		return o, fmt.Errorf("%w: unknown model", ErrInvalidOptions)
+	}
+	return o, nil
+}
+
+func (o SummaryOptions) or(fallback SummaryOptions) SummaryOptions {
+	if o.Style == "" {
+		o.Style = fallback.Style
+	}
+	if o.Length == "" {
+		o.Length = fallback.Length
+	}
+	if o.Language == "" {
+		o.Language = fallback.Language
+	}
+	if o.Format == "" {
+		o.Format = fallback.Format
+	}
+	if o.Model == "" {
+		o.Model = fallback.Model
+	}
+	return o
+}
+
+// Prompt is a template rendered for one job.
+type Prompt struct {
+	TemplateID      int32
+	TemplateVersion int32
+	Options         SummaryOptions
+	Text            string
+	// Version keys the summary cache, so a different template version,
+	// length, language or format never reuses an old summary.
+	Version string
+}
+
+// Prompt resolves the options for an upload against the user's preferences
+// and renders the matching template.
+func (s *Service) Prompt(ctx context.Context, userID int32, overrides SummaryOptions) (Prompt, error) {
+	opts, err := overrides.Normalize()
+	if err != nil {
+		return Prompt{}, err
+	}
+
+	prefs, err := s.queries.GetSummaryPreferences(ctx, userID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return Prompt{}, fmt.Errorf("failed to load summary preferences: %w", err)
+	}
+	if err == nil {
+		opts = opts.or(SummaryOptions{Style: prefs.Style, Length: prefs.Length, Language: prefs.Language, Format: prefs.Format, Model: prefs.Model})
+	}
+	opts = opts.or(DefaultOptions)
+
+	if opts.Model != AutoModel {
+		model, err := s.queries.GetLlmModel(ctx, opts.Model)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !model.Enabled) {
+			return Prompt{}, fmt.Errorf("%w: unknown model %q", ErrInvalidOptions, opts.Model)
+		}
+		if err != nil {
+			return Prompt{}, fmt.Errorf("failed to load model: %w", err)
+		}
+	}
+
+	var template sqlc.PromptTemplate
+	if opts.Style == StyleCustom {
+		template, err = s.queries.GetCustomPromptTemplate(ctx, pgtype.Int4{Int32: userID, Valid: true})
+	} else {
+		template, err = s.queries.GetPromptTemplate(ctx, opts.Style)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		if opts.Style == StyleCustom {
+			return Prompt{}, fmt.Errorf("%w: no custom template saved", ErrInvalidOptions)
+		}
+		return Prompt{}, fmt.Errorf("%w: unknown style %q", ErrInvalidOptions, opts.Style)
+	}
+	if err != nil {
+		return Prompt{}, fmt.Errorf("failed to load prompt template: %w", err)
+	}
+
+	prompt := Prompt{
+		TemplateID:      template.ID,
+		TemplateVersion: template.Version,
+		Options:         opts,
+		Text:            RenderTemplate(template.Body, opts),
+		Version:         fmt.Sprintf("t%d/%s/%s", template.ID, opts.Length, strings.ToLower(opts.Language)),
+	}
+	if opts.Format == FormatStructured {
+		prompt.Text += "\n\n" + structured.Instruction
+		prompt.Version += "/json"
+	}
+	return prompt, nil
+}
+
+// RenderTemplate fills in the {{length}} and {{language}} placeholders.
+func RenderTemplate(body string, opts SummaryOptions) string {
+	return strings.NewReplacer(
+		"{{length}}", lengthInstructions[opts.Length],
+		"{{language}}", opts.Language,
+	).Replace(body)
+}
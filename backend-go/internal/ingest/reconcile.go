@@ -0,0 +1,97 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/dispatch"
+)
+
+// ReconcileInterval is how often the reconciler runs.
+const ReconcileInterval = time.Minute
+
+const (
+	// reconcileGrace keeps the sweep away from uploads and dispatches that
+	// are still in progress.
+	reconcileGrace = 5 * time.Minute
+	// reconcileBatch caps the documents requeued by one sweep.
+	reconcileBatch = 100
+)
+
+// StartReconciler runs Reconcile every interval until ctx is done.
+func (s *Service) StartReconciler(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if _, err := s.Reconcile(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("failed to reconcile jobs", "error", err)
+			}
+		}
+	}()
+}
+
+// Reconcile repairs what a crash can leave behind. Jobs the dispatcher
+// claimed without getting their task into the outbox go back to waiting,
+// and pending documents with no job and no summary, e.g. from before the
+// document and job were saved together, get a new job. It returns how many
+// documents it requeued.
+func (s *Service) Reconcile(ctx context.Context) (int, error) {
+	n, err := s.queries.UnclaimLostJobs(ctx, reconcileGrace.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to put back lost jobs: %w", err)
+	}
+	if n > 0 {
+		slog.Info("put back jobs whose task was never sent", "count", n)
+		s.dispatcher.Notify()
+	}
+
+	docs, err := s.queries.ListStalledDocuments(ctx, sqlc.ListStalledDocumentsParams{
+		GraceSeconds: reconcileGrace.Seconds(),
+		MaxDocuments: reconcileBatch,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list stalled documents: %w", err)
+	}
+
+	requeued := 0
+	for _, doc := range docs {
+		if err := s.requeue(ctx, doc); err != nil {
+			slog.ErrorContext(ctx, "failed to requeue document", "document_id", doc.ID, "error", err)
+			continue
+		}
+		requeued++
+	}
+	if requeued > 0 {
+		slog.Info("requeued documents that had no job", "count", requeued)
+	}
+	return requeued, nil
+}
+
+// requeue creates a job for a pending document that lost its own. Quota
+// isn't reserved again: the upload was counted when it came in.
+func (s *Service) requeue(ctx context.Context, doc sqlc.Document) error {
+	jreq, err := s.newJob(ctx, doc, SummaryOptions{}, dispatch.LaneInteractive)
+	if err != nil {
+		return err
+	}
+
+	var job sqlc.Job
+	err = s.inTx(ctx, func(queries *sqlc.Queries) error {
+		job, err = s.createJob(ctx, queries, jreq)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.announce(job)
+	return nil
+}
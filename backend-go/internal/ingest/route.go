@@ -0,0 +1,120 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	sqlc "backend-go/internal/db/sqlc"
+)
+
+// AutoModel lets Route pick the model.
+const AutoModel = "auto"
+
+// styleQuality is the lowest model quality a style is routed to. Styles not
+// listed are fine with any model.
+var styleQuality = map[string]int32{
+	StyleExecutive: 2,
+	StyleDeepDive:  2,
+}
+
+// outputTokens is the expected size of a summary of each length, used to
+// reserve room in the context window and to compare prices.
+var outputTokens = map[string]int{
+	LengthShort:  150,
+	LengthMedium: 400,
+	LengthLong:   1000,
+}
+
+// EstimateTokens guesses the token count of n bytes of text.
+func EstimateTokens(n int64) int64 {
+	return n/4 + 1
+}
+
+// Cost is the price in USD of a completion; model prices are per million
+// tokens.
+func Cost(model sqlc.LlmModel, promptTokens, completionTokens int32) float64 {
+	return (float64(promptTokens)*model.PromptPrice + float64(completionTokens)*model.CompletionPrice) / 1e6
+}
+
+// Route picks the model for summarizing size bytes with the given prompt:
+// the cheapest model that is good enough for the style and fits the whole
+// document into its context window. When none fits, the largest window
+// wins and the worker summarizes the document in parts.
+func Route(models []sqlc.LlmModel, prompt Prompt, size int64) (sqlc.LlmModel, bool) {
+	if len(models) == 0 {
+		return sqlc.LlmModel{}, false
+	}
+
+	in := EstimateTokens(size + int64(len(prompt.Text)))
+	out := int64(outputTokens[prompt.Options.Length])
+	if prompt.Options.Format == FormatStructured {
+		out += 300
+	}
+	cost := func(m sqlc.LlmModel) float64 {
+		return Cost(m, int32(min(in, 1<<30)), int32(out))
+	}
+
+	candidates := qualified(models, styleQuality[prompt.Options.Style])
+	if len(candidates) == 0 {
+		candidates = models
+	}
+
+	var fitting []sqlc.LlmModel
+	for _, m := range candidates {
+		if in+out <= int64(m.ContextTokens) {
+			fitting = append(fitting, m)
+		}
+	}
+	if len(fitting) == 0 {
+		largest := candidates[0]
+		for _, m := range candidates[1:] {
+			if m.ContextTokens > largest.ContextTokens {
+				largest = m
+			}
+		}
+		return largest, true
+	}
+
+	// among equally priced models the smaller one is usually faster
+	sort.SliceStable(fitting, func(i, j int) bool {
+		ci, cj := cost(fitting[i]), cost(fitting[j])
+		if ci != cj {
+			return ci < cj
+		}
+		if fitting[i].ContextTokens != fitting[j].ContextTokens {
+			return fitting[i].ContextTokens < fitting[j].ContextTokens
+		}
+		return fitting[i].ID < fitting[j].ID
+	})
+	return fitting[0], true
+}
+
+func qualified(models []sqlc.LlmModel, quality int32) []sqlc.LlmModel {
+	var res []sqlc.LlmModel
+	for _, m := range models {
+		if m.Quality >= quality {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// chooseModel returns the model the user asked for, or routes among the
+// enabled ones.
+func (s *Service) chooseModel(ctx context.Context, prompt Prompt, size int64) (string, error) {
+	if prompt.Options.Model != AutoModel {
+		return prompt.Options.Model, nil
+	}
+
+	models, err := s.queries.ListLlmModels(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load models: %w", err)
+	}
+	model, ok := Route(models, prompt, size)
+	if !ok {
+		// an empty registry shouldn't stop uploads
+		return DefaultModel, nil
+	}
+	return model.ID, nil
+}
@@ -0,0 +1,40 @@
+package ingest
+
+import "strings"
+
+// maxSearchText caps how much of a document is indexed. Postgres refuses
+// tsvectors over 1 MB and the head of a document is what matters for
+// ranking anyway.
+const maxSearchText = 256 << 10
+
+// SearchText makes s safe to store in a text column for indexing: invalid
+// UTF-8 and NUL bytes are dropped and the result is cut to maxSearchText.
+func SearchText(s string) string {
+	if len(s) > maxSearchText {
+		// a rune split by the cut is dropped as invalid below
+		s = s[:maxSearchText]
+	}
+	s = strings.ToValidUTF8(s, "")
+	return strings.ReplaceAll(s, "\x00", "")
+}
+
+// searchBuffer keeps the first maxSearchText bytes written to it and
+// discards the rest, so uploads can be indexed while they stream to storage.
+type searchBuffer struct {
+	b strings.Builder
+}
+
+func (sb *searchBuffer) Write(p []byte) (int, error) {
+	if room := maxSearchText - sb.b.Len(); room > 0 {
+		if len(p) > room {
+			sb.b.Write(p[:room])
+		} else {
+			sb.b.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (sb *searchBuffer) String() string {
+	return SearchText(sb.b.String())
+}
@@ -0,0 +1,154 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const DefaultURL = "https://openrouter.ai/api/v1/chat/completions"
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Client talks to an OpenAI-compatible chat completions endpoint such as
+// OpenRouter, the provider the summarization worker uses.
+type Client struct {
+	url    string
+	apiKey string
+	http   *http.Client
+}
+
+func NewClient(url, apiKey string) *Client {
+	if url == "" {
+		url = DefaultURL
+	}
+	return &Client{
+		url:    url,
+		apiKey: apiKey,
+		// no overall timeout: streams last as long as the answer, and the
+		// caller's context bounds them
+		http: &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+	}
+}
+
+// Usage is the token count of a completion as reported by the provider.
+type Usage struct {
+	PromptTokens     int32 `json:"prompt_tokens"`
+	CompletionTokens int32 `json:"completion_tokens"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []Message      `json:"messages"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	// Usage comes with the last chunk.
+	Usage *Usage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Stream requests a completion and calls onToken with every piece of
+// content as it arrives. The full answer is returned at the end, with the
+// usage if the provider reported it before the stream ended. An error from
+// onToken stops the stream.
+func (c *Client) Stream(ctx context.Context, model string, messages []Message, onToken func(string) error) (string, Usage, error) {
+	body, _ := json.Marshal(chatRequest{
+		Model:         model,
+		Messages:      messages,
+		Stream:        true,
+		StreamOptions: &streamOptions{IncludeUsage: true},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return "", Usage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", Usage{}, fmt.Errorf("completion request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", Usage{}, fmt.Errorf("completion request failed with %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var answer strings.Builder
+	var usage Usage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// blank lines separate events; lines starting with ':' are
+		// keep-alive comments
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return answer.String(), usage, nil
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return answer.String(), usage, fmt.Errorf("failed to decode completion chunk: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+		if chunk.Error != nil {
+			return answer.String(), usage, errors.New(chunk.Error.Message)
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			answer.WriteString(choice.Delta.Content)
+			if err := onToken(choice.Delta.Content); err != nil {
+				return answer.String(), usage, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return answer.String(), usage, fmt.Errorf("completion stream failed: %w", err)
+	}
+	return answer.String(), usage, nil
+}
+
+// Complete requests a completion and returns it once it is finished.
+func (c *Client) Complete(ctx context.Context, model string, messages []Message) (string, error) {
+	text, _, err := c.Stream(ctx, model, messages, func(string) error { return nil })
+	return text, err
+}
@@ -0,0 +1,125 @@
+// Package logging sets up the backend's structured logger. Log lines carry
+// the request, job and document they are about, taken from the context, and
+// secrets are redacted before anything is written.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Config struct {
+	Level slog.Level
+	// Format is text or json.
+	Format string
+}
+
+var DefaultConfig = Config{
+	Level:  slog.LevelInfo,
+	Format: FormatText,
+}
+
+// Setup makes a logger writing to stderr the default, for slog and for the
+// standard log package, whose lines then come out at info level.
+func Setup(cfg Config) *slog.Logger {
+	logger := New(os.Stderr, cfg)
+	slog.SetDefault(logger)
+	// gin's route list and warnings in debug mode
+	gin.DebugPrintFunc = func(format string, values ...any) {
+		logger.Debug(strings.TrimSpace(fmt.Sprintf(format, values...)))
+	}
+	return logger
+}
+
+// New returns a logger writing to w.
+func New(w io.Writer, cfg Config) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.Level, ReplaceAttr: redactAttr}
+	var h slog.Handler
+	if cfg.Format == FormatJSON {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(contextHandler{h})
+}
+
+type attrsKey struct{}
+
+// With returns ctx with attrs added to every line logged with it.
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	all := make([]slog.Attr, 0, len(prev)+len(attrs))
+	all = append(all, prev...)
+	all = append(all, attrs...)
+	return context.WithValue(ctx, attrsKey{}, all)
+}
+
+// WithJob returns ctx with the job and document IDs added to every line
+// logged with it. Zero IDs are left out.
+func WithJob(ctx context.Context, jobID int32, documentID int32) context.Context {
+	var attrs []slog.Attr
+	if jobID != 0 {
+		attrs = append(attrs, slog.Int("job_id", int(jobID)))
+	}
+	if documentID != 0 {
+		attrs = append(attrs, slog.Int("document_id", int(documentID)))
+	}
+	if len(attrs) == 0 {
+		return ctx
+	}
+	return With(ctx, attrs...)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx with the request ID added to every line logged
+// with it.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return With(ctx, slog.String("request_id", id))
+}
+
+// RequestID returns the ID of the request ctx belongs to, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds the attributes stored in the context, and the trace
+// and span IDs, to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
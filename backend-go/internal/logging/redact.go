@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces secrets in log lines.
+const Redacted = "[REDACTED]"
+
+// secretKeys are attribute names whose values are never logged.
+var secretKeys = map[string]bool{
+	"password":      true,
+	"passwd":        true,
+	"secret":        true,
+	"token":         true,
+	"api_key":       true,
+	"apikey":        true,
+	"authorization": true,
+	"cookie":        true,
+	"set_cookie":    true,
+	"session":       true,
+	"session_id":    true,
+	"credentials":   true,
+}
+
+// secretSuffixes catch names like db_password or openrouter_api_key.
+var secretSuffixes = []string{"_password", "_secret", "_token", "_api_key", "_apikey", "_credentials"}
+
+// IsSecret reports whether a value named key must not be shown, e.g.
+// "password", "OPENROUTER_API_KEY" or "X-Session-Token".
+func IsSecret(key string) bool {
+	k := strings.ToLower(strings.ReplaceAll(key, "-", "_"))
+	if secretKeys[k] {
+		return true
+	}
+	for _, suffix := range secretSuffixes {
+		if strings.HasSuffix(k, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// the password in a URL such as a DATABASE_URL
+	{regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://[^:/@\s]+:)[^@\s]+@`), "${1}" + Redacted + "@"},
+	// Authorization headers
+	{regexp.MustCompile(`(?i)\b(bearer)\s+[A-Za-z0-9._~+/=-]+`), "${1} " + Redacted},
+	// key=value settings, e.g. in a connection string
+	{regexp.MustCompile(`(?i)\b(password|passwd|secret|token|api_key|apikey)=[^\s&;]+`), "${1}=" + Redacted},
+}
+
+// Redact removes the secrets it recognizes in s: passwords in URLs and
+// connection strings, and bearer tokens.
+func Redact(s string) string {
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// redactAttr drops the values of secret attributes and redacts the
+// message, string values and errors.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if IsSecret(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+	}
+	return a
+}
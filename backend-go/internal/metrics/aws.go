@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"context"
+	"path"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// AWSMiddleware times every call of an S3 or SQS client and, for SQS,
+// counts the messages received, sent and deleted and the failed calls.
+// Add it to the client's APIOptions.
+func AWSMiddleware(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("Metrics", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+		start := time.Now()
+		out, md, err := next.HandleInitialize(ctx, in)
+
+		service := awsmiddleware.GetServiceID(ctx)
+		operation := awsmiddleware.GetOperationName(ctx)
+		outcome := "ok"
+		if err != nil {
+			outcome = "error"
+		}
+		AWSRequestDuration.WithLabelValues(service, operation, outcome).Observe(time.Since(start).Seconds())
+
+		if service == sqs.ServiceID {
+			recordSQS(operation, in.Parameters, out.Result, err)
+		}
+		return out, md, err
+	}), middleware.After)
+}
+
+func recordSQS(operation string, params any, result any, err error) {
+	var queueUrl *string
+	switch p := params.(type) {
+	case *sqs.ReceiveMessageInput:
+		queueUrl = p.QueueUrl
+	case *sqs.DeleteMessageInput:
+		queueUrl = p.QueueUrl
+	case *sqs.SendMessageInput:
+		queueUrl = p.QueueUrl
+	case *sqs.GetQueueAttributesInput:
+		queueUrl = p.QueueUrl
+	case *sqs.ChangeMessageVisibilityInput:
+		queueUrl = p.QueueUrl
+	case *sqs.GetQueueUrlInput:
+		queueUrl = p.QueueName
+	case *sqs.CreateQueueInput:
+		queueUrl = p.QueueName
+	}
+	queue := "unknown"
+	if queueUrl != nil {
+		queue = path.Base(*queueUrl)
+	}
+
+	if err != nil {
+		SQSErrors.WithLabelValues(queue, operation).Inc()
+		return
+	}
+	switch r := result.(type) {
+	case *sqs.ReceiveMessageOutput:
+		SQSMessagesReceived.WithLabelValues(queue).Add(float64(len(r.Messages)))
+	case *sqs.DeleteMessageOutput:
+		SQSMessagesDeleted.WithLabelValues(queue).Inc()
+	case *sqs.SendMessageOutput:
+		SQSMessagesSent.WithLabelValues(queue).Inc()
+	}
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	sqlc "backend-go/internal/db/sqlc"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scrapeTimeout bounds the queries a scrape runs.
+const scrapeTimeout = 5 * time.Second
+
+var (
+	jobsDesc = prometheus.NewDesc("jobs", "Jobs by status.", []string{"status"}, nil)
+
+	queueDepthDesc = prometheus.NewDesc("sqs_queue_messages",
+		"Approximate messages in a queue by state: visible, in_flight (received but not deleted) or delayed.",
+		[]string{"queue", "state"}, nil)
+
+	queueAttributes = map[types.QueueAttributeName]string{
+		types.QueueAttributeNameApproximateNumberOfMessages:           "visible",
+		types.QueueAttributeNameApproximateNumberOfMessagesNotVisible: "in_flight",
+		types.QueueAttributeNameApproximateNumberOfMessagesDelayed:    "delayed",
+	}
+)
+
+// stateCollector reads the number of jobs per status from the database and
+// the depth of the queues from SQS whenever metrics are scraped.
+type stateCollector struct {
+	queries   *sqlc.Queries
+	sqsClient *sqs.Client
+	queues    []string
+}
+
+// RegisterStateCollector adds the jobs and queue depth gauges, read at
+// scrape time.
+func RegisterStateCollector(queries *sqlc.Queries, sqsClient *sqs.Client, queues []string) {
+	prometheus.MustRegister(&stateCollector{queries: queries, sqsClient: sqsClient, queues: queues})
+}
+
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- jobsDesc
+	ch <- queueDepthDesc
+}
+
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+
+	counts, err := c.queries.CountJobsByStatus(ctx)
+	if err != nil {
+		slog.Error("failed to count jobs for metrics", "error", err)
+	}
+	for _, row := range counts {
+		ch <- prometheus.MustNewConstMetric(jobsDesc, prometheus.GaugeValue, float64(row.Jobs), row.Status)
+	}
+
+	for _, queue := range c.queues {
+		if err := c.collectQueue(ctx, ch, queue); err != nil {
+			slog.Error("failed to read queue depth for metrics", "queue", queue, "error", err)
+		}
+	}
+}
+
+func (c *stateCollector) collectQueue(ctx context.Context, ch chan<- prometheus.Metric, queue string) error {
+	url, err := c.sqsClient.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queue)})
+	if err != nil {
+		return err
+	}
+	names := make([]types.QueueAttributeName, 0, len(queueAttributes))
+	for name := range queueAttributes {
+		names = append(names, name)
+	}
+	out, err := c.sqsClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       url.QueueUrl,
+		AttributeNames: names,
+	})
+	if err != nil {
+		return err
+	}
+	for name, state := range queueAttributes {
+		n, err := strconv.ParseFloat(out.Attributes[string(name)], 64)
+		if err != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, n, queue, state)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// Package metrics defines the backend's Prometheus metrics. They are
+// registered with the default registry, which Handler serves together with
+// the Go runtime and process metrics.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time to answer HTTP requests by method, route and status. Event streams count until they close.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	UploadBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "upload_bytes_total",
+		Help: "Bytes of documents stored by uploads, archive uploads and repository imports.",
+	})
+
+	UploadSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "upload_size_bytes",
+		Help:    "Size of stored documents.",
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 8),
+	})
+
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "job_duration_seconds",
+		Help:    "Time from a job's creation to its result, by status.",
+		Buckets: []float64{1, 2.5, 5, 10, 20, 30, 60, 120, 300, 600, 1800},
+	}, []string{"status"})
+
+	SQSMessagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqs_messages_received_total",
+		Help: "Messages received from SQS by queue.",
+	}, []string{"queue"})
+
+	SQSMessagesDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqs_messages_deleted_total",
+		Help: "Messages deleted from SQS by queue.",
+	}, []string{"queue"})
+
+	SQSMessagesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqs_messages_sent_total",
+		Help: "Messages sent to SQS by queue.",
+	}, []string{"queue"})
+
+	SQSErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqs_errors_total",
+		Help: "Failed SQS calls by queue and operation.",
+	}, []string{"queue", "operation"})
+
+	AWSRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "aws_request_duration_seconds",
+		Help:    "Duration of S3 and SQS calls, retries included, by service, operation and outcome.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 25},
+	}, []string{"service", "operation", "outcome"})
+
+	SSESubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sse_subscribers",
+		Help: "Open event streams.",
+	})
+
+	SSEDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sse_dropped_messages_total",
+		Help: "Events that found a subscriber too far behind, closing its stream, by event type.",
+	}, []string{"event"})
+
+	LLMTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "llm_tokens_total",
+		Help: "Tokens used by summarization jobs, by model and kind (prompt or completion).",
+	}, []string{"model", "kind"})
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() gin.HandlerFunc {
+	h := promhttp.Handler()
+	return func(c *gin.Context) {
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// HTTPMiddleware counts requests and times them. Requests are labelled with
+// their route pattern rather than their path, so IDs don't create a series
+// each.
+func HTTPMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RecordUpload counts a stored document.
+func RecordUpload(size int64) {
+	UploadBytes.Add(float64(size))
+	UploadSize.Observe(float64(size))
+}
+
+// RecordTokens counts the tokens a job used.
+func RecordTokens(model string, promptTokens int32, completionTokens int32) {
+	LLMTokens.WithLabelValues(model, "prompt").Add(float64(promptTokens))
+	LLMTokens.WithLabelValues(model, "completion").Add(float64(completionTokens))
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	db "backend-go/internal/db/sqlc"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware lets only admins through. It must run after
+// SessionMiddleware.
+func AdminMiddleware(queries *db.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("user_id")
+		id, ok := userID.(int32)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "access denied"})
+			return
+		}
+
+		user, err := queries.GetUser(c, id)
+		if err != nil || !user.IsAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+
+	"backend-go/internal/idempotency"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxStoredResponse is the largest response kept for replay; requests
+// with a bigger response can be retried as if they had no key.
+const maxStoredResponse = 1 << 20
+
+// IdempotencyMiddleware makes mutating requests that carry an
+// Idempotency-Key safe to retry: the first response is stored and replayed
+// for later requests with the same key. Reusing a key for a different
+// request is rejected. It must run after SessionMiddleware. Streaming
+// routes should not use it; a response the handler flushes early is
+// passed through and not stored.
+func IdempotencyMiddleware(store *idempotency.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotency.Header)
+		if key == "" || !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if len(key) > idempotency.MaxKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid idempotency key"})
+			return
+		}
+		v, _ := c.Get("user_id")
+		userID, ok := v.(int32)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "access denied"})
+			return
+		}
+
+		fingerprint, cleanup, err := idempotency.Fingerprint(c.Request)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request"})
+			return
+		}
+		defer cleanup()
+
+		rec, claimed, err := store.Claim(c, userID, key, fingerprint)
+		if err != nil {
+			slog.ErrorContext(c, "failed to claim idempotency key", "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check idempotency key"})
+			return
+		}
+		if !claimed {
+			switch {
+			case rec.Fingerprint != fingerprint:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "idempotency key was used for a different request"})
+			case rec.Status != idempotency.StatusCompleted:
+				c.Header("Retry-After", "1")
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this idempotency key is in progress"})
+			default:
+				c.Header(idempotency.ReplayedHeader, "true")
+				c.Data(int(rec.ResponseStatus.Int32), rec.ContentType.String, rec.ResponseBody)
+				c.Abort()
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// the request is over; store its outcome even if the client left
+		ctx := context.WithoutCancel(c)
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests || recorder.overflow || recorder.streamed {
+			if err := store.Release(ctx, userID, key); err != nil {
+				slog.ErrorContext(ctx, "failed to release idempotency key", "error", err)
+			}
+			return
+		}
+		err = store.Complete(ctx, userID, key, status, recorder.body.Bytes(), recorder.Header().Get("Content-Type"))
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to store idempotent response", "error", err)
+		}
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// responseRecorder keeps a copy of what the handler writes. It unwraps to
+// the underlying writer so http.ResponseController still reaches it, e.g.
+// to set write deadlines.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	overflow bool
+	// streamed is set once the handler flushes, which only streaming
+	// responses do; they can't be replayed.
+	streamed bool
+}
+
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseRecorder) Flush() {
+	w.streamed = true
+	w.body.Reset()
+	w.ResponseWriter.Flush()
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.record(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseRecorder) record(b []byte) {
+	if w.overflow || w.streamed {
+		return
+	}
+	if w.body.Len()+len(b) > maxStoredResponse {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(b)
+}
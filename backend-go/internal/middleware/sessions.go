@@ -2,6 +2,8 @@ package middleware
 
 import (
 	db "backend-go/internal/db/sqlc"
+	"backend-go/internal/logging"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -22,6 +24,7 @@ func SessionMiddleware(queries *db.Queries) gin.HandlerFunc {
 			return
 		}
 		c.Set("user_id", session.UserID)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), slog.Int("user_id", int(session.UserID))))
 		c.Next()
 	}
 }
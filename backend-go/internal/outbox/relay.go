@@ -0,0 +1,228 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/dispatch"
+	"backend-go/internal/logging"
+	"backend-go/internal/tracing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// TaskQueue is the name outbox messages use for the worker's task queue.
+// Messages name queues by their role rather than the SQS name, which is
+// configured, so that renaming a queue doesn't strand unsent messages.
+const TaskQueue = "tasks"
+
+type Config struct {
+	// BatchSize is how many messages are claimed at a time.
+	BatchSize int
+	// Lease is how long claimed messages are left to the relay sending
+	// them. If it stops before marking them, another relay sends them
+	// once the lease is up.
+	Lease time.Duration
+	// Interval is how often the relay looks for messages without being
+	// notified, which is also when failed ones are retried.
+	Interval time.Duration
+	// MinBackoff and MaxBackoff bound the wait before a failed message is
+	// sent again; it doubles with every attempt.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Retention is how long sent messages are kept once their job is done.
+	Retention time.Duration
+}
+
+var DefaultConfig = Config{
+	BatchSize:  20,
+	Lease:      time.Minute,
+	Interval:   time.Second,
+	MinBackoff: time.Second,
+	MaxBackoff: 5 * time.Minute,
+	Retention:  24 * time.Hour,
+}
+
+// Relay publishes the messages written to the outbox to the queues they
+// name. A message is marked sent once its queue accepts it, so it is sent at
+// least once; if the relay stops between the two it is sent again.
+type Relay struct {
+	queries *sqlc.Queries
+	queues  map[string]dispatch.Queue
+	cfg     Config
+	wake    chan struct{}
+}
+
+func NewRelay(queries *sqlc.Queries, queues map[string]dispatch.Queue, cfg Config) *Relay {
+	return &Relay{
+		queries: queries,
+		queues:  queues,
+		cfg:     cfg,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Notify asks for the outbox to be relayed soon. It never blocks.
+func (r *Relay) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Queue returns the outbox as a queue for the dispatcher: sending a task
+// writes it to the outbox for the relay to publish to name.
+func (r *Relay) Queue(name string) *Queue {
+	return &Queue{relay: r, name: name}
+}
+
+// Start relays whenever notified and every Interval until ctx is done.
+func (r *Relay) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.cfg.Interval)
+		defer ticker.Stop()
+		lastCleanup := time.Time{}
+		for {
+			if _, err := r.Relay(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("failed to relay outbox", "error", err)
+			}
+			if time.Since(lastCleanup) > time.Hour {
+				r.cleanup(ctx)
+				lastCleanup = time.Now()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.wake:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Relay sends the messages that are due, batch by batch, and returns how
+// many it sent.
+func (r *Relay) Relay(ctx context.Context) (int, error) {
+	sent := 0
+	for {
+		n, more, err := r.relayBatch(ctx)
+		sent += n
+		if err != nil || !more {
+			return sent, err
+		}
+	}
+}
+
+// relayBatch sends one batch. Its messages are claimed first, in a
+// statement of their own, so no transaction stays open while they are sent
+// and relays in other backends skip them until the lease is up.
+func (r *Relay) relayBatch(ctx context.Context) (int, bool, error) {
+	messages, err := r.queries.ClaimDueOutboxMessages(ctx, sqlc.ClaimDueOutboxMessagesParams{
+		LeaseSeconds: r.cfg.Lease.Seconds(),
+		MaxMessages:  int32(r.cfg.BatchSize),
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+
+	sent, failed := 0, 0
+	for _, m := range messages {
+		// continue the trace of the request that created the job
+		msgCtx := tracing.Decode(ctx, m.TraceContext)
+		if err := r.send(msgCtx, m); err != nil {
+			delay := r.backoff(m.Attempts)
+			slog.WarnContext(logging.WithJob(msgCtx, m.JobID.Int32, 0), "failed to send outbox message",
+				"outbox_message_id", m.ID,
+				"queue", m.QueueName,
+				"attempt", m.Attempts+1,
+				"retry_in", delay,
+				"error", err,
+			)
+			err = r.queries.MarkOutboxMessageFailed(ctx, sqlc.MarkOutboxMessageFailedParams{
+				ID:           m.ID,
+				LastError:    pgtype.Text{String: err.Error(), Valid: true},
+				DelaySeconds: delay.Seconds(),
+			})
+			if err != nil {
+				return sent, false, fmt.Errorf("failed to record failure of outbox message %d: %w", m.ID, err)
+			}
+			failed++
+			continue
+		}
+		if err := r.queries.MarkOutboxMessageSent(ctx, m.ID); err != nil {
+			return sent, false, fmt.Errorf("failed to mark outbox message %d sent: %w", m.ID, err)
+		}
+		sent++
+	}
+
+	// a failure probably means SQS is down; wait for the retries
+	return sent, len(messages) == r.cfg.BatchSize && failed == 0, nil
+}
+
+func (r *Relay) send(ctx context.Context, m sqlc.OutboxMessage) error {
+	q, ok := r.queues[m.QueueName]
+	if !ok {
+		return fmt.Errorf("unknown queue %q", m.QueueName)
+	}
+	return q.Send(ctx, m.JobID.Int32, m.Body)
+}
+
+func (r *Relay) backoff(attempts int32) time.Duration {
+	d := r.cfg.MinBackoff
+	for i := int32(0); i < attempts && d < r.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, r.cfg.MaxBackoff)
+}
+
+func (r *Relay) cleanup(ctx context.Context) {
+	n, err := r.queries.DeleteSentOutboxMessages(ctx, r.cfg.Retention.Seconds())
+	if err != nil {
+		slog.Error("failed to delete sent outbox messages", "error", err)
+		return
+	}
+	if n > 0 {
+		slog.Info("deleted sent outbox messages", "count", n)
+	}
+}
+
+// Queue adds messages for one queue to the outbox. The dispatcher sends its
+// tasks through it, so a task it has claimed is published even if SQS is
+// down at that moment. A message for a job carries the job's trace context.
+type Queue struct {
+	relay *Relay
+	name  string
+}
+
+var _ dispatch.TxQueue = (*Queue)(nil)
+
+func (q *Queue) Send(ctx context.Context, jobID int32, body string) error {
+	if err := q.add(ctx, q.relay.queries, jobID, body); err != nil {
+		return err
+	}
+	q.relay.Notify()
+	return nil
+}
+
+// SendTx adds the message in tx, e.g. the one claiming the job, so the
+// message exists if and only if tx commits.
+func (q *Queue) SendTx(ctx context.Context, tx pgx.Tx, jobID int32, body string) error {
+	return q.add(ctx, q.relay.queries.WithTx(tx), jobID, body)
+}
+
+// Notify tells the relay about messages added with SendTx.
+func (q *Queue) Notify() {
+	q.relay.Notify()
+}
+
+func (q *Queue) add(ctx context.Context, queries *sqlc.Queries, jobID int32, body string) error {
+	return queries.CreateOutboxMessage(ctx, sqlc.CreateOutboxMessageParams{
+		QueueName: q.name,
+		Body:      body,
+		JobID:     pgtype.Int4{Int32: jobID, Valid: jobID != 0},
+	})
+}
@@ -0,0 +1,368 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	sqlc "backend-go/internal/db/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Names of the limits, as reported in errors and by the quota endpoint.
+const (
+	LimitBytes          = "bytes"
+	LimitUploadsPerDay  = "uploadsPerDay"
+	LimitTokensPerMonth = "tokensPerMonth"
+	LimitCostPerMonth   = "costPerMonth"
+	LimitPendingJobs    = "pendingJobs"
+)
+
+// Limits caps what one user may use. Zero means unlimited.
+type Limits struct {
+	MaxBytes       int64   `json:"maxBytes"`
+	UploadsPerDay  int32   `json:"uploadsPerDay"`
+	TokensPerMonth int64   `json:"tokensPerMonth"`
+	CostPerMonth   float64 `json:"costPerMonth"`
+	PendingJobs    int32   `json:"pendingJobs"`
+}
+
+// Usage is what a user has used in the current day and month.
+type Usage struct {
+	BytesStored     int64   `json:"bytesStored"`
+	UploadsToday    int32   `json:"uploadsToday"`
+	TokensThisMonth int64   `json:"tokensThisMonth"`
+	CostThisMonth   float64 `json:"costThisMonth"`
+	PendingJobs     int32   `json:"pendingJobs"`
+}
+
+// ExceededError is returned when an upload would go over a limit.
+type ExceededError struct {
+	Limit string
+	Used  float64
+	Max   float64
+	// RetryAfter is zero for limits that waiting doesn't lift.
+	RetryAfter time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded: %s (%s of %s)", e.Limit, formatAmount(e.Used), formatAmount(e.Max))
+}
+
+// Status is 429 for limits that reset or drain over time and 403 for the
+// ones that need space freed or an override.
+func (e *ExceededError) Status() int {
+	if e.RetryAfter > 0 {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusForbidden
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Service enforces quotas. Counters live in quota_usage; reservations lock
+// the user's row so concurrent uploads can't both squeeze under a limit.
+type Service struct {
+	pool              *pgxpool.Pool
+	queries           *sqlc.Queries
+	defaults          Limits
+	workspaceMaxBytes int64
+}
+
+func NewService(pool *pgxpool.Pool, queries *sqlc.Queries, defaults Limits, workspaceMaxBytes int64) *Service {
+	return &Service{
+		pool:              pool,
+		queries:           queries,
+		defaults:          defaults,
+		workspaceMaxBytes: workspaceMaxBytes,
+	}
+}
+
+// Limits returns the user's limits: the defaults with any admin override
+// applied.
+func (s *Service) Limits(ctx context.Context, userID int32) (Limits, error) {
+	return s.limits(ctx, s.queries, userID)
+}
+
+func (s *Service) limits(ctx context.Context, queries *sqlc.Queries, userID int32) (Limits, error) {
+	limits := s.defaults
+	o, err := queries.GetQuotaOverride(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return limits, nil
+	}
+	if err != nil {
+		return limits, fmt.Errorf("failed to load quota override: %w", err)
+	}
+
+	if o.MaxBytes.Valid {
+		limits.MaxBytes = o.MaxBytes.Int64
+	}
+	if o.UploadsPerDay.Valid {
+		limits.UploadsPerDay = o.UploadsPerDay.Int32
+	}
+	if o.TokensPerMonth.Valid {
+		limits.TokensPerMonth = o.TokensPerMonth.Int64
+	}
+	if o.CostPerMonth.Valid {
+		limits.CostPerMonth = o.CostPerMonth.Float64
+	}
+	if o.PendingJobs.Valid {
+		limits.PendingJobs = o.PendingJobs.Int32
+	}
+	return limits, nil
+}
+
+// Usage returns the user's counters for the current day and month.
+func (s *Service) Usage(ctx context.Context, userID int32) (Usage, error) {
+	row, err := s.queries.GetQuotaUsage(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Usage{}, nil
+	}
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to load quota usage: %w", err)
+	}
+	return current(row, time.Now()), nil
+}
+
+// Reserve counts an upload of bytes against the user's quota, and a pending
+// job unless the summary came from the cache. bytes may be negative when a
+// document is replaced by smaller content. Nothing is counted when a limit
+// would be exceeded; the error is then an *ExceededError.
+func (s *Service) Reserve(ctx context.Context, userID int32, bytes int64, job bool) error {
+	return s.reserve(ctx, userID, bytes, true, job)
+}
+
+// ReserveJob counts a pending job that doesn't come with an upload, such as
+// summarizing a stored document again.
+func (s *Service) ReserveJob(ctx context.Context, userID int32) error {
+	return s.reserve(ctx, userID, 0, false, true)
+}
+
+func (s *Service) reserve(ctx context.Context, userID int32, bytes int64, upload bool, job bool) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin quota transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := s.queries.WithTx(tx)
+
+	if err := queries.EnsureQuotaUsage(ctx, userID); err != nil {
+		return fmt.Errorf("failed to create quota usage: %w", err)
+	}
+	row, err := queries.LockQuotaUsage(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to lock quota usage: %w", err)
+	}
+	limits, err := s.limits(ctx, queries, userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	usage := current(row, now)
+	if err := check(limits, usage, bytes, upload, job, now); err != nil {
+		return err
+	}
+
+	usage.BytesStored = max(usage.BytesStored+bytes, 0)
+	if upload {
+		usage.UploadsToday++
+	}
+	if job {
+		usage.PendingJobs++
+	}
+	err = queries.SaveQuotaUsage(ctx, sqlc.SaveQuotaUsageParams{
+		UserID:      userID,
+		BytesStored: usage.BytesStored,
+		PendingJobs: usage.PendingJobs,
+		UploadsDay:  date(day(now)),
+		Uploads:     usage.UploadsToday,
+		SpendMonth:  date(month(now)),
+		Tokens:      usage.TokensThisMonth,
+		Cost:        usage.CostThisMonth,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update quota usage: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// Release gives back storage and, with job, a pending job slot: for a
+// reservation whose upload failed, a deleted document or a job that will
+// never report back.
+func (s *Service) Release(ctx context.Context, userID int32, bytes int64, job bool) error {
+	var jobs int32
+	if job {
+		jobs = 1
+	}
+	return s.queries.AdjustQuotaUsage(ctx, sqlc.AdjustQuotaUsageParams{
+		UserID: userID,
+		Bytes:  -bytes,
+		Jobs:   -jobs,
+	})
+}
+
+// Finish frees the pending job slot of a finished job and adds what it
+// spent to the current month.
+func (s *Service) Finish(ctx context.Context, userID int32, tokens int64, cost float64) error {
+	return s.spend(ctx, s.queries, userID, 1, tokens, cost)
+}
+
+// FinishTx is Finish in tx, e.g. the one recording the job's result.
+func (s *Service) FinishTx(ctx context.Context, tx pgx.Tx, userID int32, tokens int64, cost float64) error {
+	return s.spend(ctx, s.queries.WithTx(tx), userID, 1, tokens, cost)
+}
+
+// CheckSpend reports whether the user may still spend tokens this month,
+// for completions that run outside a job, such as answering questions.
+func (s *Service) CheckSpend(ctx context.Context, userID int32) error {
+	limits, err := s.Limits(ctx, userID)
+	if err != nil {
+		return err
+	}
+	usage, err := s.Usage(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return checkSpend(limits, usage, time.Now())
+}
+
+// Spend adds what a completion outside a job spent to the current month.
+func (s *Service) Spend(ctx context.Context, userID int32, tokens int64, cost float64) error {
+	if err := s.queries.EnsureQuotaUsage(ctx, userID); err != nil {
+		return fmt.Errorf("failed to create quota usage: %w", err)
+	}
+	return s.spend(ctx, s.queries, userID, 0, tokens, cost)
+}
+
+func (s *Service) spend(ctx context.Context, queries *sqlc.Queries, userID int32, jobs int32, tokens int64, cost float64) error {
+	return queries.RecordQuotaSpend(ctx, sqlc.RecordQuotaSpendParams{
+		UserID: userID,
+		Jobs:   jobs,
+		Month:  date(month(time.Now())),
+		Tokens: tokens,
+		Cost:   cost,
+	})
+}
+
+// WorkspaceLimit returns the storage limit of a workspace and what the
+// documents shared with it take up.
+func (s *Service) WorkspaceLimit(ctx context.Context, workspaceID int32) (int64, int64, error) {
+	limit := s.workspaceMaxBytes
+	o, err := s.queries.GetWorkspaceQuotaOverride(ctx, workspaceID)
+	if err == nil {
+		limit = o.MaxBytes
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, 0, fmt.Errorf("failed to load workspace quota override: %w", err)
+	}
+
+	used, err := s.queries.GetWorkspaceStoredBytes(ctx, pgtype.Int4{Int32: workspaceID, Valid: true})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load workspace storage: %w", err)
+	}
+	return limit, used, nil
+}
+
+// CheckWorkspace reports whether bytes more fit into the workspace.
+func (s *Service) CheckWorkspace(ctx context.Context, workspaceID int32, bytes int64) error {
+	limit, used, err := s.WorkspaceLimit(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	if limit > 0 && used+bytes > limit {
+		return &ExceededError{Limit: LimitBytes, Used: float64(used), Max: float64(limit)}
+	}
+	return nil
+}
+
+func check(limits Limits, usage Usage, bytes int64, upload bool, job bool, now time.Time) error {
+	if limits.MaxBytes > 0 && bytes > 0 && usage.BytesStored+bytes > limits.MaxBytes {
+		return &ExceededError{Limit: LimitBytes, Used: float64(usage.BytesStored), Max: float64(limits.MaxBytes)}
+	}
+	if upload && limits.UploadsPerDay > 0 && usage.UploadsToday >= limits.UploadsPerDay {
+		return &ExceededError{
+			Limit:      LimitUploadsPerDay,
+			Used:       float64(usage.UploadsToday),
+			Max:        float64(limits.UploadsPerDay),
+			RetryAfter: day(now).AddDate(0, 0, 1).Sub(now),
+		}
+	}
+	if !job {
+		// cache hits cost nothing beyond storage
+		return nil
+	}
+
+	if err := checkSpend(limits, usage, now); err != nil {
+		return err
+	}
+	if limits.PendingJobs > 0 && usage.PendingJobs >= limits.PendingJobs {
+		return &ExceededError{
+			Limit:      LimitPendingJobs,
+			Used:       float64(usage.PendingJobs),
+			Max:        float64(limits.PendingJobs),
+			RetryAfter: 30 * time.Second,
+		}
+	}
+	return nil
+}
+
+// checkSpend checks the monthly token and cost limits.
+func checkSpend(limits Limits, usage Usage, now time.Time) error {
+	nextMonth := month(now).AddDate(0, 1, 0).Sub(now)
+	if limits.TokensPerMonth > 0 && usage.TokensThisMonth >= limits.TokensPerMonth {
+		return &ExceededError{
+			Limit:      LimitTokensPerMonth,
+			Used:       float64(usage.TokensThisMonth),
+			Max:        float64(limits.TokensPerMonth),
+			RetryAfter: nextMonth,
+		}
+	}
+	if limits.CostPerMonth > 0 && usage.CostThisMonth >= limits.CostPerMonth {
+		return &ExceededError{
+			Limit:      LimitCostPerMonth,
+			Used:       usage.CostThisMonth,
+			Max:        limits.CostPerMonth,
+			RetryAfter: nextMonth,
+		}
+	}
+	return nil
+}
+
+// current drops counters whose day or month has passed.
+func current(row sqlc.QuotaUsage, now time.Time) Usage {
+	usage := Usage{
+		BytesStored: row.BytesStored,
+		PendingJobs: row.PendingJobs,
+	}
+	if row.UploadsDay.Valid && row.UploadsDay.Time.Equal(day(now)) {
+		usage.UploadsToday = row.Uploads
+	}
+	if row.SpendMonth.Valid && row.SpendMonth.Time.Equal(month(now)) {
+		usage.TokensThisMonth = row.Tokens
+		usage.CostThisMonth = row.Cost
+	}
+	return usage
+}
+
+// Days and months are UTC.
+func day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func month(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func date(t time.Time) pgtype.Date {
+	return pgtype.Date{Time: t, Valid: true}
+}
@@ -1,51 +1,172 @@
 package router
 
 import (
-	"os"
+	"net/http"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
+	"backend-go/internal/ask"
+	"backend-go/internal/deadletter"
+	"backend-go/internal/dispatch"
+	"backend-go/internal/embed"
 	"backend-go/internal/events"
+	"backend-go/internal/gitimport"
 	handlers "backend-go/internal/handlers"
+	"backend-go/internal/health"
+	"backend-go/internal/idempotency"
+	"backend-go/internal/ingest"
+	metrics "backend-go/internal/metrics"
 	middleware "backend-go/internal/middleware"
+	"backend-go/internal/quota"
+	"backend-go/internal/tracing"
+	"backend-go/internal/worker"
 
 	sqlc "backend-go/internal/db/sqlc"
 )
 
-func SetupRouter(queries *sqlc.Queries, s3Client *s3.Client, sqsClient *sqs.Client, bucketName string, queueName string, broadcaster *events.Broadcaster) *gin.Engine {
-	r := gin.Default()
+func SetupRouter(queries *sqlc.Queries, s3Client *s3.Client, bucketName string, ingester *ingest.Service, importer *gitimport.Importer, indexer *embed.Indexer, asker *ask.Service, broadcaster *events.Broadcaster, quotas *quota.Service, dispatcher *dispatch.Dispatcher, deadLetters *deadletter.Service, idempotencyStore *idempotency.Store, responses *worker.ResponseWorker, liveness *health.Checker, readiness *health.Checker, allowedOrigins []string) *gin.Engine {
+	r := gin.New()
+	// handlers pass the gin context on as their context; with the fallback
+	// it carries the request's span, and is done when the client leaves
+	r.ContextWithFallback = true
+	r.Use(metrics.HTTPMiddleware())
+	r.Use(otelgin.Middleware(tracing.ScopeName, otelgin.WithFilter(func(req *http.Request) bool {
+		// probes and scrapes would drown out the requests worth tracing
+		switch req.URL.Path {
+		case "/health", "/livez", "/readyz", "/metrics":
+			return false
+		}
+		return true
+	})))
+	// after the tracing middleware, so the ID goes on the request's span
+	r.Use(middleware.RequestIDMiddleware(), middleware.LoggerMiddleware(), middleware.RecoveryMiddleware())
 
-	allowedOrigins := os.Getenv("ALLOWED_ORIGINS")
-	if allowedOrigins == "" {
-		allowedOrigins = "http://localhost:3000"
-	}
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{allowedOrigins},
-		AllowMethods:     []string{"GET", "POST", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowOrigins:     allowedOrigins,
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "If-None-Match", idempotency.Header, middleware.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", "ETag", idempotency.ReplayedHeader, middleware.RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	r.GET("/health", handlers.HealthHandler)
 
+	r.GET("/livez", handlers.HealthCheckHandler(liveness))
+
+	r.GET("/readyz", handlers.HealthCheckHandler(readiness))
+
+	r.GET("/metrics", metrics.Handler())
+
 	r.POST("/register", handlers.RegisterHandler(queries))
 
 	r.POST("/login", handlers.LoginHandler(queries))
 
-	r.GET("/events", handlers.EventHandler(broadcaster))
+	// streamed responses can't be replayed, so these take no idempotency key
+	stream := r.Group("/")
+	stream.Use(middleware.SessionMiddleware(queries))
+	{
+		stream.GET("/events", handlers.EventHandler(broadcaster))
+
+		stream.POST("/files/:id/ask", handlers.AskHandler(queries, quotas, asker))
+	}
 
 	auth := r.Group("/")
-	auth.Use(middleware.SessionMiddleware(queries))
+	auth.Use(middleware.SessionMiddleware(queries), middleware.IdempotencyMiddleware(idempotencyStore))
+	{
+		auth.POST("/upload", handlers.UploadHandler(ingester, broadcaster))
+
+		auth.POST("/upload/archive", handlers.ArchiveUploadHandler(queries, ingester, broadcaster))
+
+		auth.GET("/files", handlers.ListFilesHandler(queries))
+
+		auth.PUT("/files/:id/tags", handlers.SetFileTagsHandler(queries, ingester))
+
+		auth.PUT("/files/:id/workspace", handlers.ShareFileHandler(queries, quotas))
+
+		auth.GET("/files/:id/summary", handlers.FetchSummaryHandler(queries, s3Client, bucketName))
+
+		auth.GET("/search", handlers.SearchHandler(queries))
+
+		auth.GET("/search/semantic", handlers.SemanticSearchHandler(indexer))
+
+		auth.GET("/files/:id/related", handlers.RelatedFilesHandler(queries, indexer))
+
+		auth.GET("/files/:id/threads", handlers.ListAskThreadsHandler(queries))
+
+		auth.GET("/files/:id/threads/:threadId", handlers.GetAskThreadHandler(queries))
+
+		auth.GET("/workspaces", handlers.ListWorkspacesHandler(queries))
+
+		auth.POST("/workspaces", handlers.CreateWorkspaceHandler(queries))
+
+		auth.GET("/workspaces/:id/members", handlers.ListWorkspaceMembersHandler(queries))
+
+		auth.POST("/workspaces/:id/members", handlers.AddWorkspaceMemberHandler(queries))
+
+		auth.DELETE("/workspaces/:id/members/:userId", handlers.RemoveWorkspaceMemberHandler(queries))
+
+		auth.DELETE("/files/:id", handlers.DeleteFileHandler(ingester))
+
+		auth.POST("/files/:id/resummarize", handlers.ResummarizeHandler(ingester))
+
+		auth.POST("/jobs/:id/cancel", handlers.CancelJobHandler(ingester))
+
+		auth.GET("/prompt-templates", handlers.ListPromptTemplatesHandler(queries))
+
+		auth.PUT("/prompt-templates/custom", handlers.SaveCustomTemplateHandler(queries))
+
+		auth.GET("/preferences/summary", handlers.GetSummaryPreferencesHandler(queries))
+
+		auth.PUT("/preferences/summary", handlers.UpdateSummaryPreferencesHandler(queries, ingester))
+
+		auth.GET("/models", handlers.ListModelsHandler(queries))
+
+		auth.GET("/usage", handlers.UserUsageHandler(queries))
+
+		auth.GET("/workspaces/:id/usage", handlers.WorkspaceUsageHandler(queries))
+
+		auth.GET("/quota", handlers.GetQuotaHandler(quotas))
+
+		auth.GET("/workspaces/:id/quota", handlers.WorkspaceQuotaHandler(queries, quotas))
+
+		auth.GET("/repositories", handlers.ListRepositoriesHandler(queries))
+
+		auth.POST("/repositories", handlers.CreateRepositoryHandler(queries, importer))
+
+		auth.POST("/repositories/:id/sync", handlers.SyncRepositoryHandler(queries, importer))
+	}
+
+	admin := auth.Group("/admin")
+	admin.Use(middleware.AdminMiddleware(queries))
 	{
-		auth.POST("/upload", handlers.UploadHandler(s3Client, sqsClient, bucketName, queueName))
+		admin.GET("/users/:id/quota", handlers.AdminGetUserQuotaHandler(queries, quotas))
+
+		admin.PUT("/users/:id/quota", handlers.AdminSetUserQuotaHandler(queries))
+
+		admin.DELETE("/users/:id/quota", handlers.AdminDeleteUserQuotaHandler(queries))
+
+		admin.PUT("/workspaces/:id/quota", handlers.AdminSetWorkspaceQuotaHandler(queries))
+
+		admin.DELETE("/workspaces/:id/quota", handlers.AdminDeleteWorkspaceQuotaHandler(queries))
+
+		admin.GET("/queue", handlers.AdminQueueHandler(queries, dispatcher, responses))
+
+		admin.PUT("/jobs/:id/priority", handlers.AdminSetJobPriorityHandler(queries, dispatcher))
+
+		admin.POST("/reprocess", handlers.AdminReprocessHandler(queries, ingester))
+
+		admin.GET("/quarantine", handlers.AdminListQuarantineHandler(queries))
+
+		admin.GET("/quarantine/:id", handlers.AdminGetQuarantinedMessageHandler(queries))
+
+		admin.POST("/quarantine/:id/redrive", handlers.AdminRedriveHandler(deadLetters))
 
-		auth.POST("/files", handlers.ListFilesHandler(s3Client, bucketName))
+		admin.POST("/quarantine/:id/discard", handlers.AdminDiscardHandler(deadLetters))
 	}
 
 	return r
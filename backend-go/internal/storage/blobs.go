@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	sqlc "backend-go/internal/db/sqlc"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Blob is a content-addressed object in the bucket, identified by the
+// SHA-256 of its bytes.
+type Blob struct {
+	Hash       string
+	Size       int64
+	StorageKey string
+}
+
+// BlobStore deduplicates uploads by content. Every successful Put acquires
+// one reference on the blob; callers hand that reference back with Release
+// once the owning document is gone. The object is deleted from the bucket
+// when the last reference is released.
+//
+// Put and Release of the same hash are serialized by a lock on the hash, so
+// a release never deletes an object that a concurrent upload just stored.
+type BlobStore struct {
+	pool       *pgxpool.Pool
+	queries    *sqlc.Queries
+	s3Client   *s3.Client
+	bucketName string
+}
+
+func NewBlobStore(pool *pgxpool.Pool, queries *sqlc.Queries, s3Client *s3.Client, bucketName string) *BlobStore {
+	return &BlobStore{
+		pool:       pool,
+		queries:    queries,
+		s3Client:   s3Client,
+		bucketName: bucketName,
+	}
+}
+
+func BlobKey(hash string) string {
+	return "blobs/sha256/" + hash
+}
+
+// Put streams r into a spool file while hashing it, then uploads the bytes
+// only if no blob with the same hash is stored yet. The hash stays locked
+// until the blob is recorded.
+func (s *BlobStore) Put(ctx context.Context, r io.Reader) (Blob, error) {
+	spool, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return Blob{}, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hasher), r)
+	if err != nil {
+		return Blob{}, fmt.Errorf("failed to read upload: %w", err)
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return Blob{}, fmt.Errorf("failed to begin blob transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := s.queries.WithTx(tx)
+	if err := queries.LockBlob(ctx, hash); err != nil {
+		return Blob{}, fmt.Errorf("failed to lock blob: %w", err)
+	}
+
+	existing, err := queries.AcquireBlob(ctx, hash)
+	if err == nil {
+		if err := tx.Commit(ctx); err != nil {
+			return Blob{}, fmt.Errorf("failed to commit blob: %w", err)
+		}
+		return Blob{Hash: existing.Hash, Size: existing.Size, StorageKey: existing.StorageKey}, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Blob{}, fmt.Errorf("failed to look up blob: %w", err)
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return Blob{}, fmt.Errorf("failed to rewind spool file: %w", err)
+	}
+
+	key := BlobKey(hash)
+	_, err = s.s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        &s.bucketName,
+		Key:           &key,
+		Body:          spool,
+		ContentLength: &size,
+	})
+	if err != nil {
+		return Blob{}, fmt.Errorf("failed to store blob: %w", err)
+	}
+
+	blob, err := queries.InsertBlob(ctx, sqlc.InsertBlobParams{
+		Hash:       hash,
+		Size:       size,
+		StorageKey: key,
+	})
+	if err != nil {
+		return Blob{}, fmt.Errorf("failed to record blob: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Blob{}, fmt.Errorf("failed to commit blob: %w", err)
+	}
+
+	return Blob{Hash: blob.Hash, Size: blob.Size, StorageKey: blob.StorageKey}, nil
+}
+
+// Release drops one reference and deletes the object once nothing points
+// at it anymore. The hash stays locked until the object is deleted, so a
+// Put of the same bytes waits and then uploads them again.
+func (s *BlobStore) Release(ctx context.Context, hash string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin blob transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := s.queries.WithTx(tx)
+	if err := queries.LockBlob(ctx, hash); err != nil {
+		return fmt.Errorf("failed to lock blob: %w", err)
+	}
+
+	blob, err := queries.ReleaseBlob(ctx, hash)
+	if err != nil {
+		return fmt.Errorf("failed to release blob: %w", err)
+	}
+	if blob.RefCount > 0 {
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("failed to commit blob release: %w", err)
+		}
+		return nil
+	}
+
+	key, err := queries.DeleteUnreferencedBlob(ctx, hash)
+	if err != nil {
+		return fmt.Errorf("failed to delete blob record: %w", err)
+	}
+
+	_, err = s.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: &s.bucketName,
+		Key:    &key,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to delete blob object", "key", key, "error", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit blob release: %w", err)
+	}
+	return nil
+}
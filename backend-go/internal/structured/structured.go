@@ -0,0 +1,230 @@
+package structured
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"backend-go/internal/llm"
+)
+
+// Instruction is appended to the prompt of structured jobs. It describes the
+// schema Parse accepts.
+const Instruction = `Respond with a single JSON object and nothing else, using exactly these keys:
+{
+  "title": "a short title for the document",
+  "tldr": "one sentence",
+  "keyPoints": ["..."],
+  "actionItems": ["..."],
+  "openQuestions": ["..."],
+  "audience": "who the document is written for",
+  "keywords": ["..."]
+}
+Use empty lists where nothing applies. Keep every list entry to one sentence.`
+
+const (
+	maxTitle    = 200
+	maxTLDR     = 400
+	maxAudience = 200
+	maxEntry    = 500
+	maxKeyword  = 50
+	maxEntries  = 20
+)
+
+// Summary is the typed form of a structured summary. It is stored as
+// _overview.json next to the text rendering.
+type Summary struct {
+	Title         string   `json:"title"`
+	TLDR          string   `json:"tldr"`
+	KeyPoints     []string `json:"keyPoints"`
+	ActionItems   []string `json:"actionItems"`
+	OpenQuestions []string `json:"openQuestions"`
+	Audience      string   `json:"audience"`
+	Keywords      []string `json:"keywords"`
+}
+
+// ValidationError lists everything wrong with a model's output, so a repair
+// request can tell the model exactly what to fix.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid structured summary: " + strings.Join(e.Problems, "; ")
+}
+
+// Key is where the structured form of the summary stored at textKey lives.
+func Key(textKey string) string {
+	return strings.TrimSuffix(textKey, ".txt") + ".json"
+}
+
+// TextKey is the inverse of Key.
+func TextKey(key string) string {
+	return strings.TrimSuffix(key, ".json") + ".txt"
+}
+
+// IsKey reports whether key names a structured summary.
+func IsKey(key string) bool {
+	return strings.HasSuffix(key, ".json")
+}
+
+// Parse extracts a summary from model output. It tolerates code fences and
+// text around the object, a single string where a list is expected and the
+// other way round; anything it can't make sense of is a ValidationError.
+func Parse(raw string) (Summary, error) {
+	start := strings.Index(raw, "{")
+	end := strings.LastIndex(raw, "}")
+	if start < 0 || end < start {
+		return Summary{}, &ValidationError{Problems: []string{"output contains no JSON object"}}
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw[start:end+1]), &fields); err != nil {
+		return Summary{}, &ValidationError{Problems: []string{"output is not valid JSON: " + err.Error()}}
+	}
+
+	var problems []string
+	text := func(key string, limit int, required bool) string {
+		v, ok := asText(fields[key])
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%q must be a string", key))
+			return ""
+		}
+		if required && v == "" {
+			problems = append(problems, fmt.Sprintf("%q is required", key))
+		}
+		return truncate(v, limit)
+	}
+	list := func(key string, limit int, required bool) []string {
+		v, ok := asList(fields[key])
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%q must be a list of strings", key))
+			return []string{}
+		}
+		if required && len(v) == 0 {
+			problems = append(problems, fmt.Sprintf("%q needs at least one entry", key))
+		}
+		if len(v) > maxEntries {
+			v = v[:maxEntries]
+		}
+		for i := range v {
+			v[i] = truncate(v[i], limit)
+		}
+		return v
+	}
+
+	s := Summary{
+		Title:         text("title", maxTitle, true),
+		TLDR:          text("tldr", maxTLDR, true),
+		KeyPoints:     list("keyPoints", maxEntry, true),
+		ActionItems:   list("actionItems", maxEntry, false),
+		OpenQuestions: list("openQuestions", maxEntry, false),
+		Audience:      text("audience", maxAudience, false),
+		Keywords:      list("keywords", maxKeyword, false),
+	}
+	if len(problems) > 0 {
+		return Summary{}, &ValidationError{Problems: problems}
+	}
+	return s, nil
+}
+
+// Repair parses raw and, while it is invalid, asks the model to fix its own
+// output, at most attempts times.
+func Repair(ctx context.Context, client *llm.Client, model string, raw string, attempts int) (Summary, error) {
+	s, err := Parse(raw)
+	for i := 0; i < attempts && err != nil; i++ {
+		var invalid *ValidationError
+		if !errors.As(err, &invalid) {
+			return Summary{}, err
+		}
+
+		fixed, cerr := client.Complete(ctx, model, []llm.Message{
+			{Role: "system", Content: Instruction},
+			{Role: "user", Content: "The following output does not match the schema (" +
+				strings.Join(invalid.Problems, "; ") + "). Return only the corrected JSON object.\n\n" + raw},
+		})
+		if cerr != nil {
+			return Summary{}, fmt.Errorf("repair request failed: %w", cerr)
+		}
+		raw = fixed
+		s, err = Parse(raw)
+	}
+	return s, err
+}
+
+// Text renders the summary as markdown. It is what the text endpoints,
+// previews and search see.
+func (s Summary) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n%s\n", s.Title, s.TLDR)
+	section := func(heading string, entries []string) {
+		if len(entries) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n", heading)
+		for _, e := range entries {
+			fmt.Fprintf(&b, "- %s\n", e)
+		}
+	}
+	section("Key points", s.KeyPoints)
+	section("Action items", s.ActionItems)
+	section("Open questions", s.OpenQuestions)
+	if s.Audience != "" {
+		fmt.Fprintf(&b, "\n**Audience:** %s\n", s.Audience)
+	}
+	if len(s.Keywords) > 0 {
+		fmt.Fprintf(&b, "\n**Keywords:** %s\n", strings.Join(s.Keywords, ", "))
+	}
+	return b.String()
+}
+
+func asText(raw json.RawMessage) (string, bool) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", true
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return strings.Join(strings.Fields(s), " "), true
+	}
+	if list, ok := asList(raw); ok {
+		return strings.Join(list, ", "), true
+	}
+	return "", false
+}
+
+func asList(raw json.RawMessage) ([]string, bool) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return []string{}, true
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, false
+		}
+		items = []json.RawMessage{raw}
+	}
+
+	list := []string{}
+	for _, item := range items {
+		var s string
+		if err := json.Unmarshal(item, &s); err != nil {
+			return nil, false
+		}
+		s = strings.TrimLeft(strings.Join(strings.Fields(s), " "), "-* ")
+		if s != "" {
+			list = append(list, s)
+		}
+	}
+	return list, true
+}
+
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:limit-1])) + "…"
+}
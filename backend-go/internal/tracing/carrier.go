@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MessageAttributes carries trace context in the attributes of an SQS
+// message, as traceparent and tracestate, which is what the worker reads
+// and writes too.
+type MessageAttributes map[string]types.MessageAttributeValue
+
+func (m MessageAttributes) Get(key string) string {
+	v, ok := m[key]
+	if !ok || v.StringValue == nil {
+		return ""
+	}
+	return *v.StringValue
+}
+
+func (m MessageAttributes) Set(key string, value string) {
+	m[key] = types.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(value),
+	}
+}
+
+func (m MessageAttributes) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// InjectMessage adds the trace context of ctx to attrs, which may be nil,
+// and returns them.
+func InjectMessage(ctx context.Context, attrs map[string]types.MessageAttributeValue) map[string]types.MessageAttributeValue {
+	if attrs == nil {
+		attrs = make(map[string]types.MessageAttributeValue)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, MessageAttributes(attrs))
+	return attrs
+}
+
+// ExtractMessage returns ctx with the trace context carried by attrs, for
+// the span handling the message to continue the sender's trace.
+func ExtractMessage(ctx context.Context, attrs map[string]types.MessageAttributeValue) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, MessageAttributes(attrs))
+}
+
+// Encode returns the trace context of ctx as JSON to store with a row, or
+// nil when ctx isn't part of a trace.
+func Encode(ctx context.Context) []byte {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return nil
+	}
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	data, _ := json.Marshal(carrier)
+	return data
+}
+
+// Decode returns ctx with the trace context Encode stored. Data that isn't
+// a trace context leaves ctx as it is.
+func Decode(ctx context.Context, data []byte) context.Context {
+	if len(data) == 0 {
+		return ctx
+	}
+	carrier := propagation.MapCarrier{}
+	if err := json.Unmarshal(data, &carrier); err != nil {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
@@ -0,0 +1,91 @@
+// Package tracing sets up OpenTelemetry tracing and carries W3C trace
+// context across what isn't an HTTP request: SQS messages, and jobs waiting
+// in the database to be dispatched.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// ScopeName names the tracer of the backend's own spans.
+const ScopeName = "backend-go"
+
+type Config struct {
+	// Exporter is where spans go: none, otlp or stdout.
+	Exporter    string
+	ServiceName string
+}
+
+var DefaultConfig = Config{
+	Exporter:    ExporterNone,
+	ServiceName: "backend-go",
+}
+
+// Setup installs the W3C trace context propagator and, unless the exporter
+// is none, a tracer provider exporting to it. The returned function flushes
+// the spans not yet exported and stops the provider.
+//
+// The OTLP exporter reads its endpoint and headers from the standard
+// OTEL_EXPORTER_OTLP_* variables, and the sampler from OTEL_TRACES_SAMPLER.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	// propagated even without an exporter, so a trace started by a client
+	// or the worker isn't cut short here
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(cfg.ServiceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe trace resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span with the backend's tracer.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(ScopeName).Start(ctx, name, opts...)
+}
+
+// RecordError marks span failed with err.
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -1,90 +1,558 @@
 package worker
 
 import (
+	clients "backend-go/internal/clients"
+	sqlc "backend-go/internal/db/sqlc"
 	"backend-go/internal/events"
+	"backend-go/internal/ingest"
+	"backend-go/internal/logging"
+	"backend-go/internal/metrics"
+	"backend-go/internal/structured"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Types of intermediate messages the summarization worker sends while a job
+// runs. A message without a type is the job's result.
+const (
+	MessageStarted  = "started"
+	MessageProgress = "progress"
+	MessagePartial  = "partial"
 )
 
 type ResponseMessage struct {
-	Bucket string `json:"bucket"`
-	Key    string `json:"key"`
-	Status string `json:"status"`
-	UserID string `json:"userId"`
+	Type       string `json:"type,omitempty"`
+	Bucket     string `json:"bucket"`
+	Key        string `json:"key"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	UserID     string `json:"userId"`
+	DocumentID int32  `json:"documentId"`
+	JobID      int32  `json:"jobId"`
+	Chunk      int    `json:"chunk,omitempty"`
+	Chunks     int    `json:"chunks,omitempty"`
+	Seq        int    `json:"seq,omitempty"`
+	Text       string `json:"text,omitempty"`
+	// Usage of the job's completions, reported with the result.
+	PromptTokens     int32 `json:"promptTokens,omitempty"`
+	CompletionTokens int32 `json:"completionTokens,omitempty"`
+	LatencyMs        int32 `json:"latencyMs,omitempty"`
 }
 
-type SSEMessage struct {
-	UserID  string `json:"userId"`
-	Content string `json:"content"`
+// outcome of handling one message
+type outcome int
+
+const (
+	outcomeProcessed outcome = iota
+	// outcomeRetry leaves the message on the queue to be received again,
+	// until it is dead-lettered
+	outcomeRetry
+	outcomeQuarantined
+	// outcomeDropped is a message for a cancelled job, or a result for a
+	// job that already finished
+	outcomeDropped
+)
+
+func (o outcome) String() string {
+	switch o {
+	case outcomeProcessed:
+		return "processed"
+	case outcomeRetry:
+		return "retry"
+	case outcomeQuarantined:
+		return "quarantined"
+	case outcomeDropped:
+		return "dropped"
+	}
+	return "unknown"
 }
 
-func StartResponseWorker(sqsClient *sqs.Client, s3Client *s3.Client, queueName string, bucketName string, broadcaster *events.Broadcaster) {
-	go func() {
-		getOut, _ := sqsClient.GetQueueUrl(context.TODO(), &sqs.GetQueueUrlInput{
-			QueueName: &queueName,
+func (w *ResponseWorker) handle(ctx context.Context, m sqstypes.Message) outcome {
+	var msg ResponseMessage
+	if err := json.Unmarshal([]byte(aws.ToString(m.Body)), &msg); err != nil {
+		slog.WarnContext(ctx, "failed to parse message", "message_id", aws.ToString(m.MessageId), "error", err)
+		return w.quarantine(ctx, m, fmt.Sprintf("invalid message: %v", err))
+	}
+
+	ctx = logging.WithJob(ctx, msg.JobID, msg.DocumentID)
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("job.id", int(msg.JobID)),
+		attribute.String("response.type", msg.Type),
+		attribute.String("response.status", msg.Status),
+	)
+
+	if msg.JobID != 0 {
+		unlock := w.jobs.lock(msg.JobID)
+		defer unlock()
+	}
+
+	if msg.JobID != 0 && w.jobCancelled(ctx, msg.JobID) {
+		// only the result matters, to account for what the job used
+		if msg.Type == "" {
+			w.finishCancelled(ctx, msg)
+			w.dispatcher.Notify()
+		}
+		w.deleteMessage(ctx, m)
+		return outcomeDropped
+	}
+
+	switch msg.Type {
+	case MessageStarted:
+		if err := w.queries.StartJob(ctx, msg.JobID); err != nil {
+			slog.ErrorContext(ctx, "failed to mark job running", "error", err)
+		}
+		w.broadcaster.Publish(events.JobStarted, events.JobMessage{
+			Type:       events.JobStarted,
+			UserID:     msg.UserID,
+			DocumentID: msg.DocumentID,
+			JobID:      msg.JobID,
 		})
+	case MessageProgress:
+		w.broadcaster.Publish(events.JobProgress, events.ProgressMessage{
+			Type:       events.JobProgress,
+			UserID:     msg.UserID,
+			DocumentID: msg.DocumentID,
+			JobID:      msg.JobID,
+			Chunk:      msg.Chunk,
+			Chunks:     msg.Chunks,
+		})
+	case MessagePartial:
+		w.broadcaster.Publish(events.JobPartial, events.PartialMessage{
+			Type:       events.JobPartial,
+			UserID:     msg.UserID,
+			DocumentID: msg.DocumentID,
+			JobID:      msg.JobID,
+			Seq:        msg.Seq,
+			Text:       msg.Text,
+		})
+	default:
+		if res := w.handleResult(ctx, m, msg); res != outcomeProcessed {
+			return res
+		}
+	}
 
-		queueUrl := getOut.QueueUrl
+	w.deleteMessage(ctx, m)
+	return outcomeProcessed
+}
 
-		for {
-			resp, err := sqsClient.ReceiveMessage(context.TODO(), &sqs.ReceiveMessageInput{
-				QueueUrl:            queueUrl,
-				MaxNumberOfMessages: 5,
-				WaitTimeSeconds:     10,
-			})
+// handleResult records a job's result and tells the browser.
+func (w *ResponseWorker) handleResult(ctx context.Context, m sqstypes.Message, msg ResponseMessage) outcome {
+	if msg.JobID != 0 && w.jobFinished(ctx, msg.JobID) {
+		// a result delivered again; spare the summary another repair
+		return w.dropResult(ctx, m)
+	}
+
+	var summary *structured.Summary
+	content := ""
+	if msg.Status == ingest.StatusCompleted {
+		var err error
+		content, err = w.fetchSummary(ctx, msg.Bucket, msg.Key)
+		var missing *s3types.NoSuchKey
+		if errors.As(err, &missing) {
+			// it won't turn up by trying again
+			slog.WarnContext(ctx, "summary is missing", "key", msg.Key)
+			return w.quarantine(ctx, m, fmt.Sprintf("summary %q not found", msg.Key))
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to fetch summary", "key", msg.Key, "error", err)
+			return outcomeRetry
+		}
+
+		if structured.IsKey(msg.Key) {
+			summary, err = w.finishStructured(ctx, msg, content)
 			if err != nil {
-				log.Printf("error receiving messages: %v", err)
-				time.Sleep(5 * time.Second)
-				continue
+				slog.WarnContext(ctx, "structured summary is invalid", "error", err)
+				msg.Status = ingest.StatusFailed
+				msg.Error = err.Error()
+			} else {
+				msg.Key = structured.TextKey(msg.Key)
+				content = summary.Text()
 			}
+		}
+	}
 
-			if len(resp.Messages) == 0 {
-				continue
+	if msg.Status != ingest.StatusCompleted {
+		if msg.JobID != 0 {
+			if res := w.recordResult(ctx, m, msg, ""); res != outcomeProcessed {
+				return res
 			}
+		}
+		w.broadcaster.Publish(events.JobFailed, events.JobMessage{
+			Type:       events.JobFailed,
+			UserID:     msg.UserID,
+			DocumentID: msg.DocumentID,
+			JobID:      msg.JobID,
+			Error:      failureReason(msg),
+		})
+		return outcomeProcessed
+	}
+
+	if msg.JobID != 0 {
+		if res := w.recordResult(ctx, m, msg, content); res != outcomeProcessed {
+			return res
+		}
+	}
+
+	w.broadcaster.Publish(events.JobCompleted, events.SSEMessage{
+		Type:       events.JobCompleted,
+		UserID:     msg.UserID,
+		DocumentID: msg.DocumentID,
+		JobID:      msg.JobID,
+		Content:    content,
+		Structured: summary,
+	})
+	return outcomeProcessed
+}
+
+// recordResult records the result of a job and frees its slot in the task
+// queue. A result for a job that already finished is dropped, so nothing is
+// accounted or announced twice.
+func (w *ResponseWorker) recordResult(ctx context.Context, m sqstypes.Message, msg ResponseMessage, content string) outcome {
+	recorded, err := w.recordSummary(ctx, msg, content)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to record result", "error", err)
+		return outcomeRetry
+	}
+	if !recorded {
+		return w.dropResult(ctx, m)
+	}
+	// a slot in the task queue is free again
+	w.dispatcher.Notify()
+	return outcomeProcessed
+}
+
+func (w *ResponseWorker) dropResult(ctx context.Context, m sqstypes.Message) outcome {
+	slog.InfoContext(ctx, "job already finished, result dropped")
+	w.deleteMessage(ctx, m)
+	return outcomeDropped
+}
+
+func (w *ResponseWorker) fetchSummary(ctx context.Context, bucket string, key string) (string, error) {
+	resp, err := w.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read summary %s: %w", key, err)
+	}
+	return string(body), nil
+}
+
+func (w *ResponseWorker) deleteMessage(ctx context.Context, m sqstypes.Message) {
+	_, err := w.sqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      w.queueUrl,
+		ReceiptHandle: m.ReceiptHandle,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to delete message", "message_id", aws.ToString(m.MessageId), "error", err)
+	}
+}
+
+// quarantine takes a message that can never be processed off the queue. If
+// it can't be recorded it is left to be dead-lettered after its receives
+// run out.
+func (w *ResponseWorker) quarantine(ctx context.Context, m sqstypes.Message, reason string) outcome {
+	if err := w.deadLetters.Quarantine(ctx, w.queueName, m, reason); err != nil {
+		slog.ErrorContext(ctx, "failed to quarantine message", "message_id", aws.ToString(m.MessageId), "error", err)
+		return outcomeRetry
+	}
+	w.deleteMessage(ctx, m)
+	return outcomeQuarantined
+}
+
+// jobCancelled reports whether the job was cancelled after the worker got
+// it. Anything the worker still sends for it is dropped.
+func (w *ResponseWorker) jobCancelled(ctx context.Context, jobID int32) bool {
+	status, err := w.queries.GetJobStatus(ctx, jobID)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			slog.ErrorContext(ctx, "failed to load job status", "error", err)
+		}
+		return false
+	}
+	return status == ingest.StatusCancelled
+}
+
+// jobFinished reports whether the job has completed or failed already. When
+// in doubt it reports false; recording the result checks again.
+func (w *ResponseWorker) jobFinished(ctx context.Context, jobID int32) bool {
+	status, err := w.queries.GetJobStatus(ctx, jobID)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			slog.ErrorContext(ctx, "failed to load job status", "error", err)
+		}
+		return false
+	}
+	return status == ingest.StatusCompleted || status == ingest.StatusFailed
+}
 
-			for _, m := range resp.Messages {
-				var msg ResponseMessage
-				if err := json.Unmarshal([]byte(*m.Body), &msg); err != nil {
-					log.Printf("failed to parse message: %v", err)
-					continue
-				}
-
-				s3Resp, err := s3Client.GetObject(context.TODO(), &s3.GetObjectInput{
-					Bucket: aws.String(msg.Bucket),
-					Key:    aws.String(msg.Key),
-				})
-				if err != nil {
-					log.Printf("failed to fetch from s3: %v", err)
-					continue
-				}
-
-				bodyBytes, _ := io.ReadAll(s3Resp.Body)
-				content := string(bodyBytes)
-
-				sseMsg := SSEMessage{
-					UserID:  msg.UserID,
-					Content: content,
-				}
-
-				jsonData, _ := json.Marshal(sseMsg)
-
-				broadcaster.Publish(string(jsonData))
-
-				_, err = sqsClient.DeleteMessage(context.TODO(), &sqs.DeleteMessageInput{
-					QueueUrl:      queueUrl,
-					ReceiptHandle: m.ReceiptHandle,
-				})
-				if err != nil {
-					log.Printf("failed to delete message: %v", err)
-				}
+// finishCancelled records what a cancelled job used before the worker
+// stopped it, and removes the marker that told the worker to stop along with
+// any summary it wrote anyway.
+func (w *ResponseWorker) finishCancelled(ctx context.Context, msg ResponseMessage) {
+	job, err := w.queries.GetJob(ctx, msg.JobID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load job", "error", err)
+		return
+	}
+	err = w.inTx(ctx, func(tx pgx.Tx) error {
+		return w.recordUsage(ctx, tx, job, msg)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to record usage of cancelled job", "error", err)
+	} else {
+		metrics.RecordTokens(job.Model, msg.PromptTokens, msg.CompletionTokens)
+	}
+
+	keys := []string{ingest.CancelKey(job.ID)}
+	if msg.Key != "" {
+		keys = append(keys, msg.Key)
+	}
+	for _, key := range keys {
+		_, err := w.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(msg.Bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to delete output of cancelled job", "key", key, "error", err)
+		}
+	}
+}
+
+// recordSummary marks the job and its document as done, counts it in its
+// batch and charges what it used, all in one transaction: an error leaves
+// everything as it was, so the message can be delivered again. Indexing the
+// summary and caching it under the job's content hash, so identical uploads
+// can reuse it, only follow when that commits; they are best effort.
+//
+// Only the first result of a job counts: for a job that already finished,
+// e.g. when a response is delivered twice, it changes nothing and reports
+// false.
+func (w *ResponseWorker) recordSummary(ctx context.Context, msg ResponseMessage, content string) (bool, error) {
+	job, err := w.queries.GetJob(ctx, msg.JobID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.WarnContext(ctx, "job of result not found")
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load job: %w", err)
+	}
+
+	status := ingest.StatusCompleted
+	if msg.Status != ingest.StatusCompleted {
+		status = ingest.StatusFailed
+	}
+	preview := pgtype.Text{String: ingest.Preview(content), Valid: true}
+
+	recorded := false
+	var batch sqlc.Batch
+	batchDone := false
+	err = w.inTx(ctx, func(tx pgx.Tx) error {
+		queries := w.queries.WithTx(tx)
+
+		var n int64
+		var err error
+		if status == ingest.StatusFailed {
+			n, err = queries.FailJob(ctx, sqlc.FailJobParams{
+				ID:    job.ID,
+				Error: pgtype.Text{String: failureReason(msg), Valid: true},
+			})
+		} else {
+			n, err = queries.CompleteJob(ctx, job.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to mark job %s: %w", status, err)
+		}
+		if n == 0 {
+			return nil
+		}
+
+		// a document with a newer job, e.g. for new content, is left to it
+		if status == ingest.StatusFailed {
+			_, err = queries.SetDocumentStatus(ctx, sqlc.SetDocumentStatusParams{
+				ID:     job.DocumentID,
+				Status: ingest.StatusFailed,
+				JobID:  job.ID,
+			})
+		} else {
+			_, err = queries.CompleteDocument(ctx, sqlc.CompleteDocumentParams{
+				ID:             job.DocumentID,
+				SummaryKey:     pgtype.Text{String: msg.Key, Valid: true},
+				SummaryPreview: preview,
+				JobID:          job.ID,
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update document: %w", err)
+		}
+
+		if job.BatchID.Valid {
+			if status == ingest.StatusFailed {
+				err = queries.IncrementBatchFailed(ctx, job.BatchID.Int32)
+			} else {
+				err = queries.IncrementBatchCompleted(ctx, job.BatchID.Int32)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to update batch %d: %w", job.BatchID.Int32, err)
+			}
+			batch, batchDone, err = ingest.FinishBatch(ctx, queries, job.BatchID.Int32)
+			if err != nil {
+				return fmt.Errorf("failed to finish batch %d: %w", job.BatchID.Int32, err)
 			}
 		}
-	}()
+
+		if err := w.recordUsage(ctx, tx, job, msg); err != nil {
+			return err
+		}
+		recorded = true
+		return nil
+	})
+	if err != nil || !recorded {
+		return false, err
+	}
+
+	w.observeDuration(ctx, job.ID, status)
+	metrics.RecordTokens(job.Model, msg.PromptTokens, msg.CompletionTokens)
+	if batchDone {
+		ingest.PublishBatchCompleted(w.broadcaster, batch)
+	}
+	if status == ingest.StatusFailed {
+		return true, nil
+	}
+
+	if err := w.queries.IndexDocumentSummary(ctx, sqlc.IndexDocumentSummaryParams{
+		Summary:    ingest.SearchText(content),
+		DocumentID: job.DocumentID,
+	}); err != nil {
+		slog.ErrorContext(ctx, "failed to index summary", "error", err)
+	}
+	if err := w.queries.PutCachedSummary(ctx, sqlc.PutCachedSummaryParams{
+		ContentHash:     job.ContentHash,
+		Model:           job.Model,
+		PromptVersion:   job.PromptVersion,
+		SummaryKey:      msg.Key,
+		Preview:         preview,
+		TemplateID:      job.TemplateID,
+		TemplateVersion: job.TemplateVersion,
+	}); err != nil {
+		slog.ErrorContext(ctx, "failed to cache summary", "error", err)
+	}
+	return true, nil
+}
+
+// inTx runs fn in a transaction, committing if it returns nil.
+func (w *ResponseWorker) inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := w.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// observeDuration records how long a finished job took from upload to
+// result. The database computes it, as it wrote both timestamps.
+func (w *ResponseWorker) observeDuration(ctx context.Context, jobID int32, status string) {
+	seconds, err := w.queries.GetJobDuration(ctx, jobID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load job duration", "error", err)
+		return
+	}
+	metrics.JobDuration.WithLabelValues(status).Observe(seconds)
+}
+
+// recordUsage stores the tokens and time a job took and what it cost at the
+// model's current prices, and charges it to the user's monthly quota, in tx.
+func (w *ResponseWorker) recordUsage(ctx context.Context, tx pgx.Tx, job sqlc.Job, msg ResponseMessage) error {
+	queries := w.queries.WithTx(tx)
+
+	var cost float64
+	model, err := queries.GetLlmModel(ctx, job.Model)
+	if err == nil {
+		cost = ingest.Cost(model, msg.PromptTokens, msg.CompletionTokens)
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to load model %s: %w", job.Model, err)
+	}
+
+	if err := queries.RecordJobUsage(ctx, sqlc.RecordJobUsageParams{
+		ID:               job.ID,
+		PromptTokens:     msg.PromptTokens,
+		CompletionTokens: msg.CompletionTokens,
+		LatencyMs:        msg.LatencyMs,
+		Cost:             cost,
+	}); err != nil {
+		return fmt.Errorf("failed to record job usage: %w", err)
+	}
+
+	tokens := int64(msg.PromptTokens) + int64(msg.CompletionTokens)
+	if err := w.quotas.FinishTx(ctx, tx, job.UserID, tokens, cost); err != nil {
+		return fmt.Errorf("failed to charge quota: %w", err)
+	}
+	return nil
+}
+
+// repairAttempts bounds how often the model is asked to fix a structured
+// summary that doesn't match the schema.
+const repairAttempts = 2
+
+// finishStructured validates the JSON a structured job produced, repairing it
+// if needed, and stores the canonical JSON together with its text rendering.
+func (w *ResponseWorker) finishStructured(ctx context.Context, msg ResponseMessage, raw string) (*structured.Summary, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	model := ingest.DefaultModel
+	if job, err := w.queries.GetJob(ctx, msg.JobID); err == nil {
+		model = job.Model
+	}
+
+	summary, err := structured.Repair(ctx, w.llmClient, model, raw, repairAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	body, _ := json.Marshal(summary)
+	if err := clients.WriteObject(ctx, w.s3Client, msg.Bucket, msg.Key, body, "application/json"); err != nil {
+		return nil, fmt.Errorf("failed to store structured summary: %w", err)
+	}
+	textKey := structured.TextKey(msg.Key)
+	if err := clients.WriteObject(ctx, w.s3Client, msg.Bucket, textKey, []byte(summary.Text()), "text/plain; charset=utf-8"); err != nil {
+		return nil, fmt.Errorf("failed to store summary text: %w", err)
+	}
+	return &summary, nil
+}
+
+func failureReason(msg ResponseMessage) string {
+	if msg.Error != "" {
+		return msg.Error
+	}
+	return msg.Status
 }
@@ -0,0 +1,406 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sqlc "backend-go/internal/db/sqlc"
+	"backend-go/internal/deadletter"
+	"backend-go/internal/dispatch"
+	"backend-go/internal/events"
+	"backend-go/internal/llm"
+	"backend-go/internal/quota"
+	"backend-go/internal/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// receiveRetryDelay is the pause after a failed receive.
+const receiveRetryDelay = 5 * time.Second
+
+type Config struct {
+	// Concurrency is how many messages are handled at once.
+	Concurrency int
+	// VisibilityTimeout is how long a received message stays hidden from
+	// other consumers. It is extended every HeartbeatInterval while the
+	// message is handled, so it only bounds how soon a message comes back
+	// after the backend stopped.
+	VisibilityTimeout time.Duration
+	HeartbeatInterval time.Duration
+	// WaitTime is how long one receive waits for messages.
+	WaitTime time.Duration
+	// DrainTimeout is how long Stop waits for messages being handled.
+	DrainTimeout time.Duration
+}
+
+var DefaultConfig = Config{
+	Concurrency:       8,
+	VisibilityTimeout: time.Minute,
+	HeartbeatInterval: 20 * time.Second,
+	WaitTime:          10 * time.Second,
+	DrainTimeout:      30 * time.Second,
+}
+
+// Stats counts what the worker did since it started.
+type Stats struct {
+	Received        int64     `json:"received"`
+	Processed       int64     `json:"processed"`
+	Failed          int64     `json:"failed"`
+	Quarantined     int64     `json:"quarantined"`
+	Dropped         int64     `json:"dropped"`
+	InFlight        int64     `json:"inFlight"`
+	ReceiveErrors   int64     `json:"receiveErrors"`
+	HeartbeatErrors int64     `json:"heartbeatErrors"`
+	LastPoll        time.Time `json:"lastPoll"`
+}
+
+type counters struct {
+	received        atomic.Int64
+	processed       atomic.Int64
+	failed          atomic.Int64
+	quarantined     atomic.Int64
+	dropped         atomic.Int64
+	inFlight        atomic.Int64
+	receiveErrors   atomic.Int64
+	heartbeatErrors atomic.Int64
+	lastPoll        atomic.Int64
+}
+
+// ResponseWorker handles the messages the summarization worker sends to the
+// response queue: job events are passed on to the browser and results are
+// recorded.
+type ResponseWorker struct {
+	pool        *pgxpool.Pool
+	sqsClient   *sqs.Client
+	s3Client    *s3.Client
+	queries     *sqlc.Queries
+	queueName   string
+	broadcaster *events.Broadcaster
+	llmClient   *llm.Client
+	quotas      *quota.Service
+	dispatcher  *dispatch.Dispatcher
+	deadLetters *deadletter.Service
+	cfg         Config
+
+	queueUrl *string
+	stats    counters
+	jobs     jobLocks
+	started  atomic.Int64
+	stopped  atomic.Bool
+
+	// stopReceiving ends the receive loop; abort cancels messages still
+	// being handled once draining takes too long.
+	stopReceiving context.CancelFunc
+	abort         context.CancelFunc
+	receiving     sync.WaitGroup
+	handling      sync.WaitGroup
+	stopOnce      sync.Once
+}
+
+func NewResponseWorker(pool *pgxpool.Pool, sqsClient *sqs.Client, s3Client *s3.Client, queries *sqlc.Queries, queueName string, broadcaster *events.Broadcaster, llmClient *llm.Client, quotas *quota.Service, dispatcher *dispatch.Dispatcher, deadLetters *deadletter.Service, cfg Config) *ResponseWorker {
+	return &ResponseWorker{
+		pool:        pool,
+		sqsClient:   sqsClient,
+		s3Client:    s3Client,
+		queries:     queries,
+		queueName:   queueName,
+		broadcaster: broadcaster,
+		llmClient:   llmClient,
+		quotas:      quotas,
+		dispatcher:  dispatcher,
+		deadLetters: deadLetters,
+		cfg:         cfg,
+		jobs:        jobLocks{locks: map[int32]*jobLock{}},
+	}
+}
+
+// Start looks up the response queue and receives from it until Stop is
+// called or ctx is done.
+func (w *ResponseWorker) Start(ctx context.Context) error {
+	out, err := w.sqsClient.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
+		QueueName: aws.String(w.queueName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to look up queue %s: %w", w.queueName, err)
+	}
+	w.queueUrl = out.QueueUrl
+
+	receiveCtx, stopReceiving := context.WithCancel(ctx)
+	// messages being handled finish even when ctx is done; Stop decides
+	// how long they get
+	handleCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	w.stopReceiving = stopReceiving
+	w.abort = abort
+
+	w.started.Store(time.Now().UnixNano())
+	w.receiving.Add(1)
+	go func() {
+		defer w.receiving.Done()
+		w.receive(receiveCtx, handleCtx)
+	}()
+	return nil
+}
+
+// Stop stops receiving and waits for the messages being handled, for at
+// most DrainTimeout or until ctx is done. Messages still unfinished then are
+// abandoned and come back to the queue once their visibility times out.
+func (w *ResponseWorker) Stop(ctx context.Context) error {
+	if w.stopReceiving == nil {
+		return nil
+	}
+	var err error
+	w.stopOnce.Do(func() {
+		w.stopped.Store(true)
+		w.stopReceiving()
+		w.receiving.Wait()
+
+		drained := make(chan struct{})
+		go func() {
+			w.handling.Wait()
+			close(drained)
+		}()
+
+		ctx, cancel := context.WithTimeout(ctx, w.cfg.DrainTimeout)
+		defer cancel()
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			err = fmt.Errorf("%d messages still being handled: %w", w.stats.inFlight.Load(), ctx.Err())
+		}
+		w.abort()
+		<-drained
+
+		s := w.Stats()
+		slog.Info("response worker stopped",
+			"received", s.Received,
+			"processed", s.Processed,
+			"failed", s.Failed,
+			"quarantined", s.Quarantined,
+			"dropped", s.Dropped,
+		)
+	})
+	return err
+}
+
+// Stats returns the worker's counters.
+func (w *ResponseWorker) Stats() Stats {
+	s := Stats{
+		Received:        w.stats.received.Load(),
+		Processed:       w.stats.processed.Load(),
+		Failed:          w.stats.failed.Load(),
+		Quarantined:     w.stats.quarantined.Load(),
+		Dropped:         w.stats.dropped.Load(),
+		InFlight:        w.stats.inFlight.Load(),
+		ReceiveErrors:   w.stats.receiveErrors.Load(),
+		HeartbeatErrors: w.stats.heartbeatErrors.Load(),
+	}
+	if t := w.stats.lastPoll.Load(); t != 0 {
+		s.LastPoll = time.Unix(0, t)
+	}
+	return s
+}
+
+// CheckPolling returns an error if the worker isn't receiving: it isn't
+// running, or it has had a free slot for longer than maxAge without a
+// receive succeeding.
+func (w *ResponseWorker) CheckPolling(maxAge time.Duration) error {
+	if w.started.Load() == 0 || w.stopped.Load() {
+		return errors.New("response worker is not running")
+	}
+	if w.stats.inFlight.Load() >= int64(w.cfg.Concurrency) {
+		// busy; it polls again once a message is done
+		return nil
+	}
+	last := max(w.stats.lastPoll.Load(), w.started.Load())
+	if age := time.Since(time.Unix(0, last)); age > maxAge {
+		return fmt.Errorf("last poll of %s was %s ago", w.queueName, age.Round(time.Second))
+	}
+	return nil
+}
+
+// MaxPollAge is how long the worker can go without a successful receive
+// while it is healthy: a long poll, the pause after a failed one and
+// another long poll, with some slack.
+func (w *ResponseWorker) MaxPollAge() time.Duration {
+	return 2*w.cfg.WaitTime + receiveRetryDelay + 10*time.Second
+}
+
+// receive hands messages to handlers, receiving only as many as there are
+// free slots so no message waits unhandled while its visibility runs out.
+func (w *ResponseWorker) receive(ctx context.Context, handleCtx context.Context) {
+	slots := make(chan struct{}, w.cfg.Concurrency)
+	for {
+		// wait for a free slot, then take every other free one up to the
+		// most SQS returns at once
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		free := 1
+		for free < 10 && tryAcquire(slots) {
+			free++
+		}
+
+		resp, err := w.sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            w.queueUrl,
+			MaxNumberOfMessages: int32(free),
+			WaitTimeSeconds:     int32(w.cfg.WaitTime.Seconds()),
+			VisibilityTimeout:   int32(w.cfg.VisibilityTimeout.Seconds()),
+			MessageSystemAttributeNames: []sqstypes.MessageSystemAttributeName{
+				sqstypes.MessageSystemAttributeNameApproximateReceiveCount,
+			},
+			// the worker's trace context
+			MessageAttributeNames: []string{"traceparent", "tracestate"},
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			w.stats.receiveErrors.Add(1)
+			slog.Error("failed to receive messages", "queue", w.queueName, "error", err)
+			release(slots, free)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(receiveRetryDelay):
+			}
+			continue
+		}
+		w.stats.lastPoll.Store(time.Now().UnixNano())
+		w.stats.received.Add(int64(len(resp.Messages)))
+
+		release(slots, free-len(resp.Messages))
+		for _, m := range resp.Messages {
+			w.handling.Add(1)
+			w.stats.inFlight.Add(1)
+			go func() {
+				defer func() {
+					w.stats.inFlight.Add(-1)
+					<-slots
+					w.handling.Done()
+				}()
+				w.process(handleCtx, m)
+			}()
+		}
+	}
+}
+
+func tryAcquire(slots chan struct{}) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func release(slots chan struct{}, n int) {
+	for range n {
+		<-slots
+	}
+}
+
+// process handles one message while keeping it hidden from other consumers.
+func (w *ResponseWorker) process(ctx context.Context, m sqstypes.Message) {
+	// the span continues the trace of the job the message is about
+	ctx, span := tracing.Start(tracing.ExtractMessage(ctx, m.MessageAttributes), w.queueName+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "aws_sqs"),
+			attribute.String("messaging.destination.name", w.queueName),
+			attribute.String("messaging.message.id", aws.ToString(m.MessageId)),
+		),
+	)
+	defer span.End()
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go w.heartbeat(heartbeatCtx, m)
+
+	res := w.handle(ctx, m)
+	span.SetAttributes(attribute.String("outcome", res.String()))
+	if res == outcomeRetry {
+		span.SetStatus(codes.Error, "left for another try")
+	}
+	switch res {
+	case outcomeProcessed:
+		w.stats.processed.Add(1)
+	case outcomeRetry:
+		w.stats.failed.Add(1)
+	case outcomeQuarantined:
+		w.stats.quarantined.Add(1)
+	case outcomeDropped:
+		w.stats.dropped.Add(1)
+	}
+}
+
+// heartbeat extends the visibility of m until ctx is done, so a message
+// taking long, e.g. a structured summary being repaired, isn't delivered
+// again meanwhile.
+func (w *ResponseWorker) heartbeat(ctx context.Context, m sqstypes.Message) {
+	ticker := time.NewTicker(w.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		_, err := w.sqsClient.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          w.queueUrl,
+			ReceiptHandle:     m.ReceiptHandle,
+			VisibilityTimeout: int32(w.cfg.VisibilityTimeout.Seconds()),
+		})
+		if err != nil && ctx.Err() == nil {
+			w.stats.heartbeatErrors.Add(1)
+			slog.ErrorContext(ctx, "failed to extend message visibility", "message_id", aws.ToString(m.MessageId), "error", err)
+		}
+	}
+}
+
+// jobLocks serializes the messages of one job, so e.g. a job's result is
+// never recorded before it was marked running.
+type jobLocks struct {
+	mu    sync.Mutex
+	locks map[int32]*jobLock
+}
+
+type jobLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func (l *jobLocks) lock(jobID int32) func() {
+	l.mu.Lock()
+	jl, ok := l.locks[jobID]
+	if !ok {
+		jl = &jobLock{}
+		l.locks[jobID] = jl
+	}
+	jl.refs++
+	l.mu.Unlock()
+
+	jl.mu.Lock()
+	return func() {
+		jl.mu.Unlock()
+		l.mu.Lock()
+		jl.refs--
+		if jl.refs == 0 {
+			delete(l.locks, jobID)
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,4 @@
+drop table if exists summary_cache;
+drop table if exists jobs;
+drop table if exists documents;
+drop table if exists blobs;
@@ -0,0 +1,47 @@
+create table if not exists blobs (
+    hash varchar(64) primary key,
+    size bigint not null,
+    storage_key varchar(255) not null,
+    ref_count int not null default 0,
+    created_at timestamp default current_timestamp
+);
+
+create table if not exists documents (
+    id serial primary key,
+    user_id int not null references users(id) on delete cascade,
+    path varchar(1024) not null,
+    blob_hash varchar(64) not null references blobs(hash),
+    size bigint not null,
+    status varchar(20) not null default 'pending',
+    summary_key varchar(1024),
+    created_at timestamp default current_timestamp,
+    updated_at timestamp default current_timestamp
+);
+
+create index if not exists documents_user_id_idx on documents(user_id);
+create index if not exists documents_blob_hash_idx on documents(blob_hash);
+
+create table if not exists jobs (
+    id serial primary key,
+    document_id int not null references documents(id) on delete cascade,
+    user_id int not null references users(id) on delete cascade,
+    content_hash varchar(64) not null,
+    model varchar(255) not null,
+    prompt_version varchar(50) not null,
+    status varchar(20) not null default 'queued',
+    error text,
+    created_at timestamp default current_timestamp,
+    updated_at timestamp default current_timestamp,
+    completed_at timestamp
+);
+
+create index if not exists jobs_document_id_idx on jobs(document_id);
+
+create table if not exists summary_cache (
+    content_hash varchar(64) not null,
+    model varchar(255) not null,
+    prompt_version varchar(50) not null,
+    summary_key varchar(1024) not null,
+    created_at timestamp default current_timestamp,
+    primary key (content_hash, model, prompt_version)
+);
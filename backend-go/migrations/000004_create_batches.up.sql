@@ -0,0 +1,13 @@
+create table if not exists batches (
+    id serial primary key,
+    user_id int not null references users(id) on delete cascade,
+    name varchar(1024) not null,
+    status varchar(20) not null default 'open',
+    total int not null default 0,
+    completed int not null default 0,
+    failed int not null default 0,
+    created_at timestamp default current_timestamp,
+    completed_at timestamp
+);
+
+alter table jobs add column if not exists batch_id int references batches(id) on delete set null;
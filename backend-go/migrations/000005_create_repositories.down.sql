@@ -0,0 +1,4 @@
+drop table if exists document_versions;
+alter table documents drop column if exists source_sha;
+alter table documents drop column if exists repository_id;
+drop table if exists repositories;
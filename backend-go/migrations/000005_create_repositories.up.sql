@@ -0,0 +1,25 @@
+create table if not exists repositories (
+    id serial primary key,
+    user_id int not null references users(id) on delete cascade,
+    path varchar(1024) not null,
+    ref varchar(255) not null,
+    last_commit varchar(40),
+    created_at timestamp default current_timestamp,
+    synced_at timestamp,
+    unique (user_id, path)
+);
+
+alter table documents add column if not exists repository_id int references repositories(id) on delete set null;
+alter table documents add column if not exists source_sha varchar(40);
+
+create index if not exists documents_repository_id_idx on documents(repository_id);
+
+create table if not exists document_versions (
+    id serial primary key,
+    document_id int not null references documents(id) on delete cascade,
+    blob_hash varchar(64) not null,
+    commit_sha varchar(40),
+    created_at timestamp default current_timestamp
+);
+
+create index if not exists document_versions_document_id_idx on document_versions(document_id);
@@ -0,0 +1,4 @@
+drop index if exists documents_user_created_idx;
+drop table if exists document_tags;
+alter table summary_cache drop column if exists preview;
+alter table documents drop column if exists summary_preview;
@@ -0,0 +1,11 @@
+alter table documents add column if not exists summary_preview varchar(300);
+alter table summary_cache add column if not exists preview varchar(300);
+
+create table if not exists document_tags (
+    document_id int not null references documents(id) on delete cascade,
+    tag varchar(64) not null,
+    primary key (document_id, tag)
+);
+
+create index if not exists document_tags_tag_idx on document_tags(tag);
+create index if not exists documents_user_created_idx on documents(user_id, created_at, id);
@@ -0,0 +1,3 @@
+alter table documents drop column if exists workspace_id;
+drop table if exists workspace_members;
+drop table if exists workspaces;
@@ -0,0 +1,20 @@
+create table if not exists workspaces (
+    id serial primary key,
+    name varchar(255) not null,
+    owner_id int not null references users(id) on delete cascade,
+    created_at timestamp default current_timestamp
+);
+
+create table if not exists workspace_members (
+    workspace_id int not null references workspaces(id) on delete cascade,
+    user_id int not null references users(id) on delete cascade,
+    role varchar(20) not null default 'member',
+    created_at timestamp default current_timestamp,
+    primary key (workspace_id, user_id)
+);
+
+create index if not exists workspace_members_user_id_idx on workspace_members(user_id);
+
+alter table documents add column if not exists workspace_id int references workspaces(id) on delete set null;
+
+create index if not exists documents_workspace_id_idx on documents(workspace_id);
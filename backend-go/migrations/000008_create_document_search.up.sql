@@ -0,0 +1,19 @@
+create table if not exists document_search (
+    document_id int primary key references documents(id) on delete cascade,
+    title text not null default '',
+    content text not null default '',
+    summary text not null default '',
+    search_vector tsvector generated always as (
+        setweight(to_tsvector('english', title), 'A') ||
+        setweight(to_tsvector('english', summary), 'B') ||
+        setweight(to_tsvector('english', content), 'C')
+    ) stored
+);
+
+create index if not exists document_search_vector_idx on document_search using gin (search_vector);
+
+-- content lives in the bucket, so existing documents are searchable by path
+-- until they are uploaded or summarized again
+insert into document_search (document_id, title)
+select id, path from documents
+on conflict (document_id) do nothing;
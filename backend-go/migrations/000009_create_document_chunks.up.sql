@@ -0,0 +1,28 @@
+-- pgvector is optional; without it nearest-neighbour lookups run on an
+-- in-process index instead
+do $$
+begin
+    if exists (select 1 from pg_available_extensions where name = 'vector') then
+        create extension if not exists vector;
+    end if;
+exception when insufficient_privilege then
+    raise notice 'skipping pgvector: %', sqlerrm;
+end
+$$;
+
+create table if not exists document_chunks (
+    id serial primary key,
+    document_id int not null references documents(id) on delete cascade,
+    chunk_index int not null,
+    heading text not null default '',
+    start_line int not null,
+    end_line int not null,
+    content text not null,
+    content_hash varchar(64) not null,
+    model varchar(255) not null,
+    embedding real[] not null,
+    created_at timestamp default current_timestamp,
+    unique (document_id, model, chunk_index)
+);
+
+create index if not exists document_chunks_model_idx on document_chunks(model, document_id);
@@ -0,0 +1,2 @@
+drop table if exists ask_messages;
+drop table if exists ask_threads;
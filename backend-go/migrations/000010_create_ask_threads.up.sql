@@ -0,0 +1,21 @@
+create table if not exists ask_threads (
+    id serial primary key,
+    document_id int not null references documents(id) on delete cascade,
+    user_id int not null references users(id) on delete cascade,
+    title varchar(255) not null,
+    created_at timestamp default current_timestamp,
+    updated_at timestamp default current_timestamp
+);
+
+create index if not exists ask_threads_document_user_idx on ask_threads(document_id, user_id);
+
+create table if not exists ask_messages (
+    id serial primary key,
+    thread_id int not null references ask_threads(id) on delete cascade,
+    role varchar(20) not null,
+    content text not null,
+    citations jsonb not null default '[]',
+    created_at timestamp default current_timestamp
+);
+
+create index if not exists ask_messages_thread_id_idx on ask_messages(thread_id, id);
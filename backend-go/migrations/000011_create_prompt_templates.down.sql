@@ -0,0 +1,6 @@
+alter table summary_cache drop column if exists template_version;
+alter table summary_cache drop column if exists template_id;
+alter table jobs drop column if exists template_version;
+alter table jobs drop column if exists template_id;
+drop table if exists summary_preferences;
+drop table if exists prompt_templates;
@@ -0,0 +1,31 @@
+create table if not exists prompt_templates (
+    id serial primary key,
+    name varchar(50) not null,
+    version int not null,
+    -- null for the built-in styles, the owner for custom templates
+    user_id int references users(id) on delete cascade,
+    body text not null,
+    created_at timestamp default current_timestamp
+);
+
+create unique index if not exists prompt_templates_name_version_idx on prompt_templates(coalesce(user_id, 0), name, version);
+
+insert into prompt_templates (name, version, body) values
+    ('tldr', 1, 'Summarize the following text, {{length}}. Write the summary in {{language}}.'),
+    ('bullets', 1, 'List the key points of the following text as a markdown bullet list, {{length}}. Write the list in {{language}}.'),
+    ('executive', 1, 'Write an executive summary of the following text for a busy decision maker: the situation, the key findings and what needs to be decided or done, {{length}}. Write the summary in {{language}}.'),
+    ('deep-dive', 1, 'Write a technical deep-dive of the following text for engineers: the architecture, the key mechanisms, trade-offs and caveats, {{length}}. Use markdown headings where they help. Write the summary in {{language}}.')
+on conflict do nothing;
+
+create table if not exists summary_preferences (
+    user_id int primary key references users(id) on delete cascade,
+    style varchar(50) not null,
+    length varchar(20) not null,
+    language varchar(40) not null,
+    updated_at timestamp default current_timestamp
+);
+
+alter table jobs add column if not exists template_id int references prompt_templates(id) on delete set null;
+alter table jobs add column if not exists template_version int;
+alter table summary_cache add column if not exists template_id int references prompt_templates(id) on delete set null;
+alter table summary_cache add column if not exists template_version int;
@@ -0,0 +1,2 @@
+alter table jobs drop column if exists format;
+alter table summary_preferences drop column if exists format;
@@ -0,0 +1,2 @@
+alter table summary_preferences add column if not exists format varchar(20) not null default 'text';
+alter table jobs add column if not exists format varchar(20) not null default 'text';
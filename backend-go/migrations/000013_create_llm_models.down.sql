@@ -0,0 +1,7 @@
+drop index if exists jobs_user_created_idx;
+alter table jobs drop column if exists cost;
+alter table jobs drop column if exists latency_ms;
+alter table jobs drop column if exists completion_tokens;
+alter table jobs drop column if exists prompt_tokens;
+alter table summary_preferences drop column if exists model;
+drop table if exists llm_models;
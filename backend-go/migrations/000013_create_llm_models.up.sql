@@ -0,0 +1,28 @@
+create table if not exists llm_models (
+    id varchar(255) primary key,
+    name varchar(255) not null,
+    context_tokens int not null,
+    -- USD per million tokens
+    prompt_price double precision not null default 0,
+    completion_price double precision not null default 0,
+    -- 1 is enough for short summaries, 3 for the most demanding styles
+    quality int not null default 1,
+    enabled boolean not null default true,
+    created_at timestamp default current_timestamp
+);
+
+insert into llm_models (id, name, context_tokens, prompt_price, completion_price, quality) values
+    ('x-ai/grok-4-fast:free', 'Grok 4 Fast (free)', 2000000, 0, 0, 2),
+    ('openai/gpt-4o-mini', 'GPT-4o mini', 128000, 0.15, 0.60, 1),
+    ('google/gemini-2.0-flash-001', 'Gemini 2.0 Flash', 1000000, 0.10, 0.40, 2),
+    ('anthropic/claude-sonnet-4', 'Claude Sonnet 4', 200000, 3.00, 15.00, 3)
+on conflict do nothing;
+
+alter table summary_preferences add column if not exists model varchar(255) not null default 'auto';
+
+alter table jobs add column if not exists prompt_tokens int not null default 0;
+alter table jobs add column if not exists completion_tokens int not null default 0;
+alter table jobs add column if not exists latency_ms int not null default 0;
+alter table jobs add column if not exists cost double precision not null default 0;
+
+create index if not exists jobs_user_created_idx on jobs(user_id, created_at);
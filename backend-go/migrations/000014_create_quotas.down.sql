@@ -0,0 +1,4 @@
+drop table if exists workspace_quota_overrides;
+drop table if exists quota_overrides;
+drop table if exists quota_usage;
+alter table users drop column if exists is_admin;
@@ -0,0 +1,43 @@
+alter table users add column if not exists is_admin boolean not null default false;
+
+create table if not exists quota_usage (
+    user_id int primary key references users(id) on delete cascade,
+    bytes_stored bigint not null default 0,
+    pending_jobs int not null default 0,
+    -- uploads counts the uploads on uploads_day, tokens and cost the spend
+    -- in the month starting on spend_month
+    uploads_day date not null default current_date,
+    uploads int not null default 0,
+    spend_month date not null default date_trunc('month', current_date),
+    tokens bigint not null default 0,
+    cost double precision not null default 0,
+    updated_at timestamp default current_timestamp
+);
+
+-- null columns fall back to the configured defaults
+create table if not exists quota_overrides (
+    user_id int primary key references users(id) on delete cascade,
+    max_bytes bigint,
+    uploads_per_day int,
+    tokens_per_month bigint,
+    cost_per_month double precision,
+    pending_jobs int,
+    note text not null default '',
+    granted_by int references users(id) on delete set null,
+    updated_at timestamp default current_timestamp
+);
+
+create table if not exists workspace_quota_overrides (
+    workspace_id int primary key references workspaces(id) on delete cascade,
+    max_bytes bigint not null,
+    granted_by int references users(id) on delete set null,
+    updated_at timestamp default current_timestamp
+);
+
+-- existing data counts against the new quotas
+insert into quota_usage (user_id, bytes_stored, pending_jobs)
+select u.id,
+       coalesce((select sum(d.size) from documents d where d.user_id = u.id), 0),
+       (select count(*) from jobs j where j.user_id = u.id and j.status in ('queued', 'running'))
+from users u
+on conflict do nothing;
@@ -0,0 +1,6 @@
+drop index if exists jobs_dispatched_idx;
+drop index if exists jobs_waiting_idx;
+alter table jobs drop column if exists dispatched_at;
+alter table jobs drop column if exists task;
+alter table jobs drop column if exists priority;
+alter table jobs drop column if exists lane;
@@ -0,0 +1,14 @@
+-- lane and priority decide the order the dispatcher sends jobs in; task is
+-- the message it sends and dispatched_at when it did
+alter table jobs add column if not exists lane varchar(20) not null default 'interactive';
+alter table jobs add column if not exists priority int not null default 0;
+alter table jobs add column if not exists task text;
+alter table jobs add column if not exists dispatched_at timestamp;
+
+-- jobs created before the dispatcher went to the task queue right away
+update jobs set dispatched_at = created_at where dispatched_at is null;
+
+create index if not exists jobs_waiting_idx on jobs(lane, user_id, priority desc, id)
+    where dispatched_at is null and status = 'queued';
+create index if not exists jobs_dispatched_idx on jobs(dispatched_at)
+    where status in ('queued', 'running');
@@ -0,0 +1,19 @@
+-- messages taken off a queue because they can't be processed, either right
+-- away (unparseable, object missing) or after too many receives from the
+-- queue's dead-letter queue
+create table if not exists quarantined_messages (
+    id serial primary key,
+    queue_name text not null,
+    message_id text not null,
+    body text not null,
+    reason text not null,
+    receive_count int not null default 0,
+    job_id int references jobs(id) on delete set null,
+    status text not null default 'quarantined',
+    created_at timestamp default current_timestamp,
+    resolved_at timestamp,
+    resolved_by int references users(id) on delete set null
+);
+
+create unique index if not exists quarantined_messages_message_idx on quarantined_messages (queue_name, message_id);
+create index if not exists quarantined_messages_status_idx on quarantined_messages (status, created_at);
@@ -0,0 +1,25 @@
+-- messages to publish, written in the same transaction as the change they
+-- announce and sent by the relay until SQS accepts them
+create table if not exists outbox_messages (
+    id serial primary key,
+    -- the queue's role, e.g. tasks; the relay maps it to the SQS queue
+    queue_name text not null,
+    body text not null,
+    job_id int references jobs(id) on delete set null,
+    attempts int not null default 0,
+    last_error text,
+    available_at timestamp not null default current_timestamp,
+    created_at timestamp default current_timestamp,
+    sent_at timestamp
+);
+
+create index if not exists outbox_messages_unsent_idx on outbox_messages (available_at)
+    where sent_at is null;
+create index if not exists outbox_messages_job_idx on outbox_messages (job_id);
+
+-- jobs dispatched before the outbox were sent directly; record them as sent
+-- so they aren't taken for jobs whose message was lost
+insert into outbox_messages (queue_name, body, job_id, available_at, created_at, sent_at)
+select 'tasks', coalesce(task, ''), id, dispatched_at, dispatched_at, dispatched_at
+from jobs
+where dispatched_at is not null and status in ('queued', 'running');
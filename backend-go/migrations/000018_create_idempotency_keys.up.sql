@@ -0,0 +1,16 @@
+-- responses of requests sent with an Idempotency-Key, replayed when the same
+-- request comes again before expires_at
+create table if not exists idempotency_keys (
+    user_id int not null references users(id) on delete cascade,
+    key text not null,
+    fingerprint text not null,
+    status text not null default 'processing',
+    response_status int,
+    response_body bytea,
+    content_type text,
+    created_at timestamp default current_timestamp,
+    expires_at timestamp not null,
+    primary key (user_id, key)
+);
+
+create index if not exists idempotency_keys_expires_idx on idempotency_keys (expires_at);
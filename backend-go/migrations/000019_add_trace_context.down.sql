@@ -0,0 +1,2 @@
+alter table outbox_messages drop column if exists trace_context;
+alter table jobs drop column if exists trace_context;
@@ -0,0 +1,4 @@
+-- W3C trace context of the request that created a job, so the task the
+-- dispatcher sends later continues the same trace
+alter table jobs add column if not exists trace_context jsonb;
+alter table outbox_messages add column if not exists trace_context jsonb;
@@ -4,14 +4,27 @@ import { useState, useEffect } from "react";
 import { Upload, FileText, History } from "lucide-react";
 
 interface FileItem {
+  id: number;
+  name: string;
+  path: string;
+  uploadedAt: string;
+  status: string;
+  summaryPreview?: string;
+}
+
+interface ModelItem {
+  id: string;
   name: string;
-  uploaded_at: string;
 }
 
 export default function DashboardPage() {
   const [file, setFile] = useState<File | null>(null);
-  const [model, setModel] = useState("gpt-4");
+  const [model, setModel] = useState("auto");
+  const [models, setModels] = useState<ModelItem[]>([]);
+  // empty means the user's saved default
+  const [style, setStyle] = useState("");
   const [overview, setOverview] = useState("");
+  const [progress, setProgress] = useState("");
   const [loading, setLoading] = useState(false);
   const [files, setFiles] = useState<FileItem[]>([]);
   const [historyLoading, setHistoryLoading] = useState(false);
@@ -32,13 +45,12 @@ export default function DashboardPage() {
       const formData = new FormData();
       formData.append("file", file);
       formData.append("model", model);
+      if (style) formData.append("style", style);
 
   const apiBase = process.env.NEXT_PUBLIC_API_BASE || "http://localhost:8080";
-  const res = await fetch(`${apiBase}/upload`, {
-        method: "POST",
-        body: formData,
-        credentials: "include",
-      });
+      // the same key on every attempt lets the backend drop duplicates
+      const idempotencyKey = crypto.randomUUID();
+      const res = await uploadWithRetry(`${apiBase}/upload`, formData, idempotencyKey);
 
       if (!res.ok) {
         setOverview("Error: Failed to upload or generate overview.");
@@ -54,18 +66,39 @@ export default function DashboardPage() {
     }
   }
 
+  async function uploadWithRetry(url: string, formData: FormData, idempotencyKey: string, attempts = 3): Promise<Response> {
+    for (let attempt = 1; ; attempt++) {
+      try {
+        const res = await fetch(url, {
+          method: "POST",
+          body: formData,
+          credentials: "include",
+          headers: { "Idempotency-Key": idempotencyKey },
+        });
+        // 409: the first attempt is still being processed
+        if ((res.status === 409 || res.status >= 500) && attempt < attempts) {
+          await new Promise((r) => setTimeout(r, attempt * 1000));
+          continue;
+        }
+        return res;
+      } catch (err) {
+        if (attempt >= attempts) throw err;
+        await new Promise((r) => setTimeout(r, attempt * 1000));
+      }
+    }
+  }
+
   async function fetchFiles() {
     setHistoryLoading(true);
     try {
   const apiBase = process.env.NEXT_PUBLIC_API_BASE || "http://localhost:8080";
   const res = await fetch(`${apiBase}/files`, {
-        method: "POST",
         credentials: "include",
       });
 
       if (res.ok) {
         const data = await res.json();
-        setFiles(data.files || []);
+        setFiles(data.items || []);
       } else {
         console.error("Failed to fetch files history");
       }
@@ -78,23 +111,96 @@ export default function DashboardPage() {
 
   useEffect(() => {
   const apiBase = process.env.NEXT_PUBLIC_API_BASE || "http://localhost:8080";
-  const evtSource = new EventSource(`${apiBase}/events`);
+    let evtSource: EventSource;
+    let retryTimer: ReturnType<typeof setTimeout> | undefined;
+    let failures = 0;
+    const handlers: [string, (data: any) => void][] = [];
 
-    evtSource.onmessage = (event) => {
-      try {
-        const data = JSON.parse(event.data);
-          setOverview(data.content);
-      } catch (err) {
-        console.error("Failed to parse SSE message:", err);
+    const listen = (type: string, handle: (data: any) => void) => {
+      handlers.push([type, handle]);
+    };
+
+    // the backend closes the stream with a reconnect event when it shuts
+    // down; open a new one, which may reach another instance
+    const connect = () => {
+      evtSource = new EventSource(`${apiBase}/events`, { withCredentials: true });
+      evtSource.onopen = () => {
+        failures = 0;
+      };
+      for (const [type, handle] of handlers) {
+        evtSource.addEventListener(type, (event) => {
+          try {
+            handle(JSON.parse((event as MessageEvent).data));
+          } catch (err) {
+            console.error("Failed to parse SSE message:", err);
+          }
+        });
       }
+      evtSource.addEventListener("reconnect", (event) => {
+        evtSource.close();
+        const { retryMs } = JSON.parse((event as MessageEvent).data);
+        // events may have been missed meanwhile
+        retryTimer = setTimeout(() => {
+          connect();
+          fetchFiles();
+        }, retryMs + Math.random() * 1000);
+      });
+      evtSource.onerror = (err) => {
+        console.error("SSE connection error:", err);
+        evtSource.close();
+        failures++;
+        retryTimer = setTimeout(connect, Math.min(30000, 1000 * 2 ** failures));
+      };
     };
 
-    evtSource.onerror = (err) => {
-      console.error("SSE connection error:", err);
+    // partial summaries can arrive out of order, so keep the newest one
+    let lastSeq = 0;
+
+    listen("job_accepted", () => {
+      lastSeq = 0;
+      setProgress("Queued");
+    });
+    listen("job_started", () => setProgress("Summarizing..."));
+    listen("job_progress", (data) =>
+      setProgress(`Summarized part ${data.chunk} of ${data.chunks}`)
+    );
+    listen("job_partial", (data) => {
+      if (data.seq > lastSeq) {
+        lastSeq = data.seq;
+        setOverview(data.text);
+      }
+    });
+    listen("job_completed", (data) => {
+      lastSeq = Number.MAX_SAFE_INTEGER;
+      setProgress("");
+      setOverview(data.content);
+      fetchFiles();
+    });
+    listen("job_failed", (data) => {
+      setProgress("");
+      setOverview(`Summarization failed: ${data.error}`);
+    });
+    listen("job_cancelled", () => {
+      lastSeq = Number.MAX_SAFE_INTEGER;
+      setProgress("");
+      setOverview("Summarization cancelled");
+      fetchFiles();
+    });
+
+    connect();
+
+    return () => {
+      clearTimeout(retryTimer);
       evtSource.close();
     };
+  }, []);
 
-    return () => evtSource.close();
+  useEffect(() => {
+    const apiBase = process.env.NEXT_PUBLIC_API_BASE || "http://localhost:8080";
+    fetch(`${apiBase}/models`, { credentials: "include" })
+      .then((res) => (res.ok ? res.json() : { models: [] }))
+      .then((data) => setModels(data.models || []))
+      .catch((err) => console.error(err));
   }, []);
 
   useEffect(() => {
@@ -139,9 +245,29 @@ export default function DashboardPage() {
               onChange={(e) => setModel(e.target.value)}
               className="rounded-lg border border-gray-300 p-3 focus:border-teal-500 focus:ring focus:ring-teal-200"
             >
-              <option value="gpt-4">GPT-4</option>
-              <option value="gpt-3.5">GPT-3.5</option>
-              <option value="claude-3">Claude 3</option>
+              <option value="auto">Automatic</option>
+              {models.map((m) => (
+                <option key={m.id} value={m.id}>
+                  {m.name}
+                </option>
+              ))}
+            </select>
+          </div>
+
+          {/* Style selector */}
+          <div className="flex flex-col gap-2">
+            <label className="font-semibold text-gray-700">Summary Style</label>
+            <select
+              value={style}
+              onChange={(e) => setStyle(e.target.value)}
+              className="rounded-lg border border-gray-300 p-3 focus:border-teal-500 focus:ring focus:ring-teal-200"
+            >
+              <option value="">My default</option>
+              <option value="tldr">TL;DR</option>
+              <option value="bullets">Key points</option>
+              <option value="executive">Executive summary</option>
+              <option value="deep-dive">Technical deep-dive</option>
+              <option value="custom">My custom template</option>
             </select>
           </div>
 
@@ -159,6 +285,9 @@ export default function DashboardPage() {
             <div className="flex items-center gap-2 mb-3">
               <FileText className="h-6 w-6 text-blue-600" />
               <h2 className="text-xl font-bold text-gray-900">Overview</h2>
+              {progress && (
+                <span className="ml-auto text-sm text-gray-500">{progress}</span>
+              )}
             </div>
             <p className="text-gray-700 whitespace-pre-wrap">
               {overview || "Your file overview will appear here."}
@@ -178,17 +307,17 @@ export default function DashboardPage() {
             <p className="text-gray-600">No files uploaded yet.</p>
           ) : (
             <ul className="max-h-[500px] overflow-y-auto space-y-3">
-              {files.map((f, i) => (
+              {files.map((f) => (
                 <li
-                  key={i}
+                  key={f.id}
                   className="cursor-pointer rounded-lg border border-gray-200 bg-white/80 p-3 text-gray-800 shadow-sm transition hover:bg-teal-50"
                   onClick={() =>
-                    setOverview(`Overview for: ${f.name}\n\n(Click would fetch from backend)`)
+                    setOverview(f.summaryPreview || `Overview for: ${f.path}\n\n(${f.status})`)
                   }
                 >
                   <p className="font-medium">{f.name}</p>
                   <p className="text-xs text-gray-500">
-                    {new Date(f.uploaded_at).toLocaleString()}
+                    {new Date(f.uploadedAt).toLocaleString()}
                   </p>
                 </li>
               ))}
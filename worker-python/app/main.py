@@ -1,9 +1,20 @@
 import boto3
 import requests
+import contextlib
+import contextvars
 import json
+import logging
+import re
+import threading
 import time
 import os
 
+from opentelemetry import propagate, trace
+from opentelemetry.sdk.resources import Resource
+from opentelemetry.sdk.trace import TracerProvider
+from opentelemetry.sdk.trace.export import BatchSpanProcessor, ConsoleSpanExporter
+from opentelemetry.trace import SpanKind, Status, StatusCode
+
 LOCALSTACK_ENDPOINT = os.getenv("LOCALSTACK_ENDPOINT", "http://localhost:4566")
 AWS_DEFAULT_REGION = os.getenv("AWS_DEFAULT_REGION", "eu-central-1")
 
@@ -12,87 +23,503 @@ s3 = boto3.client("s3", endpoint_url=LOCALSTACK_ENDPOINT, region_name=AWS_DEFAUL
 
 TASK_QUEUE_URL = os.getenv("TASK_QUEUE_URL", "http://sqs.eu-central-1.localhost.localstack.cloud:4566/000000000000/task-queue")
 RESPONSE_QUEUE_URL = os.getenv("RESPONSE_QUEUE_URL", "http://sqs.eu-central-1.localhost.localstack.cloud:4566/000000000000/response-queue")
+# Tasks that can never be processed go here directly; the backend creates it
+# and quarantines what arrives in it.
+TASK_DLQ_URL = os.getenv("TASK_DLQ_URL", TASK_QUEUE_URL + "-dlq")
 OPENROUTER_API_KEY = os.getenv("OPENROUTER_API_KEY", "")  # Intentionally blank fallback
 OPENROUTER_URL = os.getenv("OPENROUTER_URL", "https://openrouter.ai/api/v1/chat/completions")
 
-def process_file(bucket, key):
+DEFAULT_MODEL = "x-ai/grok-4-fast:free"
+
+# debug, info, warning or error, and text or json
+LOG_LEVEL = os.getenv("LOG_LEVEL", "info").upper()
+LOG_FORMAT = os.getenv("LOG_FORMAT", "text")
+
+# otlp, stdout or none. The OTLP exporter reads its endpoint from the
+# standard OTEL_EXPORTER_OTLP_* variables.
+OTEL_TRACES_EXPORTER = os.getenv("OTEL_TRACES_EXPORTER", "none")
+OTEL_SERVICE_NAME = os.getenv("OTEL_SERVICE_NAME", "worker-python")
+# The backend reads the trace context from these message attributes.
+TRACE_ATTRIBUTES = ["traceparent", "tracestate"]
+
+# Documents longer than this are summarized chunk by chunk and the chunk
+# summaries are then combined.
+CHUNK_SIZE = 12000
+# Partial summaries are sent at most this often, in seconds.
+PARTIAL_INTERVAL = 0.5
+# The backend writes cancelled/<jobId> to the bucket to stop a job; it is
+# looked for at most this often, in seconds.
+CANCEL_CHECK_INTERVAL = 2.0
+
+DURATION_UNITS = {"h": 3600, "m": 60, "s": 1, "ms": 1e-3, "us": 1e-6, "\u00b5s": 1e-6, "ns": 1e-9}
+DURATION_PART = re.compile(r"(\d+(?:\.\d*)?|\.\d+)(h|ms|m|s|us|\u00b5s|ns)")
+
+
+def parse_duration(value):
+    """Parses a Go duration such as 5m or 1m30s, as the backend reads it, into seconds."""
+    value = value.strip()
+    pos, seconds = 0, 0.0
+    while pos < len(value):
+        match = DURATION_PART.match(value, pos)
+        if not match:
+            raise ValueError(f"invalid duration {value!r}")
+        seconds += float(match.group(1)) * DURATION_UNITS[match.group(2)]
+        pos = match.end()
+    if seconds <= 0:
+        raise ValueError(f"invalid duration {value!r}")
+    return seconds
+
+
+# A task is hidden from other receives while it is worked on: every
+# HEARTBEAT_INTERVAL seconds its visibility is extended to VISIBILITY_TIMEOUT
+# seconds from then, so a long LLM call doesn't get it delivered again. The
+# timeout is the one the backend sets on the queue.
+VISIBILITY_TIMEOUT = max(int(parse_duration(os.getenv("TASK_QUEUE_VISIBILITY_TIMEOUT") or "5m")), 1)
+HEARTBEAT_INTERVAL = VISIBILITY_TIMEOUT / 5
+
+# Used for task messages that do not carry a rendered prompt template.
+PROMPT = "Summarize following text in two sentences:\n"
+COMBINE_PROMPT = "Combine these summaries of consecutive parts of one document into a single summary of two sentences:\n"
+COMBINE_PREFIX = "The following are summaries of consecutive parts of one document. "
+
+
+def prompts_for(template):
+    """Returns the prompts for single chunks and for combining chunk summaries."""
+    if not template:
+        return PROMPT, COMBINE_PROMPT
+    prompt = template.rstrip() + "\n\n"
+    return prompt, COMBINE_PREFIX + prompt
+
+
+log = logging.getLogger("worker")
+
+# The job and document of the task being handled, added to every log line.
+log_context = contextvars.ContextVar("log_context", default={})
+
+# Secrets that could end up in a message, e.g. in an error from requests.
+SECRET_PATTERNS = [
+    (re.compile(r"([a-zA-Z][a-zA-Z0-9+.-]*://[^:/@\s]+:)[^@\s]+@"), r"\1[REDACTED]@"),
+    (re.compile(r"(?i)\b(bearer)\s+[A-Za-z0-9._~+/=-]+"), r"\1 [REDACTED]"),
+    (re.compile(r"(?i)\b(password|passwd|secret|token|api_key|apikey)=[^\s&;]+"), r"\1=[REDACTED]"),
+]
+
+
+def redact(text):
+    """Removes passwords in URLs and connection strings, and bearer tokens, from text."""
+    for pattern, replacement in SECRET_PATTERNS:
+        text = pattern.sub(replacement, text)
+    return text
+
+
+class LogFormatter(logging.Formatter):
+    """Formats records as text or JSON, with the log context and trace ID, and secrets redacted."""
+
+    def __init__(self, json_format):
+        super().__init__()
+        self.json_format = json_format
+
+    def format(self, record):
+        fields = dict(log_context.get())
+        span = trace.get_current_span().get_span_context()
+        if span.is_valid:
+            fields["trace_id"] = format(span.trace_id, "032x")
+        message = record.getMessage()
+        if record.exc_info:
+            message += "\n" + self.formatException(record.exc_info)
+        message = redact(message)
+
+        if self.json_format:
+            entry = {"time": self.formatTime(record), "level": record.levelname, "msg": message}
+            entry.update(fields)
+            return json.dumps(entry)
+        pairs = " ".join(f"{key}={value}" for key, value in fields.items())
+        return f"{self.formatTime(record)} {record.levelname} {message} {pairs}".rstrip()
+
+
+def setup_logging():
+    handler = logging.StreamHandler()
+    handler.setFormatter(LogFormatter(LOG_FORMAT == "json"))
+    logging.basicConfig(level=LOG_LEVEL, handlers=[handler])
+    # their debug output would drown the worker's
+    for name in ("boto3", "botocore", "urllib3"):
+        logging.getLogger(name).setLevel(max(logging.getLogger().level, logging.INFO))
+
+
+def setup_tracing():
+    """Exports spans as OTEL_TRACES_EXPORTER says; with none they are dropped, but trace context is still passed on."""
+    if OTEL_TRACES_EXPORTER == "none":
+        return
+    if OTEL_TRACES_EXPORTER == "otlp":
+        from opentelemetry.exporter.otlp.proto.http.trace_exporter import OTLPSpanExporter
+        exporter = OTLPSpanExporter()
+    elif OTEL_TRACES_EXPORTER == "stdout":
+        exporter = ConsoleSpanExporter()
+    else:
+        raise ValueError(f"invalid OTEL_TRACES_EXPORTER: {OTEL_TRACES_EXPORTER!r}")
+    provider = TracerProvider(resource=Resource.create({"service.name": OTEL_SERVICE_NAME}))
+    provider.add_span_processor(BatchSpanProcessor(exporter))
+    trace.set_tracer_provider(provider)
+
+
+tracer = trace.get_tracer("worker-python")
+
+
+def trace_attributes():
+    """Returns SQS message attributes carrying the current trace context."""
+    carrier = {}
+    propagate.inject(carrier)
+    return {key: {"DataType": "String", "StringValue": value} for key, value in carrier.items()}
+
+
+def trace_context(msg):
+    """Returns the trace context the sender put in msg's attributes."""
+    attrs = msg.get("MessageAttributes") or {}
+    return propagate.extract({key: value["StringValue"] for key, value in attrs.items() if "StringValue" in value})
+
+
+class Cancelled(Exception):
+    """Raised when the backend has cancelled the job being processed."""
+
+
+def cancel_checker(bucket, job_id):
+    """Returns a function that raises Cancelled once the job's cancel marker exists."""
+    last_checked = None
+
+    def check():
+        nonlocal last_checked
+        if not job_id:
+            return
+        now = time.monotonic()
+        if last_checked is not None and now - last_checked < CANCEL_CHECK_INTERVAL:
+            return
+        last_checked = now
+        try:
+            s3.head_object(Bucket=bucket, Key=f"cancelled/{job_id}")
+        except s3.exceptions.ClientError:
+            # no marker, or we can't tell; keep going
+            return
+        raise Cancelled()
+
+    return check
+
+
+@contextlib.contextmanager
+def heartbeat(msg):
+    """Keeps msg hidden from other receives while the block runs."""
+    stop = threading.Event()
+
+    def beat():
+        while not stop.wait(HEARTBEAT_INTERVAL):
+            try:
+                sqs.change_message_visibility(
+                    QueueUrl=TASK_QUEUE_URL,
+                    ReceiptHandle=msg["ReceiptHandle"],
+                    VisibilityTimeout=VISIBILITY_TIMEOUT,
+                )
+            except Exception as e:
+                log.warning(f"Failed to extend visibility of message {msg['MessageId']}: {e}")
+
+    thread = threading.Thread(target=beat, daemon=True)
+    thread.start()
+    try:
+        yield
+    finally:
+        stop.set()
+        thread.join()
+
 
-    obj = s3.get_object(Bucket=bucket, Key=key)
-    file_content = obj["Body"].read().decode("utf-8")
+def dead_letter(msg, reason):
+    """Moves a task that can never be processed to the dead-letter queue."""
+    sqs.send_message(
+        QueueUrl=TASK_DLQ_URL,
+        MessageBody=msg["Body"],
+        MessageAttributes={"FailureReason": {"DataType": "String", "StringValue": reason[:500]}},
+    )
+    sqs.delete_message(QueueUrl=TASK_QUEUE_URL, ReceiptHandle=msg["ReceiptHandle"])
+    log.warning(f"Dead-lettered message {msg['MessageId']}: {reason}")
+
+
+def parse_task(msg):
+    """Returns the task in msg, or raises ValueError if it isn't one."""
+    body = json.loads(msg["Body"])
+    if not isinstance(body, dict):
+        raise ValueError("task is not a JSON object")
+    missing = [field for field in ("bucket", "key", "userId") if not body.get(field)]
+    if missing:
+        raise ValueError(f"task is missing {', '.join(missing)}")
+    return body
+
+
+def send_event(body, event_type, **fields):
+    """Sends an intermediate progress message for a task to the response queue."""
+    event = {
+        "type": event_type,
+        "userId": body["userId"],
+        "documentId": body.get("documentId"),
+        "jobId": body.get("jobId"),
+    }
+    event.update(fields)
+    try:
+        sqs.send_message(QueueUrl=RESPONSE_QUEUE_URL, MessageBody=json.dumps(event), MessageAttributes=trace_attributes())
+    except Exception as e:
+        # progress is best effort, the final result is what counts
+        log.warning(f"Failed to send {event_type} event: {e}")
 
-    file_content = "Summarize following text in two sentences:\n" + file_content
 
+def split_chunks(text, size=CHUNK_SIZE):
+    """Splits text into chunks of at most size characters, preferring paragraph breaks."""
+    chunks = []
+    while len(text) > size:
+        cut = text.rfind("\n\n", 0, size)
+        if cut < size // 2:
+            cut = size
+        chunks.append(text[:cut])
+        text = text[cut:].lstrip("\n")
+    if text.strip() or not chunks:
+        chunks.append(text)
+    return chunks
+
+
+def add_usage(usage, reported):
+    """Adds the token counts the API reported for one completion to usage."""
+    if usage is None or not reported:
+        return
+    usage["promptTokens"] += reported.get("prompt_tokens") or 0
+    usage["completionTokens"] += reported.get("completion_tokens") or 0
+
+
+def complete(prompt, model, json_mode=False, usage=None):
     headers = {
         "Authorization": f"Bearer {OPENROUTER_API_KEY}",
         "Content-Type": "application/json",
     }
 
     data = {
-        "model": "x-ai/grok-4-fast:free",  
-        "messages": [{"role": "user", "content": file_content}]
+        "model": model,
+        "messages": [{"role": "user", "content": prompt}]
     }
+    if json_mode:
+        data["response_format"] = {"type": "json_object"}
 
-    response = requests.post(OPENROUTER_URL, headers=headers, json=data)
-    resp_json = response.json()
-    print("OpenRouter response:", resp_json)
+    with tracer.start_as_current_span("llm complete", kind=SpanKind.CLIENT, attributes={"llm.model": model}):
+        response = requests.post(OPENROUTER_URL, headers=headers, json=data)
+        resp_json = response.json()
+    log.debug(f"OpenRouter response: {resp_json}")
+    add_usage(usage, resp_json.get("usage"))
 
-    overview = resp_json["choices"][0]["message"]["content"]
+    return resp_json["choices"][0]["message"]["content"]
 
-    extension_pos = key.index(".")
-    overview_key = key[:extension_pos] + "_overview.txt"
 
-    s3.put_object(
-        Bucket=bucket,
-        Key=overview_key,
-        Body=overview.encode("utf-8")  
-    )
-    print(f"Summary uploaded to s3://{bucket}/{overview_key}")
+def complete_streaming(prompt, model, on_partial, json_mode=False, usage=None, check_cancelled=None):
+    """Streams a completion, calling on_partial with the text so far every PARTIAL_INTERVAL seconds."""
+    headers = {
+        "Authorization": f"Bearer {OPENROUTER_API_KEY}",
+        "Content-Type": "application/json",
+    }
+
+    data = {
+        "model": model,
+        "messages": [{"role": "user", "content": prompt}],
+        "stream": True,
+        # the last chunk then carries the token counts
+        "stream_options": {"include_usage": True},
+    }
+    if json_mode:
+        # the backend validates the JSON and repairs it if needed
+        data["response_format"] = {"type": "json_object"}
+
+    text = ""
+    last_sent = 0.0
+    with tracer.start_as_current_span("llm stream", kind=SpanKind.CLIENT, attributes={"llm.model": model}), \
+            requests.post(OPENROUTER_URL, headers=headers, json=data, stream=True) as response:
+        response.raise_for_status()
+        for line in response.iter_lines(decode_unicode=True):
+            # skip keep-alive comments and event separators
+            if not line or not line.startswith("data:"):
+                continue
+            payload = line[len("data:"):].strip()
+            if payload == "[DONE]":
+                break
+            chunk = json.loads(payload)
+            if "error" in chunk:
+                raise RuntimeError(chunk["error"].get("message", "completion failed"))
+            add_usage(usage, chunk.get("usage"))
+            for choice in chunk.get("choices", []):
+                text += choice.get("delta", {}).get("content") or ""
+            if check_cancelled:
+                check_cancelled()
+            if time.monotonic() - last_sent >= PARTIAL_INTERVAL:
+                on_partial(text)
+                last_sent = time.monotonic()
+
+    on_partial(text)
+    return text
+
+
+def process_file(bucket, key, summary_key=None, model=DEFAULT_MODEL, template=None, structured=False, on_event=None, usage=None, check_cancelled=None):
+
+    with tracer.start_as_current_span("s3 download", kind=SpanKind.CLIENT, attributes={"s3.bucket": bucket, "s3.key": key}):
+        obj = s3.get_object(Bucket=bucket, Key=key)
+        file_content = obj["Body"].read().decode("utf-8")
+
+    if on_event is None:
+        on_event = lambda event_type, **fields: None
+    if check_cancelled is None:
+        check_cancelled = lambda: None
+
+    seq = 0
+
+    def on_partial(text):
+        nonlocal seq
+        seq += 1
+        on_event("partial", seq=seq, text=text)
+
+    prompt, combine_prompt = prompts_for(template)
+    chunks = split_chunks(file_content)
+    if len(chunks) == 1:
+        overview = complete_streaming(prompt + file_content, model, on_partial, json_mode=structured, usage=usage, check_cancelled=check_cancelled)
+        on_event("progress", chunk=1, chunks=1)
+    else:
+        partials = []
+        for i, chunk in enumerate(chunks):
+            check_cancelled()
+            partials.append(complete(prompt + chunk, model, usage=usage))
+            on_event("progress", chunk=i + 1, chunks=len(chunks))
+        check_cancelled()
+        overview = complete_streaming(combine_prompt + "\n\n".join(partials), model, on_partial, json_mode=structured, usage=usage, check_cancelled=check_cancelled)
+
+    # don't write a summary nobody wants any more
+    check_cancelled()
+    if summary_key:
+        overview_key = summary_key
+    else:
+        extension_pos = key.index(".")
+        overview_key = key[:extension_pos] + "_overview.txt"
+
+    with tracer.start_as_current_span("s3 upload", kind=SpanKind.CLIENT, attributes={"s3.bucket": bucket, "s3.key": overview_key}):
+        s3.put_object(
+            Bucket=bucket,
+            Key=overview_key,
+            Body=overview.encode("utf-8")
+        )
+    log.info(f"Summary uploaded to s3://{bucket}/{overview_key}")
     return overview_key
 
 
+def handle_task(msg, body):
+    """Summarizes the file of a task and sends the result to the response queue."""
+    bucket = body["bucket"]
+    key = body["key"]
+    userId = body["userId"]
+
+    response_msg = {
+        "bucket": bucket,
+        "userId": userId,
+        "documentId": body.get("documentId"),
+        "jobId": body.get("jobId"),
+    }
+    usage = {"promptTokens": 0, "completionTokens": 0}
+    span = trace.get_current_span()
+    span.set_attribute("job.id", body.get("jobId") or 0)
+    started = time.monotonic()
+    check_cancelled = cancel_checker(bucket, body.get("jobId"))
+    try:
+        # cancelled while waiting in the queue: skip it without a started event
+        check_cancelled()
+
+        log.info(f"Processing file {key} from {bucket} for user {userId} "
+              f"(template {body.get('templateId')} v{body.get('templateVersion')}, {body.get('style')})")
+        send_event(body, "started")
+
+        overview_key = process_file(
+            bucket,
+            key,
+            summary_key=body.get("summaryKey"),
+            model=body.get("model", DEFAULT_MODEL),
+            template=body.get("prompt"),
+            structured=body.get("format") == "structured",
+            on_event=lambda event_type, **fields: send_event(body, event_type, **fields),
+            usage=usage,
+            check_cancelled=check_cancelled,
+        )
+        response_msg.update(key=overview_key, status="completed")
+    except Cancelled:
+        log.info(f"Job for {key} was cancelled")
+        # still reported so the backend can free the job's slot
+        response_msg.update(key="", status="cancelled")
+    except Exception as e:
+        log.error(f"Failed to process {key}: {e}")
+        span.record_exception(e)
+        span.set_status(Status(StatusCode.ERROR, str(e)))
+        response_msg.update(key="", status="failed", error=str(e)[:500])
+    response_msg.update(usage, latencyMs=int((time.monotonic() - started) * 1000))
+
+    sqs.send_message(
+        QueueUrl=RESPONSE_QUEUE_URL,
+        MessageBody=json.dumps(response_msg),
+        MessageAttributes=trace_attributes(),
+    )
+    log.info(f"Sent response message for {key}")
+
+    sqs.delete_message(
+        QueueUrl=TASK_QUEUE_URL,
+        ReceiptHandle=msg["ReceiptHandle"]
+    )
+    log.debug(f"Deleted message {msg['MessageId']}")
+
+
+
 def worker_loop():
     while True:
+        # one at a time: tasks received along with one would wait hidden
+        # without a heartbeat while it is summarized
         resp = sqs.receive_message(
             QueueUrl=TASK_QUEUE_URL,
-            MaxNumberOfMessages=5,
-            WaitTimeSeconds=10
+            MaxNumberOfMessages=1,
+            WaitTimeSeconds=10,
+            MessageAttributeNames=TRACE_ATTRIBUTES,
         )
 
         messages = resp.get("Messages", [])
         if not messages:
-            print("No new messages, waiting...")
+            log.debug("No new messages, waiting...")
             time.sleep(5)
             continue
 
         for msg in messages:
-            body = json.loads(msg["Body"])
-            bucket = body["bucket"]
-            key = body["key"]
-            userId = body["userId"]
-
-            print(f"Processing file {key} from {bucket} for user {userId}")
-            overview_key = process_file(bucket, key)
-            response_msg = {
-                "bucket": bucket,
-                "key": overview_key,
-                "status": "completed",
-                "userId": userId,
-            }
-
-            sqs.send_message(
-                QueueUrl=RESPONSE_QUEUE_URL,
-                MessageBody=json.dumps(response_msg),
-            )
-            print(f"Sent response message for {overview_key}")
-
-
-            sqs.delete_message(
-                QueueUrl=TASK_QUEUE_URL,
-                ReceiptHandle=msg["ReceiptHandle"]
-            )
-            print(f"Deleted message {msg['MessageId']}")
+            # continues the trace of the upload the task is for
+            with tracer.start_as_current_span(
+                "task-queue process",
+                context=trace_context(msg),
+                kind=SpanKind.CONSUMER,
+                attributes={"messaging.system": "aws_sqs", "messaging.message.id": msg["MessageId"]},
+            ), heartbeat(msg):
+                process_message(msg)
+
+
+def process_message(msg):
+    """Handles one task message, dead-lettering it if it isn't a valid task."""
+    try:
+        body = parse_task(msg)
+    except ValueError as e:
+        try:
+            dead_letter(msg, f"invalid task: {e}")
+        except Exception as e:
+            # left on the queue; SQS dead-letters it once its receives run out
+            log.error(f"Failed to dead-letter message {msg['MessageId']}: {e}")
+        return
 
+    token = log_context.set({"job_id": body.get("jobId"), "document_id": body.get("documentId")})
+    try:
+        handle_task(msg, body)
+    except Exception as e:
+        # not deleted, so it is received again until SQS dead-letters it
+        log.error(f"Failed to handle message {msg['MessageId']}: {e}")
+    finally:
+        log_context.reset(token)
 
 if __name__ == "__main__":
-    print("Worker started...")
+    setup_logging()
+    setup_tracing()
+    log.info("Worker started...")
     worker_loop()
\ No newline at end of file